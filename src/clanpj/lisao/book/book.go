@@ -0,0 +1,192 @@
+// Polyglot opening book (.bin) reader.
+// The book format is a sequence of 16-byte big-endian entries sorted by position key:
+// key (8 bytes), move (2 bytes), weight (2 bytes), learn (4 bytes).
+
+package book
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+const entrySize = 16
+
+// Default maximum game ply at which we still consult the book
+const DefaultMaxDepth = 20
+
+type EntryT struct {
+	Key    uint64
+	Move   uint16 // Polyglot move encoding
+	Weight uint16
+	Learn  uint32
+}
+
+type BookT struct {
+	entries []EntryT
+}
+
+// How to choose between the book moves for a position
+type SelectionT int
+
+const (
+	SelectWeighted SelectionT = iota // random choice in proportion to the entry weights
+	SelectBest                       // always choose the highest-weighted move
+)
+
+func SelectionString(selection SelectionT) string {
+	switch selection {
+	case SelectWeighted:
+		return "Weighted"
+	case SelectBest:
+		return "Best"
+	default:
+		return "<unknown>"
+	}
+}
+
+// Load a Polyglot book file
+func Open(path string) (*BookT, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+// Load a Polyglot book
+func Read(r io.Reader) (*BookT, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+func parse(data []byte) (*BookT, error) {
+	if len(data)%entrySize != 0 {
+		return nil, fmt.Errorf("book: size %d is not a multiple of the %d-byte entry size", len(data), entrySize)
+	}
+
+	entries := make([]EntryT, len(data)/entrySize)
+	for i := range entries {
+		e := data[i*entrySize : (i+1)*entrySize]
+		entries[i] = EntryT{
+			Key:    binary.BigEndian.Uint64(e[0:8]),
+			Move:   binary.BigEndian.Uint16(e[8:10]),
+			Weight: binary.BigEndian.Uint16(e[10:12]),
+			Learn:  binary.BigEndian.Uint32(e[12:16]),
+		}
+	}
+
+	// Books are supposed to be sorted already, but it's cheap to make sure since we binary search
+	if !sort.SliceIsSorted(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key }) {
+		return nil, errors.New("book: entries are not sorted by key")
+	}
+
+	return &BookT{entries: entries}, nil
+}
+
+// Number of entries in the book
+func (b *BookT) Len() int { return len(b.entries) }
+
+// All book entries for the board position
+func (b *BookT) Entries(board *dragon.Board) []EntryT {
+	key := Key(board)
+	start := sort.Search(len(b.entries), func(i int) bool { return b.entries[i].Key >= key })
+	end := start
+	for end < len(b.entries) && b.entries[end].Key == key {
+		end++
+	}
+	return b.entries[start:end]
+}
+
+// Game ply of the board, derived from the full-move number
+func GamePly(board *dragon.Board) int {
+	ply := 2 * (int(board.Fullmoveno) - 1)
+	if !board.Wtomove {
+		ply++
+	}
+	return ply
+}
+
+// Choose a book move for the board position.
+// No move is returned past maxDepth plies into the game (unless maxDepth is 0), or if the position is not in the book.
+// Book moves which are not legal in the position are ignored.
+func (b *BookT) Probe(board *dragon.Board, selection SelectionT, maxDepth int) (dragon.Move, bool) {
+	if maxDepth != 0 && GamePly(board) >= maxDepth {
+		return 0, false
+	}
+
+	var moves []dragon.Move
+	var weights []int
+	totalWeight := 0
+	for _, entry := range b.Entries(board) {
+		move, ok := decodeMove(board, entry.Move)
+		if !ok || entry.Weight == 0 {
+			continue
+		}
+		moves = append(moves, move)
+		weights = append(weights, int(entry.Weight))
+		totalWeight += int(entry.Weight)
+	}
+	if len(moves) == 0 {
+		return 0, false
+	}
+
+	switch selection {
+	case SelectBest:
+		best := 0
+		for i := range moves {
+			if weights[i] > weights[best] {
+				best = i
+			}
+		}
+		return moves[best], true
+	default:
+		r := rand.Intn(totalWeight)
+		for i := range moves {
+			if r < weights[i] {
+				return moves[i], true
+			}
+			r -= weights[i]
+		}
+	}
+
+	// Not reached
+	return moves[0], true
+}
+
+// Convert a Polyglot move to the equivalent legal move in the position.
+// Polyglot moves are: to file (3 bits), to row (3 bits), from file, from row, promotion piece (3 bits - 1 for knight up to 4 for queen).
+// Castling is encoded as king-takes-own-rook, e.g. e1h1.
+func decodeMove(board *dragon.Board, pgMove uint16) (dragon.Move, bool) {
+	to := uint8(pgMove & 0x3f)
+	from := uint8((pgMove >> 6) & 0x3f)
+	var promote dragon.Piece
+	if pgPromote := (pgMove >> 12) & 0x7; pgPromote != 0 {
+		promote = dragon.Piece(pgPromote + 1)
+	}
+
+	if board.PieceAt(from) == dragon.King {
+		switch {
+		case (from == 4 && to == 7) || (from == 60 && to == 63):
+			to = from + 2
+		case (from == 4 && to == 0) || (from == 60 && to == 56):
+			to = from - 2
+		}
+	}
+
+	for _, move := range board.GenerateLegalMoves() {
+		if move.From() == from && move.To() == to && move.Promote() == promote {
+			return move, true
+		}
+	}
+	return 0, false
+}
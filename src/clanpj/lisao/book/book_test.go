@@ -0,0 +1,104 @@
+package book
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+func boardAfter(t *testing.T, moves string) *dragon.Board {
+	board := dragon.ParseFen(dragon.Startpos)
+	for _, moveStr := range strings.Fields(moves) {
+		move, err := dragon.ParseMove(moveStr)
+		if err != nil {
+			t.Fatalf("Bad move %s: %v", moveStr, err)
+		}
+		board.Apply(move)
+	}
+	return &board
+}
+
+// Reference keys from the Polyglot book format specification
+func TestKey(t *testing.T) {
+	tests := []struct {
+		moves string
+		key   uint64
+	}{
+		{"", 0x463b96181691fc9c},
+		{"e2e4", 0x823c9b50fd114196},
+		{"e2e4 d7d5", 0x0756b94461c50fb0},
+		{"e2e4 d7d5 e4e5", 0x662fafb965db29d4},
+		{"e2e4 d7d5 e4e5 f7f5", 0x22a48b5a8e47ff78},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2", 0x652a607ca3f242c1},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", 0x00fdd303c946bdd9},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4", 0x3c8123ea7b067637},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", 0x5c3f9b829b279560},
+	}
+	for _, test := range tests {
+		key := Key(boardAfter(t, test.moves))
+		if key != test.key {
+			t.Errorf("Key after '%s' is 0x%016x expected 0x%016x\n", test.moves, key, test.key)
+		}
+	}
+}
+
+func makeBook(t *testing.T, entries []EntryT) *BookT {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		binary.Write(&buf, binary.BigEndian, e)
+	}
+	b, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return b
+}
+
+// Polyglot move encoding
+func pgMove(from, to string) uint16 {
+	f, _ := dragon.AlgebraicToIndex(from)
+	tt, _ := dragon.AlgebraicToIndex(to)
+	return uint16(f)<<6 | uint16(tt)
+}
+
+func TestProbe(t *testing.T) {
+	start := boardAfter(t, "")
+	castlePos := dragon.ParseFen("r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 1")
+	b := makeBook(t, []EntryT{
+		{Key: Key(start), Move: pgMove("e2", "e4"), Weight: 10},
+		{Key: Key(start), Move: pgMove("d2", "d4"), Weight: 20},
+		{Key: Key(start), Move: pgMove("e2", "e5"), Weight: 100}, // illegal - ignored
+		{Key: Key(&castlePos), Move: pgMove("e1", "h1"), Weight: 1},
+	})
+
+	move, ok := b.Probe(start, SelectBest, 0)
+	if !ok || move.String() != "d2d4" {
+		t.Errorf("Best book move is %s (%v) expected d2d4\n", &move, ok)
+	}
+
+	for i := 0; i < 100; i++ {
+		move, ok = b.Probe(start, SelectWeighted, 0)
+		if !ok || (move.String() != "d2d4" && move.String() != "e2e4") {
+			t.Fatalf("Weighted book move is %s (%v) expected d2d4 or e2e4\n", &move, ok)
+		}
+	}
+
+	move, ok = b.Probe(&castlePos, SelectBest, 0)
+	if !ok || move.String() != "e1g1" {
+		t.Errorf("Castling book move is %s (%v) expected e1g1\n", &move, ok)
+	}
+
+	afterE4 := boardAfter(t, "e2e4")
+	if _, ok = b.Probe(afterE4, SelectBest, 0); ok {
+		t.Errorf("Found book move for position not in the book\n")
+	}
+
+	deep := dragon.ParseFen("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 20")
+	b = makeBook(t, []EntryT{{Key: Key(&deep), Move: pgMove("e2", "e4"), Weight: 1}})
+	if _, ok = b.Probe(&deep, SelectBest, DefaultMaxDepth); ok {
+		t.Errorf("Found book move beyond max depth\n")
+	}
+}
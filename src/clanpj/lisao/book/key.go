@@ -0,0 +1,85 @@
+package book
+
+import (
+	"math/bits"
+	"strings"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Polyglot piece kinds - black is even, white is odd
+const (
+	blackPawnKind = 0
+	whitePawnKind = 1
+)
+
+// Offsets into random64 for the non-piece keys
+const (
+	castleKeysOffset    = 768
+	enPassantKeysOffset = 772
+	turnKeyOffset       = 780
+)
+
+// Polyglot zobrist key of the board.
+// This is independent of dragon's Hash() which uses its own (random) zobrist constants.
+func Key(board *dragon.Board) uint64 {
+	var key uint64
+
+	key ^= sideKey(&board.White, whitePawnKind)
+	key ^= sideKey(&board.Black, blackPawnKind)
+
+	// Castling rights and en-passant square are not exported by dragon so we pick them out of the FEN
+	fields := strings.Fields(board.ToFen())
+	if len(fields) > 2 {
+		for i, c := range "KQkq" {
+			if strings.ContainsRune(fields[2], c) {
+				key ^= random64[castleKeysOffset+i]
+			}
+		}
+	}
+	if len(fields) > 3 && fields[3] != "-" {
+		// Polyglot only includes the en-passant file if a pawn of the side to move can actually capture
+		epFile := int(fields[3][0] - 'a')
+		if canCaptureEnPassant(board, epFile) {
+			key ^= random64[enPassantKeysOffset+epFile]
+		}
+	}
+
+	if board.Wtomove {
+		key ^= random64[turnKeyOffset]
+	}
+
+	return key
+}
+
+// Piece-square keys for all of one side's pieces
+func sideKey(bbs *dragon.Bitboards, pawnKind int) uint64 {
+	var key uint64
+	// Polyglot piece order matches dragon's piece order: pawn, knight, bishop, rook, queen, king
+	pieces := [6]uint64{bbs.Pawns, bbs.Knights, bbs.Bishops, bbs.Rooks, bbs.Queens, bbs.Kings}
+	for i, bb := range pieces {
+		kind := pawnKind + 2*i
+		for ; bb != 0; bb &= bb - 1 {
+			// Both dragon and Polyglot index squares from a1 = 0 to h8 = 63
+			key ^= random64[64*kind+bits.TrailingZeros64(bb)]
+		}
+	}
+	return key
+}
+
+// Is there a pawn of the side to move adjacent to the pawn that just double-pushed on the given file?
+func canCaptureEnPassant(board *dragon.Board, epFile int) bool {
+	var pawns uint64
+	var rank uint
+	if board.Wtomove {
+		pawns, rank = board.White.Pawns, 4
+	} else {
+		pawns, rank = board.Black.Pawns, 3
+	}
+	for _, file := range [2]int{epFile - 1, epFile + 1} {
+		if file >= 0 && file < 8 && pawns&(uint64(1)<<(8*rank+uint(file))) != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"time"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Default depth for the bench - deep enough to exercise the search heuristics but still quick.
+const BenchDepth = 6
+
+// Bench positions - a mix of opening, middle-game and end-game positions.
+// Changing this list changes the bench signature, so don't do it lightly.
+var BenchFens = []string{
+	dragon.Startpos,
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"r1bq1rk1/pp2ppbp/2np1np1/8/3NP3/2N1BP2/PPPQ2PP/R3KB1R w KQ - 3 9",
+	"r1bqkb1r/pp1n1ppp/2p1pn2/3p4/2PP4/2N1PN2/PP3PPP/R1BQKB1R w KQkq - 1 6",
+	"2r2rk1/pp1bqppp/2n1pn2/3p4/3P4/P1NBPN2/1PQ2PPP/R4RK1 w - - 5 14",
+	"r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+	"4rrk1/2p1b1p1/p1p3q1/4p3/2P2n1p/1P1NR2P/PB3PP1/3R1QK1 b - - 2 24",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"6k1/6p1/6Pp/ppp5/3pn2P/1P3K2/1PP2P2/8 b - - 0 1",
+	"8/8/8/5N2/8/p7/8/2NK3k w - - 0 1",
+}
+
+type BenchResultT struct {
+	Nodes     uint64 // total nodes over all positions - this is the bench signature
+	ElapsedMs uint64 // total search time over all positions
+	Nps       uint64 // nodes per second
+}
+
+// Search each of the bench positions to the given depth with the engine's eval weights, each with a fresh TT, QTT and
+// move history.
+// The node count is deterministic for a given search and eval, so any change that alters the search tree
+// shows up as a different signature. Tablebases are never probed, so the signature doesn't depend on which are installed.
+func Bench(e *EngineT, depth int) (BenchResultT, error) {
+	var result BenchResultT
+	var elapsedSecs float64
+
+	opts := NewOptions()
+	opts.UseSyzygy = false
+
+	for _, fen := range BenchFens {
+		board := dragon.ParseFen(fen)
+		ht := make(HistoryTableT)
+		ht.Add(board.Hash())
+
+		e.ResetTT()
+		e.ResetQtt()
+		e.ResetMoveHistory()
+
+		// Never time out
+		var timeout uint32
+
+		start := time.Now()
+
+		searchResult, err := Search(SearchRequestT{Options: opts, Engine: e, Board: &board, History: ht, Depth: depth, Timeout: &timeout})
+		if err != nil {
+			return result, err
+		}
+
+		elapsedSecs += time.Since(start).Seconds()
+		result.Nodes += searchResult.Stats.Nodes
+	}
+
+	result.ElapsedMs = uint64(elapsedSecs * 1000)
+	if elapsedSecs > 0 {
+		result.Nps = uint64(float64(result.Nodes) / elapsedSecs)
+	}
+
+	return result, nil
+}
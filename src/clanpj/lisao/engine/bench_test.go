@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"testing"
+
+	"clanpj/lisao/syzygy"
+)
+
+func TestBenchIsDeterministic(t *testing.T) {
+	e := NewEngine(nil)
+	res1, err := Bench(e, 4)
+	if err != nil {
+		t.Fatalf("Bench failed: %v", err)
+	}
+	res2, err := Bench(e, 4)
+	if err != nil {
+		t.Fatalf("Bench failed: %v", err)
+	}
+	if res1.Nodes != res2.Nodes {
+		t.Errorf("Bench node count is not deterministic: %d then %d\n", res1.Nodes, res2.Nodes)
+	}
+}
+
+func TestBenchIgnoresTablebases(t *testing.T) {
+	// Add a position that's in the test tables
+	defer func(fens []string) { BenchFens = fens }(BenchFens)
+	BenchFens = append(BenchFens[:len(BenchFens):len(BenchFens)], "8/4k3/8/4K3/4P3/8/8/8 w - - 0 1")
+
+	e := NewEngine(nil)
+	res1, err := Bench(e, 4)
+	if err != nil {
+		t.Fatalf("Bench failed: %v", err)
+	}
+	if n, err := syzygy.Init("../syzygy/testdata"); n == 0 || err != nil {
+		t.Fatalf("Failed to load the test tablebases (%v)", err)
+	}
+	defer syzygy.Init("")
+	res2, err := Bench(e, 4)
+	if err != nil {
+		t.Fatalf("Bench failed: %v", err)
+	}
+	if res1.Nodes != res2.Nodes {
+		t.Errorf("Bench node count depends on the tablebases: %d without then %d with\n", res1.Nodes, res2.Nodes)
+	}
+}
+
+// go test -bench Search -run XXX
+func BenchmarkSearch(b *testing.B) {
+	e := NewEngine(nil)
+	for i := 0; i < b.N; i++ {
+		res, err := Bench(e, BenchDepth)
+		if err != nil {
+			b.Fatalf("Bench failed: %v", err)
+		}
+		b.ReportMetric(float64(res.Nodes), "nodes")
+		b.ReportMetric(float64(res.Nps), "nps")
+	}
+}
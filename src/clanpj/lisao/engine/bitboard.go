@@ -8,6 +8,9 @@ const Shift uint = 1
 const A uint64 = 0x0101010101010101
 const H uint64 = 0x8080808080808080
 
+// Dark squares, including A1
+const DarkSquares uint64 = 0xaa55aa55aa55aa55
+
 func N(bb uint64) uint64 { return bb << 8 }
 
 func S(bb uint64) uint64 { return bb >> 8 }
@@ -81,3 +84,24 @@ func BPawnAttacks(bPawns uint64) uint64 {
 	// Take either way
 	return sw | se
 }
+
+// Knight attacks
+func KnightAttacks(knights uint64) uint64 {
+	// one file either way
+	h1 := W(knights) | E(knights)
+	// two files either way
+	h2 := W(W(knights)) | E(E(knights))
+
+	// then two ranks or one rank either way respectively
+	return N(N(h1)) | S(S(h1)) | N(h2) | S(h2)
+}
+
+// King attacks
+func KingAttacks(kings uint64) uint64 {
+	// the king's rank either side
+	h := W(kings) | E(kings)
+	// then the ranks either side of that
+	hk := h | kings
+
+	return h | N(hk) | S(hk)
+}
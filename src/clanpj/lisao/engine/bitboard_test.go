@@ -30,4 +30,16 @@ func TestBitboard(t *testing.T) {
 
 	testResult(t, "BPawnScope(0x0001000000008001) is 0x%016x expected 0x%016x\n", BPawnScope(0x0001000000008001), 0x00000303030303c3)
 	testResult(t, "BPawnScope(0x0002000000004081) is 0x%016x expected 0x%016x\n", BPawnScope(0x0002000000004081), 0x00000707070707e7)
+
+	// a1 and h8 corners
+	testResult(t, "KnightAttacks(0x8000000000000001) is 0x%016x expected 0x%016x\n", KnightAttacks(0x8000000000000001), 0x0020400000020400)
+	// d4 - all 8 squares
+	testResult(t, "KnightAttacks(0x0000000008000000) is 0x%016x expected 0x%016x\n", KnightAttacks(0x0000000008000000), 0x0000142200221400)
+
+	// a1 corner
+	testResult(t, "KingAttacks(0x0000000000000001) is 0x%016x expected 0x%016x\n", KingAttacks(0x0000000000000001), 0x0000000000000302)
+	// h8 corner
+	testResult(t, "KingAttacks(0x8000000000000000) is 0x%016x expected 0x%016x\n", KingAttacks(0x8000000000000000), 0x40c0000000000000)
+	// d4 - all 8 squares
+	testResult(t, "KingAttacks(0x0000000008000000) is 0x%016x expected 0x%016x\n", KingAttacks(0x0000000008000000), 0x0000001c141c0000)
 }
@@ -12,38 +12,138 @@ const (
 	NegAlphaBeta SearchAlgorithmT = iota
 )
 
-var SearchAlgorithm = NegAlphaBeta
-var SearchDepth = 7          // Ignored now that time control is implemented
-var SearchCutoffPercent = 25 // If we've used more than this percentage of the target time then we bail on the search instead of starting a new depth
-var HeurUseNullMove = true
-var UseEarlyMoveHint = false // Try the hint move before doing movegen - worse until we can do early null-move heuristic (requires in-check test)
-var UseMoveOrdering = true
-var UseIDMoveHint = true
-var MinIDMoveHintDepth = 3
-var UseKillerMoves = true
-var UseDeepKillerMoves = true // only valid if UseKillerMoves == true
-var UseTT = true
-var HeurUseTTDeeperHits = true // true iff we embrace deeper TT results as valid (heuristic!)
-var UsePosRepetition = true
-var UseQSearch = true
-var QSearchDepth = 12
-var UseQSearchTT = true
-var UseQSearchMoveOrdering = true
-var UseQSearchRampagePruning = true // only valid if UseQSearchMoveOrdering == true
-var QSearchRampagePruningDepth = 4  // only valid if UseQSearchRampagePruning == true
-var UseQKillerMoves = true
-var UseQDeepKillerMoves = true // only valid if UseQKillerMoves == true
-
-func SearchAlgorithmString() string {
-	switch SearchAlgorithm {
+// Search configuration options - each engine instance has its own so that searches with different options can coexist
+// The fields are exposed as UCI options by NewOptionRegistry - int fields need min and max tags for the UCI bounds.
+type OptionsT struct {
+	SearchAlgorithm            SearchAlgorithmT `uci:"-"`
+	SearchDepth                int              `min:"1" max:"1024"`  // Ignored now that time control is implemented
+	SearchCutoffPercent        int              `min:"1" max:"100"`   // If we've used more than this percentage of the target time then we bail on the search instead of starting a new depth
+	ScoreDropCutoffPercent     int              `min:"1" max:"100"`   // Used instead of SearchCutoffPercent if the root eval dropped (failed low) at the last depth
+	ProgressIntervalMs         int              `min:"1" max:"60000"` // minimum time between search progress reports
+	HeurUseNullMove            bool
+	UseEarlyMoveHint           bool // Try the hint move before doing movegen - worse until we can do early null-move heuristic (requires in-check test)
+	UseMoveOrdering            bool
+	UseIDMoveHint              bool
+	MinIDMoveHintDepth         int `min:"2" max:"1024"`
+	UseKillerMoves             bool
+	UseDeepKillerMoves         bool // only valid if UseKillerMoves == true
+	UseHistoryHeuristic        bool // order quiet moves by how often they caused beta cut-offs
+	UseCounterMoves            bool // order the quiet move that last refuted the previous move after the killers
+	UseTT                      bool
+	HeurUseTTDeeperHits        bool // true iff we embrace deeper TT results as valid (heuristic!)
+	UseDrawRules               bool // fifty-move rule and insufficient material draws in search
+	Contempt                   int  `min:"-1000" max:"1000"` // centipawns the engine subtracts from the draw score - positive avoids draws
+	UsePosRepetition           bool
+	UseAspirationWindows       bool // search the root with a narrow window around the eval from the previous depth of the same parity
+	AspirationWindow           int  `min:"1" max:"1000"` // initial half-width of the aspiration window in centipawns - only valid if UseAspirationWindows == true
+	AspirationMinDepth         int  `min:"2" max:"1024"` // only valid if UseAspirationWindows == true
+	UsePVS                     bool // principal variation search - zero-window search for all but the first move
+	UseLateMoveReductions      bool // reduce the search depth of quiet moves ordered late
+	LMRMinDepth                int  `min:"2" max:"1024"` // only valid if UseLateMoveReductions == true
+	LMRMinMoveIndex            int  `min:"1" max:"1024"` // only valid if UseLateMoveReductions == true
+	UseCheckExtensions         bool // search moves that give check one ply deeper
+	UseOneReplyExtensions      bool // search the only legal move one ply deeper
+	UsePawnPushExtensions      bool // search pawn pushes to the seventh rank one ply deeper
+	UseSingularExtensions      bool // search the TT move one ply deeper if a verification search shows that no other move comes close
+	SingularMinDepth           int  `min:"5" max:"1024"` // only valid if UseSingularExtensions == true
+	MaxPathExtensions          int  `min:"0" max:"64"`   // maximum number of extension plies on any one search path
+	UseQSearch                 bool
+	QSearchDepth               int  `min:"1" max:"1024"`
+	UseReverseFutilityPruning  bool // cut zero-window nodes near the leaves where the static eval is well above beta
+	UseRazoring                bool // only q-search zero-window nodes near the leaves where the static eval is well below alpha
+	UseFutilityPruning         bool // skip quiet moves near the leaves where the static eval is well below alpha
+	UseQSearchTT               bool
+	UseQSearchMoveOrdering     bool
+	UseQSearchRampagePruning   bool // only valid if UseQSearchMoveOrdering == true - superseded by UseQSearchSEEPruning
+	QSearchRampagePruningDepth int  `min:"0" max:"1024"` // only valid if UseQSearchRampagePruning == true
+	UseQSearchSEEPruning       bool // skip captures that lose material according to SEE, unless in check
+	UseSEEMoveOrdering         bool // order captures that lose material according to SEE after quiet moves
+	UseQSearchChecks           bool // also search quiet moves that give check in the first QSearchCheckDepth plies of q-search
+	QSearchCheckDepth          int  `min:"0" max:"1024"` // only valid if UseQSearchChecks == true
+	UseQKillerMoves            bool
+	UseQDeepKillerMoves        bool // only valid if UseQKillerMoves == true
+	UseSyzygy                  bool // probe the Syzygy endgame tablebases if any were found - see syzygy.Init()
+	UsePawnHash                bool
+	PawnHashSize               int `min:"1" max:"1024"` // megabytes - takes effect at the next search
+}
+
+// The default options
+func NewOptions() *OptionsT {
+	return &OptionsT{
+		SearchAlgorithm:            NegAlphaBeta,
+		SearchDepth:                7,
+		SearchCutoffPercent:        25,
+		ScoreDropCutoffPercent:     50,
+		ProgressIntervalMs:         1000,
+		HeurUseNullMove:            true,
+		UseEarlyMoveHint:           false,
+		UseMoveOrdering:            true,
+		UseIDMoveHint:              true,
+		MinIDMoveHintDepth:         3,
+		UseKillerMoves:             true,
+		UseDeepKillerMoves:         true,
+		UseHistoryHeuristic:        true,
+		UseCounterMoves:            true,
+		UseTT:                      true,
+		HeurUseTTDeeperHits:        true,
+		UseDrawRules:               true,
+		Contempt:                   0,
+		UsePosRepetition:           true,
+		UseAspirationWindows:       true,
+		AspirationWindow:           25,
+		AspirationMinDepth:         4,
+		UsePVS:                     true,
+		UseLateMoveReductions:      true,
+		LMRMinDepth:                3,
+		LMRMinMoveIndex:            3,
+		UseCheckExtensions:         true,
+		UseOneReplyExtensions:      true,
+		UsePawnPushExtensions:      true,
+		UseSingularExtensions:      false,
+		SingularMinDepth:           8,
+		MaxPathExtensions:          8,
+		UseQSearch:                 true,
+		QSearchDepth:               12,
+		UseReverseFutilityPruning:  true,
+		UseRazoring:                true,
+		UseFutilityPruning:         true,
+		UseQSearchTT:               true,
+		UseQSearchMoveOrdering:     true,
+		UseQSearchRampagePruning:   false,
+		QSearchRampagePruningDepth: 4,
+		UseQSearchSEEPruning:       true,
+		UseSEEMoveOrdering:         true,
+		UseQSearchChecks:           true,
+		QSearchCheckDepth:          1,
+		UseQKillerMoves:            true,
+		UseQDeepKillerMoves:        true,
+		UseSyzygy:                  true,
+		UsePawnHash:                true,
+		PawnHashSize:               2,
+	}
+}
+
+func (opts *OptionsT) SearchAlgorithmString() string {
+	switch opts.SearchAlgorithm {
 	case NegAlphaBeta:
 		return "NegAlphaBeta"
 	default:
-		SearchAlgorithm = NegAlphaBeta
+		opts.SearchAlgorithm = NegAlphaBeta
 		return "NegAlphaBeta"
 	}
 }
 
+// Late move reduction table parameters - see lmrReductions
+const LMRBase = 1.0
+const LMRDivisor = 2.0
+
+// Singular extension parameters - see isSingularMove
+const SingularMargin EvalCp = 50
+const SingularDepthReduction = 4 // must be even to cope with our even/odd ply eval instability
+
 const MinDepth = 1
 const MaxDepth = 1024
+
+// Extra plies beyond the mate distance that we search in mate mode before giving up
+const MateSearchMarginPlies = 2
 const NoMove dragon.Move = 0
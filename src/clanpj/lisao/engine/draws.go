@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"math/bits"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// A draw by the fifty-move rule - 100 plies without a capture or pawn move - unless the side to move is checkmated
+func IsFiftyMoveDraw(board *dragon.Board) bool {
+	if board.Halfmoveclock < 100 {
+		return false
+	}
+	if !board.OurKingInCheck() {
+		return true
+	}
+	legalMoves, _ := board.GenerateLegalMoves2(false /*all moves*/)
+	return len(legalMoves) != 0
+}
+
+// Neither side can possibly checkmate - K v K, K+minor v K, and kings and bishops with all bishops on the same colour
+func IsInsufficientMaterial(board *dragon.Board) bool {
+	w, b := &board.White, &board.Black
+	if w.Pawns|b.Pawns|w.Rooks|b.Rooks|w.Queens|b.Queens != 0 {
+		return false
+	}
+	wMinors := bits.OnesCount64(w.Knights | w.Bishops)
+	bMinors := bits.OnesCount64(b.Knights | b.Bishops)
+	if wMinors+bMinors <= 1 {
+		return true
+	}
+	bishops := w.Bishops | b.Bishops
+	return w.Knights|b.Knights == 0 && (bishops&DarkSquares == 0 || bishops&^DarkSquares == 0)
+}
+
+// The game is over by rule, i.e. without a move being made - fifty-move rule or insufficient material
+func IsDrawByRule(board *dragon.Board) bool {
+	return IsInsufficientMaterial(board) || IsFiftyMoveDraw(board)
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+func TestDrawByRule(t *testing.T) {
+	var tests = []struct {
+		fen                    string
+		isInsufficientMaterial bool
+		isFiftyMoveDraw        bool
+	}{
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 1", true, false},
+		{"4k3/8/8/8/8/8/8/4KN2 w - - 0 1", true, false},
+		{"4k3/8/8/8/8/8/8/4KB2 b - - 0 1", true, false},
+		{"4kb2/8/8/8/8/8/8/2B1K3 w - - 0 1", true, false},   // same-coloured bishops
+		{"2b1k3/8/8/8/8/8/8/2B1K3 w - - 0 1", false, false}, // opposite-coloured bishops
+		{"4kn2/8/8/8/8/8/8/4KN2 w - - 0 1", false, false},
+		{"4k3/8/8/8/8/8/8/3NKN2 w - - 0 1", false, false},
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", false, false},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 99 80", false, false},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 100 80", false, true},
+		{"R3k3/8/4K3/8/8/8/8/8 b - - 100 80", false, false}, // checkmate takes precedence
+		{"4k3/R7/4K3/8/8/8/8/8 b - - 100 80", false, true},
+	}
+
+	for i, test := range tests {
+		board := dragon.ParseFen(test.fen)
+		if isInsufficientMaterial := IsInsufficientMaterial(&board); isInsufficientMaterial != test.isInsufficientMaterial {
+			t.Errorf("Test %d: expected insufficient material %v for %s\n", i, test.isInsufficientMaterial, test.fen)
+		}
+		if isFiftyMoveDraw := IsFiftyMoveDraw(&board); isFiftyMoveDraw != test.isFiftyMoveDraw {
+			t.Errorf("Test %d: expected fifty-move draw %v for %s\n", i, test.isFiftyMoveDraw, test.fen)
+		}
+	}
+}
+
+func TestDrawScale(t *testing.T) {
+	var tests = []struct {
+		fen   string
+		scale int
+	}{
+		{"4k3/8/8/8/8/8/8/4KQ2 w - - 0 1", normalDrawScale},
+		{"4k3/8/8/8/8/8/8/3NKN2 w - - 0 1", 0},
+		{"4k3/8/8/8/8/8/8/3NKB2 w - - 0 1", normalDrawScale},
+		{"4kb2/8/8/8/8/8/8/4K2R w - - 0 1", 4},
+		{"4kb2/8/8/8/8/8/8/R3K2R w - - 0 1", normalDrawScale},
+		{"4k3/8/8/8/8/8/1p6/4K1N1 w - - 0 1", 0},                            // a minor piece alone can't win even against pawns
+		{"7k/8/8/7P/8/8/8/3BK3 w - - 0 1", 0},                               // wrong-coloured bishop
+		{"7k/8/8/7P/8/8/8/2B1K3 w - - 0 1", normalDrawScale},                // right-coloured bishop
+		{"8/8/8/7P/8/8/k7/3BK3 w - - 0 1", normalDrawScale},                 // the king is too far from the corner
+		{"8/8/8/p7/8/8/8/K2bk3 b - - 0 1", 0},                               // wrong-coloured bishop for black
+		{"2b1k3/p7/8/8/8/8/PPP5/2B1K3 w - - 0 1", oppositeBishopsDrawScale}, // opposite-coloured bishops
+	}
+
+	e := NewEngine(nil)
+	for i, test := range tests {
+		board := dragon.ParseFen(test.fen)
+		strong, weak, strongIsWhite := &board.White, &board.Black, true
+		if !board.Wtomove {
+			strong, weak, strongIsWhite = &board.Black, &board.White, false
+		}
+		if scale := e.drawScale(strong, weak, strongIsWhite); scale != test.scale {
+			t.Errorf("Test %d: expected draw scale %d for %s but got %d\n", i, test.scale, test.fen, scale)
+		}
+	}
+
+	board := dragon.ParseFen("4k3/8/8/8/8/8/8/4KN2 w - - 0 1")
+	if eval := e.StaticEval(&board); eval != DrawEval {
+		t.Errorf("Expected a draw eval for insufficient material but got %d\n", eval)
+	}
+}
@@ -0,0 +1,66 @@
+package engine
+
+// Engine state that persists between searches - the hash tables, move history and eval weights.
+// Not safe for concurrent searches - use one instance per search thread.
+type EngineT struct {
+	evalT
+
+	tt       []TTEntryT
+	qtt      []QSearchTTEntryT
+	pawnHash []PawnHashEntryT // nil if the pawn hash is disabled
+
+	// Quiet move ordering history - carried over (decayed) from one search to the next
+	moveHistory *MoveHistoryT
+}
+
+// A new engine with the default eval weights, and the pawn hash configured by opts - nil for the default options
+func NewEngine(opts *OptionsT) *EngineT {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	e := &EngineT{
+		tt:          make([]TTEntryT, TTSize),
+		qtt:         make([]QSearchTTEntryT, QttSize),
+		moveHistory: NewMoveHistory(),
+	}
+	e.setEvalWeights(&DefaultEvalParams)
+	e.configurePawnHash(opts)
+	return e
+}
+
+func (e *EngineT) ResetTT() {
+	e.tt = make([]TTEntryT, TTSize)
+}
+
+func (e *EngineT) ResetQtt() {
+	e.qtt = make([]QSearchTTEntryT, QttSize)
+}
+
+// Forget the quiet move ordering history, e.g. for a new game
+func (e *EngineT) ResetMoveHistory() {
+	e.moveHistory = NewMoveHistory()
+}
+
+// TT occupancy in permill, sampled from the first 1000 entries - for UCI hashfull
+func (e *EngineT) TTHashFull() int {
+	used := 0
+	for i := 0; i < 1000; i++ {
+		if e.tt[i].parityHits[0].evalType != TTInvalid || e.tt[i].parityHits[1].evalType != TTInvalid {
+			used++
+		}
+	}
+	return used
+}
+
+// The eval weights in use
+func (e *EngineT) EvalParams() EvalParamsT {
+	return e.params
+}
+
+// Use the given eval weights - not safe to call concurrently with search.
+// Note that TT entries from previous searches will have been evaluated with the old weights.
+// The pawn hash is cleared since it caches pawn structure evals.
+func (e *EngineT) SetEvalParams(p *EvalParamsT) {
+	e.setEvalWeights(p)
+	e.ResetPawnHash()
+}
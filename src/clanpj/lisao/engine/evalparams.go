@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Eval weights, loadable from JSON. Each weight has a middle-game and an end-game value, interpolated by game phase.
+// Tables are from white's perspective with index 0 being A1.
+type EvalParamsT struct {
+	PieceVals        [7]EvalCp // indexed by dragon piece type
+	PieceEndgameVals [7]EvalCp
+
+	PawnPosVals          [64]int8
+	KnightPosVals        [64]int8
+	BishopPosVals        [64]int8
+	RookPosVals          [64]int8
+	QueenPosVals         [64]int8
+	KingPosVals          [64]int8
+	PawnEndgamePosVals   [64]int8
+	KnightEndgamePosVals [64]int8
+	BishopEndgamePosVals [64]int8
+	RookEndgamePosVals   [64]int8
+	QueenEndgamePosVals  [64]int8
+	KingEndgamePosVals   [64]int8
+
+	PassedPawnVals        [8]int8 // by rank
+	PassedPawnEndgameVals [8]int8
+
+	PawnProtectsPawnVal         int
+	PawnProtectsPawnEndgameVal  int
+	PawnProtectsPieceVal        int
+	PawnProtectsPieceEndgameVal int
+	DoubledPawnPenalty          int
+	DoubledPawnEndgamePenalty   int
+
+	// King safety only has a middle-game value
+	KingAttackWeights       [7]int   // per king zone square attacked, indexed by dragon piece type
+	KingSafetyTable         [100]int // king danger by attack units
+	PawnShieldVals          [2]int   // by distance in front of the king
+	PawnStormPenalty        int
+	KingOpenFilePenalty     int
+	KingSemiOpenFilePenalty int
+
+	MobilityVals        [7]int // per safe square attacked, indexed by dragon piece type
+	MobilityEndgameVals [7]int
+
+	RookOpenFileVal            int
+	RookOpenFileEndgameVal     int
+	RookSemiOpenFileVal        int
+	RookSemiOpenFileEndgameVal int
+	RookSeventhRankVal         int
+	RookSeventhRankEndgameVal  int
+	BishopPairVal              int
+	BishopPairEndgameVal       int
+	KnightOutpostVal           int
+	KnightOutpostEndgameVal    int
+	BishopOutpostVal           int
+	BishopOutpostEndgameVal    int
+
+	// Search pruning margins by depth to go - see pruning.go
+	FutilityMargins        [MaxFrontierPruningDepth + 1]int
+	ReverseFutilityMargins [MaxFrontierPruningDepth + 1]int
+	RazoringMargins        [MaxFrontierPruningDepth + 1]int
+}
+
+// The built-in eval weights
+var DefaultEvalParams = builtInEvalParams()
+
+// The eval weights from the package vars in evaluate.go and friends. The eval never reads those directly, it only
+// reads the per-instance weights in evalT.
+func builtInEvalParams() EvalParamsT {
+	p := EvalParamsT{
+		PieceVals:                   pieceVals,
+		PieceEndgameVals:            pieceEndgameVals,
+		PawnPosVals:                 whitePawnPosVals,
+		KnightPosVals:               whiteKnightPosVals,
+		BishopPosVals:               whiteBishopPosVals,
+		RookPosVals:                 whiteRookPosVals,
+		QueenPosVals:                whiteQueenPosVals,
+		KingPosVals:                 whiteKingPosVals,
+		PawnEndgamePosVals:          whitePawnEndgamePosVals,
+		KnightEndgamePosVals:        whiteKnightEndgamePosVals,
+		BishopEndgamePosVals:        whiteBishopEndgamePosVals,
+		RookEndgamePosVals:          whiteRookEndgamePosVals,
+		QueenEndgamePosVals:         whiteQueenEndgamePosVals,
+		KingEndgamePosVals:          whiteKingEndgamePosVals,
+		PawnProtectsPawnVal:         pProtPawnVal,
+		PawnProtectsPawnEndgameVal:  pProtPawnEndgameVal,
+		PawnProtectsPieceVal:        pProtPieceVal,
+		PawnProtectsPieceEndgameVal: pProtPieceEndgameVal,
+		DoubledPawnPenalty:          doubledPawnPenalty,
+		DoubledPawnEndgamePenalty:   doubledPawnEndgamePenalty,
+		KingAttackWeights:           kingAttackWeights,
+		KingSafetyTable:             kingSafetyTable,
+		PawnShieldVals:              pawnShieldVals,
+		PawnStormPenalty:            pawnStormPenalty,
+		KingOpenFilePenalty:         kingOpenFilePenalty,
+		KingSemiOpenFilePenalty:     kingSemiOpenFilePenalty,
+		MobilityVals:                mobilityVals,
+		MobilityEndgameVals:         mobilityEndgameVals,
+		RookOpenFileVal:             rookOpenFileVal,
+		RookOpenFileEndgameVal:      rookOpenFileEndgameVal,
+		RookSemiOpenFileVal:         rookSemiOpenFileVal,
+		RookSemiOpenFileEndgameVal:  rookSemiOpenFileEndgameVal,
+		RookSeventhRankVal:          rookSeventhRankVal,
+		RookSeventhRankEndgameVal:   rookSeventhRankEndgameVal,
+		BishopPairVal:               bishopPairVal,
+		BishopPairEndgameVal:        bishopPairEndgameVal,
+		KnightOutpostVal:            knightOutpostVal,
+		KnightOutpostEndgameVal:     knightOutpostEndgameVal,
+		BishopOutpostVal:            bishopOutpostVal,
+		BishopOutpostEndgameVal:     bishopOutpostEndgameVal,
+		FutilityMargins:             futilityMargins,
+		ReverseFutilityMargins:      reverseFutilityMargins,
+		RazoringMargins:             razoringMargins,
+	}
+	for rank := 0; rank < 8; rank++ {
+		p.PassedPawnVals[rank] = whitePassedPawnPosVals[8*rank]
+		p.PassedPawnEndgameVals[rank] = whitePassedPawnEndgamePosVals[8*rank]
+	}
+	return p
+}
+
+// The eval weights of an engine instance in the form the eval uses them - piece-square tables for both colours
+type evalT struct {
+	params EvalParamsT // as set
+
+	pieceVals        [7]EvalCp
+	pieceEndgameVals [7]EvalCp
+
+	// Indexed by dragon piece type - the tables for nothing are all zero
+	whitePiecePosVals        [7][64]int8
+	whitePieceEndgamePosVals [7][64]int8
+	blackPiecePosVals        [7][64]int8
+	blackPieceEndgamePosVals [7][64]int8
+
+	whitePassedPawnPosVals        [64]int8
+	whitePassedPawnEndgamePosVals [64]int8
+	blackPassedPawnPosVals        [64]int8
+	blackPassedPawnEndgamePosVals [64]int8
+
+	pProtPawnVal, pProtPawnEndgameVal             int
+	pProtPieceVal, pProtPieceEndgameVal           int
+	doubledPawnPenalty, doubledPawnEndgamePenalty int
+
+	kingAttackWeights                            [7]int
+	kingSafetyTable                              [100]int
+	pawnShieldVals                               [2]int
+	pawnStormPenalty                             int
+	kingOpenFilePenalty, kingSemiOpenFilePenalty int
+
+	mobilityVals, mobilityEndgameVals                        [7]int
+	rookOpenFileVal, rookOpenFileEndgameVal                  int
+	rookSemiOpenFileVal, rookSemiOpenFileEndgameVal          int
+	rookSeventhRankVal, rookSeventhRankEndgameVal            int
+	bishopPairVal, bishopPairEndgameVal                      int
+	knightOutpostVal, knightOutpostEndgameVal                int
+	bishopOutpostVal, bishopOutpostEndgameVal                int
+	futilityMargins, reverseFutilityMargins, razoringMargins [MaxFrontierPruningDepth + 1]int
+}
+
+func (ev *evalT) setEvalWeights(p *EvalParamsT) {
+	ev.params = *p
+	ev.pieceVals = p.PieceVals
+	ev.pieceEndgameVals = p.PieceEndgameVals
+
+	posVals := [7]*[64]int8{nil, &p.PawnPosVals, &p.KnightPosVals, &p.BishopPosVals, &p.RookPosVals, &p.QueenPosVals, &p.KingPosVals}
+	endgamePosVals := [7]*[64]int8{nil, &p.PawnEndgamePosVals, &p.KnightEndgamePosVals, &p.BishopEndgamePosVals, &p.RookEndgamePosVals, &p.QueenEndgamePosVals, &p.KingEndgamePosVals}
+	for piece := dragon.Pawn; piece <= dragon.King; piece++ {
+		setPosVals(&ev.whitePiecePosVals[piece], &ev.blackPiecePosVals[piece], posVals[piece])
+		setPosVals(&ev.whitePieceEndgamePosVals[piece], &ev.blackPieceEndgamePosVals[piece], endgamePosVals[piece])
+	}
+
+	setRankVals(&ev.whitePassedPawnPosVals, &ev.blackPassedPawnPosVals, &p.PassedPawnVals)
+	setRankVals(&ev.whitePassedPawnEndgamePosVals, &ev.blackPassedPawnEndgamePosVals, &p.PassedPawnEndgameVals)
+
+	ev.pProtPawnVal, ev.pProtPawnEndgameVal = p.PawnProtectsPawnVal, p.PawnProtectsPawnEndgameVal
+	ev.pProtPieceVal, ev.pProtPieceEndgameVal = p.PawnProtectsPieceVal, p.PawnProtectsPieceEndgameVal
+	ev.doubledPawnPenalty, ev.doubledPawnEndgamePenalty = p.DoubledPawnPenalty, p.DoubledPawnEndgamePenalty
+
+	ev.kingAttackWeights, ev.kingSafetyTable = p.KingAttackWeights, p.KingSafetyTable
+	ev.pawnShieldVals, ev.pawnStormPenalty = p.PawnShieldVals, p.PawnStormPenalty
+	ev.kingOpenFilePenalty, ev.kingSemiOpenFilePenalty = p.KingOpenFilePenalty, p.KingSemiOpenFilePenalty
+
+	ev.mobilityVals, ev.mobilityEndgameVals = p.MobilityVals, p.MobilityEndgameVals
+	ev.rookOpenFileVal, ev.rookOpenFileEndgameVal = p.RookOpenFileVal, p.RookOpenFileEndgameVal
+	ev.rookSemiOpenFileVal, ev.rookSemiOpenFileEndgameVal = p.RookSemiOpenFileVal, p.RookSemiOpenFileEndgameVal
+	ev.rookSeventhRankVal, ev.rookSeventhRankEndgameVal = p.RookSeventhRankVal, p.RookSeventhRankEndgameVal
+	ev.bishopPairVal, ev.bishopPairEndgameVal = p.BishopPairVal, p.BishopPairEndgameVal
+	ev.knightOutpostVal, ev.knightOutpostEndgameVal = p.KnightOutpostVal, p.KnightOutpostEndgameVal
+	ev.bishopOutpostVal, ev.bishopOutpostEndgameVal = p.BishopOutpostVal, p.BishopOutpostEndgameVal
+
+	ev.futilityMargins, ev.reverseFutilityMargins, ev.razoringMargins = p.FutilityMargins, p.ReverseFutilityMargins, p.RazoringMargins
+}
+
+// The black table is the white table with the ranks mirrored
+func setPosVals(white *[64]int8, black *[64]int8, posVals *[64]int8) {
+	*white = *posVals
+	for sq := range posVals {
+		black[sq^56] = posVals[sq]
+	}
+}
+
+// Expand values by rank to piece-square tables
+func setRankVals(white *[64]int8, black *[64]int8, rankVals *[8]int8) {
+	var posVals [64]int8
+	for sq := range posVals {
+		posVals[sq] = rankVals[sq/8]
+	}
+	setPosVals(white, black, &posVals)
+}
+
+// Load eval weights from a JSON file - any weights missing from the file take their default values
+func LoadEvalParams(path string) (EvalParamsT, error) {
+	p := DefaultEvalParams
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
+
+func SaveEvalParams(path string, p *EvalParamsT) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
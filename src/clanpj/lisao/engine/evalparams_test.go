@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+func TestEvalParamsJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "evalparams")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := DefaultEvalParams
+	p.PieceVals[2] = 325
+	p.KnightPosVals[1] = -50
+	path := filepath.Join(dir, "params.json")
+	if err := SaveEvalParams(path, &p); err != nil {
+		t.Fatalf("SaveEvalParams failed: %v", err)
+	}
+	loaded, err := LoadEvalParams(path)
+	if err != nil {
+		t.Fatalf("LoadEvalParams failed: %v", err)
+	}
+	if loaded != p {
+		t.Errorf("Loaded params differ from saved params\n")
+	}
+
+	// Missing weights take their default values
+	partial := filepath.Join(dir, "partial.json")
+	if err := ioutil.WriteFile(partial, []byte(`{"DoubledPawnPenalty": -20}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = LoadEvalParams(partial)
+	if err != nil {
+		t.Fatalf("LoadEvalParams failed: %v", err)
+	}
+	expected := DefaultEvalParams
+	expected.DoubledPawnPenalty = -20
+	if loaded != expected {
+		t.Errorf("Partial params were not merged with the defaults\n")
+	}
+
+	// Black tables are mirrored
+	e := NewEngine(nil)
+	e.SetEvalParams(&p)
+	if e.blackPiecePosVals[dragon.Knight][57] != -50 || e.pieceVals[dragon.Knight] != 325 {
+		t.Errorf("Black knight b8 pos val is %d and knight val %d expected -50 and 325\n", e.blackPiecePosVals[dragon.Knight][57], e.pieceVals[dragon.Knight])
+	}
+
+	// Each engine has its own weights
+	if other := NewEngine(nil); other.EvalParams() != DefaultEvalParams || other.pieceVals[dragon.Knight] != DefaultEvalParams.PieceVals[dragon.Knight] {
+		t.Errorf("Setting the eval params of one engine changed another\n")
+	}
+}
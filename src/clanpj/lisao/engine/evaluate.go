@@ -22,33 +22,28 @@ const InvalidEval EvalCp = math.MinInt16
 
 const DrawEval EvalCp = 0
 
-// Piece values
-const nothingVal = 0
-const pawnVal = 100
-const knightVal = 300
-const bishopVal = 300
-const rookVal = 500
-const queenVal = 900
-const kingVal = 0
+// Tablebase wins are worse than any checkmate found in search but better than any static eval
+const TBWinEval EvalCp = MyCheckMateEval - 2*MaxDepth
 
+// Built-in piece values - each engine has its own copy of the eval weights, see evalparams.go
 var pieceVals = [7]EvalCp{
-	nothingVal,
-	pawnVal,
-	knightVal,
-	bishopVal,
-	rookVal,
-	queenVal,
-	kingVal}
-
-var nothingPosVals = [64]int8{
-	0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0}
+	0,   // nothing
+	100, // pawn
+	300, // knight
+	300, // bishop
+	500, // rook
+	900, // queen
+	0}   // king
+
+// Pawns are worth more in the end-game, where they can promote, and knights a bit less
+var pieceEndgameVals = [7]EvalCp{
+	0,   // nothing
+	120, // pawn
+	290, // knight
+	310, // bishop
+	530, // rook
+	950, // queen
+	0}   // king
 
 // Stolen from SunFish (tables inverted to reflect dragon pos ordering)
 var whitePawnPosVals = [64]int8{
@@ -122,99 +117,60 @@ var whiteKingEndgamePosVals = [64]int8{
 	-30, -20, -10, 0, 0, -10, -20, -30,
 	-50, -40, -30, -20, -20, -30, -40, -50}
 
-var whitePiecePosVals = [7]*[64]int8{
-	&nothingPosVals,
-	&whitePawnPosVals,
-	&whiteKnightPosVals,
-	&whiteBishopPosVals,
-	&whiteRookPosVals,
-	&whiteQueenPosVals,
-	&whiteKingPosVals}
-
-// Stolen from SunFish
-var blackPawnPosVals = [64]int8{
+// Hand-crafted end-game tables - pawns get more valuable as they advance and the other pieces prefer the centre
+var whitePawnEndgamePosVals = [64]int8{
 	0, 0, 0, 0, 0, 0, 0, 0,
-	78, 83, 86, 73, 102, 82, 85, 90,
-	7, 29, 21, 44, 40, 31, 44, 7,
-	-17, 16, -2, 15, 14, 0, 15, -13,
-	-26, 3, 10, 9, 6, 1, 0, -23,
-	-22, 9, 5, -11, -10, -2, 3, -19,
-	-31, 8, -7, -37, -36, -14, 3, -31,
+	0, 0, 0, 0, 0, 0, 0, 0,
+	5, 5, 5, 5, 5, 5, 5, 5,
+	10, 10, 10, 10, 10, 10, 10, 10,
+	20, 20, 20, 20, 20, 20, 20, 20,
+	35, 35, 35, 35, 35, 35, 35, 35,
+	120, 120, 120, 120, 120, 120, 120, 120,
 	0, 0, 0, 0, 0, 0, 0, 0}
 
-var blackKnightPosVals = [64]int8{
-	-66, -53, -75, -75, -10, -55, -58, -70,
-	-3, -6, 100, -36, 4, 62, -4, -14,
-	10, 67, 1, 74, 73, 27, 62, -2,
-	24, 24, 45, 37, 33, 41, 25, 17,
-	-1, 5, 31, 21, 22, 35, 2, 0,
-	-18, 10, 13, 22, 18, 15, 11, -14,
-	-23, -15, 2, 0, 2, 0, -23, -20,
-	-74, -23, -26, -24, -19, -35, -22, -69}
-
-var blackBishopPosVals = [64]int8{
-	-59, -78, -82, -76, -23, -107, -37, -50,
-	-11, 20, 35, -42, -39, 31, 2, -22,
-	-9, 39, -32, 41, 52, -10, 28, -14,
-	25, 17, 20, 34, 26, 25, 15, 10,
-	13, 10, 17, 23, 17, 16, 0, 7,
-	14, 25, 24, 15, 8, 25, 20, 15,
-	19, 20, 11, 6, 7, 6, 20, 16,
-	-7, 2, -15, -12, -14, -15, -10, -10}
-
-var blackRookPosVals = [64]int8{
-	35, 29, 33, 4, 37, 33, 56, 50,
-	55, 29, 56, 67, 55, 62, 34, 60,
-	19, 35, 28, 33, 45, 27, 25, 15,
-	0, 5, 16, 13, 18, -4, -9, -6,
-	-28, -35, -16, -21, -13, -29, -46, -30,
-	-42, -28, -42, -25, -25, -35, -26, -46,
-	-53, -38, -31, -26, -29, -43, -44, -53,
-	-30, -24, -18, 5, -2, -18, -31, -32}
-
-var blackQueenPosVals = [64]int8{
-	6, 1, -8, -104, 69, 24, 88, 26,
-	14, 32, 60, -10, 20, 76, 57, 24,
-	-2, 43, 32, 60, 72, 63, 43, 2,
-	1, -16, 22, 17, 25, 20, -13, -6,
-	-14, -15, -2, -5, -1, -10, -20, -22,
-	-30, -6, -13, -11, -16, -11, -16, -27,
-	-36, -18, 0, -19, -15, -15, -21, -38,
-	-39, -30, -31, -13, -31, -36, -34, -42}
-
-var blackKingPosVals = [64]int8{
-	4, 54, 47, -99, -99, 60, 83, -62,
-	-32, 10, 55, 56, 56, 55, 10, 3,
-	-62, 12, -57, 44, -67, 28, 37, -31,
-	-55, 50, 11, -4, -19, 13, 0, -49,
-	-55, -43, -52, -28, -51, -47, -8, -50,
-	-47, -42, -43, -79, -64, -32, -29, -32,
-	-4, 3, -14, -50, -57, -18, 13, 4,
-	17, 30, -3, -14, 6, -1, 40, 18}
-
-// From - https://chessprogramming.wikispaces.com/Simplified+evaluation+function
-var blackKingEndgamePosVals = [64]int8{
-	-50, -40, -30, -20, -20, -30, -40, -50,
-	-30, -20, -10, 0, 0, -10, -20, -30,
-	-30, -10, 20, 30, 30, 20, -10, -30,
-	-30, -10, 30, 40, 40, 30, -10, -30,
-	-30, -10, 30, 40, 40, 30, -10, -30,
-	-30, -10, 20, 30, 30, 20, -10, -30,
-	-30, -30, 0, 0, 0, 0, -30, -30,
-	-50, -30, -30, -30, -30, -30, -30, -50}
+var whiteKnightEndgamePosVals = [64]int8{
+	-50, -40, -30, -30, -30, -30, -40, -50,
+	-40, -20, 0, 0, 0, 0, -20, -40,
+	-30, 0, 10, 15, 15, 10, 0, -30,
+	-30, 0, 15, 20, 20, 15, 0, -30,
+	-30, 0, 15, 20, 20, 15, 0, -30,
+	-30, 0, 10, 15, 15, 10, 0, -30,
+	-40, -20, 0, 0, 0, 0, -20, -40,
+	-50, -40, -30, -30, -30, -30, -40, -50}
+
+var whiteBishopEndgamePosVals = [64]int8{
+	-20, -10, -10, -10, -10, -10, -10, -20,
+	-10, 0, 0, 0, 0, 0, 0, -10,
+	-10, 0, 5, 10, 10, 5, 0, -10,
+	-10, 0, 10, 15, 15, 10, 0, -10,
+	-10, 0, 10, 15, 15, 10, 0, -10,
+	-10, 0, 5, 10, 10, 5, 0, -10,
+	-10, 0, 0, 0, 0, 0, 0, -10,
+	-20, -10, -10, -10, -10, -10, -10, -20}
+
+var whiteRookEndgamePosVals = [64]int8{
+	0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0,
+	10, 10, 10, 10, 10, 10, 10, 10,
+	0, 0, 0, 0, 0, 0, 0, 0}
 
-var blackPiecePosVals = [7]*[64]int8{
-	&nothingPosVals,
-	&blackPawnPosVals,
-	&blackKnightPosVals,
-	&blackBishopPosVals,
-	&blackRookPosVals,
-	&blackQueenPosVals,
-	&blackKingPosVals}
+var whiteQueenEndgamePosVals = [64]int8{
+	-20, -10, -10, -5, -5, -10, -10, -20,
+	-10, 0, 0, 0, 0, 0, 0, -10,
+	-10, 0, 5, 5, 5, 5, 0, -10,
+	-5, 0, 5, 10, 10, 5, 0, -5,
+	-5, 0, 5, 10, 10, 5, 0, -5,
+	-10, 0, 5, 5, 5, 5, 0, -10,
+	-10, 0, 0, 0, 0, 0, 0, -10,
+	-20, -10, -10, -5, -5, -10, -10, -20}
 
 // Static eval only - no mate checks - from the perspective of the player to move
-func NegaStaticEval(board *dragon.Board) EvalCp {
-	staticEval := StaticEval(board)
+func (e *EngineT) NegaStaticEval(board *dragon.Board, stats *SearchStatsT) EvalCp {
+	staticEval := e.staticEval(board, stats)
 
 	if board.Wtomove {
 		return staticEval
@@ -223,77 +179,68 @@ func NegaStaticEval(board *dragon.Board) EvalCp {
 }
 
 // Static eval only - no mate checks - from white's perspective
-func StaticEval(board *dragon.Board) EvalCp {
-	whitePiecesEval := piecesEval(&board.White)
-	blackPiecesEval := piecesEval(&board.Black)
-
-	piecesEval := whitePiecesEval - blackPiecesEval
-
-	endGameRatio := EndGameRatio(whitePiecesEval + blackPiecesEval)
-
-	whitePiecesPosEval := piecesPosVal(&board.White, &whitePiecePosVals, &whiteKingEndgamePosVals, endGameRatio)
-	blackPiecesPosEval := piecesPosVal(&board.Black, &blackPiecePosVals, &blackKingEndgamePosVals, endGameRatio)
+// Every eval term has a middle-game and an end-game value, and we interpolate between them by game phase.
+func (e *EngineT) StaticEval(board *dragon.Board) EvalCp {
+	return e.staticEval(board, nil)
+}
 
-	pawnExtrasEval := pawnExtrasVal(board)
-	kingProtectionEval := kingProtectionVal(board, endGameRatio)
+// Static eval with pawn hash stats - stats may be nil
+func (e *EngineT) staticEval(board *dragon.Board, stats *SearchStatsT) EvalCp {
+	pawns := e.probePawnHash(board, stats)
 
-	piecesPosEval := whitePiecesPosEval - blackPiecesPosEval + pawnExtrasEval + kingProtectionEval
+	whitePiecesEval, whitePiecesEndgameEval := e.piecesEval(&board.White, &e.whitePiecePosVals, &e.whitePieceEndgamePosVals)
+	blackPiecesEval, blackPiecesEndgameEval := e.piecesEval(&board.Black, &e.blackPiecePosVals, &e.blackPieceEndgamePosVals)
 
-	return piecesEval + piecesPosEval
-}
+	pawnExtrasEval, pawnExtrasEndgameEval := e.pawnExtrasVal(board, &pawns)
+	// King safety is irrelevant in the end-game, so it only has a middle-game value
+	kingSafetyEval := e.kingSafetyVal(board, &pawns)
+	activityEval, activityEndgameEval := e.activityVal(board)
 
-// Sum of individual piece evals
-func piecesEval(bitboards *dragon.Bitboards) EvalCp {
-	eval := pawnVal * bits.OnesCount64(bitboards.Pawns)
-	eval += bishopVal * bits.OnesCount64(bitboards.Bishops)
-	eval += knightVal * bits.OnesCount64(bitboards.Knights)
-	eval += rookVal * bits.OnesCount64(bitboards.Rooks)
-	eval += queenVal * bits.OnesCount64(bitboards.Queens)
+	eval := whitePiecesEval - blackPiecesEval + pawnExtrasEval + kingSafetyEval + activityEval
+	endgameEval := whitePiecesEndgameEval - blackPiecesEndgameEval + pawnExtrasEndgameEval + activityEndgameEval
 
-	return EvalCp(eval)
+	return e.drawScaledEval(board, taperedEval(eval, endgameEval, GamePhase(board)))
 }
 
-// Transition smoothly from King starting pos table to king end-game table between these total piece values.
-// Note these are totals of black and white pieces.
-const EndGamePiecesValHi EvalCp = 6000
-const EndGamePiecesValLo EvalCp = 2400
-
-// TODO delta eval doesn't cope with end-game-aware king eval
-const NeverInEndgame = true
+// Game phase contribution of each piece type - pawns don't count
+var piecePhases = [7]int{0, 0, 1, 1, 2, 4, 0}
 
-// To what extent are we in end game; from 0.0 (not at all) to 1.0 (definitely)
-func EndGameRatio(bAndWPiecesVal EvalCp) float64 {
-	if NeverInEndgame {
-		return 0.0
-	}
+// Game phase with all of the non-pawn pieces on the board
+const MaxGamePhase = 24
 
-	// Somewhat arbitrary
-	if bAndWPiecesVal > EndGamePiecesValHi {
-		return 0.0
-	}
+// Game phase from the remaining non-pawn material; from MaxGamePhase (start of game) down to 0 (pawn end-game)
+func GamePhase(board *dragon.Board) int {
+	phase := piecePhases[dragon.Knight]*bits.OnesCount64(board.White.Knights|board.Black.Knights) +
+		piecePhases[dragon.Bishop]*bits.OnesCount64(board.White.Bishops|board.Black.Bishops) +
+		piecePhases[dragon.Rook]*bits.OnesCount64(board.White.Rooks|board.Black.Rooks) +
+		piecePhases[dragon.Queen]*bits.OnesCount64(board.White.Queens|board.Black.Queens)
 
-	if bAndWPiecesVal < EndGamePiecesValLo {
-		return 1.0
+	// Promotions can take us past the starting material
+	if phase > MaxGamePhase {
+		return MaxGamePhase
 	}
+	return phase
+}
 
-	return float64(EndGamePiecesValHi-bAndWPiecesVal) / float64(EndGamePiecesValHi-EndGamePiecesValLo)
+// Interpolate between the middle-game and end-game evals
+func taperedEval(eval EvalCp, endgameEval EvalCp, phase int) EvalCp {
+	return EvalCp((int(eval)*phase + int(endgameEval)*(MaxGamePhase-phase)) / MaxGamePhase)
 }
 
-// Sum of piece position values
-//   endGameRatio is a number between 0.0 and 1.0 where 1.0 means we're in end-game
-func piecesPosVal(bitboards *dragon.Bitboards, piecePosVals *[7]*[64]int8, kingEndgamePosVals *[64]int8, endGameRatio float64) EvalCp {
-	eval := pieceTypePiecesPosVal(bitboards.Pawns, piecePosVals[dragon.Pawn])
-	eval += pieceTypePiecesPosVal(bitboards.Bishops, piecePosVals[dragon.Bishop])
-	eval += pieceTypePiecesPosVal(bitboards.Knights, piecePosVals[dragon.Knight])
-	eval += pieceTypePiecesPosVal(bitboards.Rooks, piecePosVals[dragon.Rook])
-	eval += pieceTypePiecesPosVal(bitboards.Queens, piecePosVals[dragon.Queen])
+// Sum of piece values and piece position values - middle-game and end-game
+func (ev *evalT) piecesEval(bitboards *dragon.Bitboards, piecePosVals *[7][64]int8, pieceEndgamePosVals *[7][64]int8) (EvalCp, EvalCp) {
+	pieceBbs := [7]uint64{0, bitboards.Pawns, bitboards.Knights, bitboards.Bishops, bitboards.Rooks, bitboards.Queens, bitboards.Kings}
 
-	kingStartEval := pieceTypePiecesPosVal(bitboards.Kings, piecePosVals[dragon.King])
-	kingEndgameEval := pieceTypePiecesPosVal(bitboards.Kings, kingEndgamePosVals)
+	var eval, endgameEval EvalCp
+	for piece := dragon.Pawn; piece <= dragon.King; piece++ {
+		bitmask := pieceBbs[piece]
+		nPieces := EvalCp(bits.OnesCount64(bitmask))
 
-	kingEval := (1.0-endGameRatio)*float64(kingStartEval) + endGameRatio*float64(kingEndgameEval)
+		eval += nPieces*ev.pieceVals[piece] + pieceTypePiecesPosVal(bitmask, &piecePosVals[piece])
+		endgameEval += nPieces*ev.pieceEndgameVals[piece] + pieceTypePiecesPosVal(bitmask, &pieceEndgamePosVals[piece])
+	}
 
-	return eval + EvalCp(kingEval)
+	return eval, endgameEval
 }
 
 // Sum of piece position values for a particular type of piece
@@ -330,152 +277,43 @@ var whitePassedPawnPosVals = [64]int8{
 	0, 0, 0, 0, 0, 0, 0, 0, // a 7th rank pawn is always passed, so covered by the pawn-pos-val
 	0, 0, 0, 0, 0, 0, 0, 0}
 
-var blackPassedPawnPosVals = [64]int8{
+// Passed pawns are much more dangerous in the end-game
+var whitePassedPawnEndgamePosVals = [64]int8{
 	0, 0, 0, 0, 0, 0, 0, 0,
+	10, 10, 10, 10, 10, 10, 10, 10,
+	15, 15, 15, 15, 15, 15, 15, 15,
+	25, 25, 25, 25, 25, 25, 25, 25,
+	40, 40, 40, 40, 40, 40, 40, 40,
+	60, 60, 60, 60, 60, 60, 60, 60,
 	0, 0, 0, 0, 0, 0, 0, 0, // a 7th rank pawn is always passed, so covered by the pawn-pos-val
-	pp6, pp6, pp6, pp6, pp6, pp6, pp6, pp6,
-	pp5, pp5, pp5, pp5, pp5, pp5, pp5, pp5,
-	pp4, pp4, pp4, pp4, pp4, pp4, pp4, pp4,
-	pp3, pp3, pp3, pp3, pp3, pp3, pp3, pp3,
-	pp2, pp2, pp2, pp2, pp2, pp2, pp2, pp2,
 	0, 0, 0, 0, 0, 0, 0, 0}
 
 // Bonus for pawns protecting pawns
-const pProtPawnVal = 10
+var pProtPawnVal = 10
+var pProtPawnEndgameVal = 15
 
 // Bonus for pawns protecting pieces
-const pProtPieceVal = 7
+var pProtPieceVal = 7
+var pProtPieceEndgameVal = 3
 
 // Penalty per doubled pawn
-const doubledPawnPenalty = -15
-
-// Pawn extras
-func pawnExtrasVal(board *dragon.Board) EvalCp {
-	wPawns := board.White.Pawns
-	bPawns := board.Black.Pawns
-
-	// Passed pawns
-	wPawnScope := WPawnScope(wPawns)
-	bPawnScope := BPawnScope(wPawns)
-
-	wPassedPawns := wPawns & ^bPawnScope
-	bPassedPawns := bPawns & ^wPawnScope
-
-	wPPVal := pieceTypePiecesPosVal(wPassedPawns, &whitePassedPawnPosVals)
-	bPPVal := pieceTypePiecesPosVal(bPassedPawns, &blackPassedPawnPosVals)
-
-	// Pawns protected by pawns
-	wPawnAtt := WPawnAttacks(wPawns)
-	wPawnsProtectedByPawns := wPawnAtt & wPawns
-	wPProtPawnsVal := bits.OnesCount64(wPawnsProtectedByPawns) * pProtPawnVal
-
-	bPawnAtt := BPawnAttacks(bPawns)
-	bPawnsProtectedByPawns := bPawnAtt & bPawns
-	bPProtPawnsVal := bits.OnesCount64(bPawnsProtectedByPawns) * pProtPawnVal
+var doubledPawnPenalty = -15
+var doubledPawnEndgamePenalty = -25
 
+// Pawn extras - middle-game and end-game
+// The pawn-only terms come from the pawn hash - see pawnStructure()
+func (ev *evalT) pawnExtrasVal(board *dragon.Board, pawns *PawnHashEntryT) (EvalCp, EvalCp) {
 	// Pieces protected by pawns
-	wPieces := board.White.All & ^wPawns
-	wPiecesProtectedByPawns := wPawnAtt & wPieces
-	wPProtPiecesVal := bits.OnesCount64(wPiecesProtectedByPawns) * pProtPieceVal
-
-	bPieces := board.Black.All & ^bPawns
-	bPiecesProtectedByPawns := bPawnAtt & bPieces
-	bPProtPiecesVal := bits.OnesCount64(bPiecesProtectedByPawns) * pProtPieceVal
-
-	// Doubled pawns
-	wPawnTelestop := NFill(N(wPawns))
-	wDoubledPawns := wPawnTelestop & wPawns
-	wDoubledPawnVal := bits.OnesCount64(wDoubledPawns) * doubledPawnPenalty
-
-	bPawnTelestop := SFill(S(bPawns))
-	bDoubledPawns := bPawnTelestop & bPawns
-	bDoubledPawnVal := bits.OnesCount64(bDoubledPawns) * doubledPawnPenalty
-
-	return (wPPVal - bPPVal) +
-		EvalCp(wPProtPawnsVal-bPProtPawnsVal) +
-		EvalCp(wPProtPiecesVal-bPProtPiecesVal) +
-		EvalCp(wDoubledPawnVal-bDoubledPawnVal)
-}
-
-type KingProtectionT uint8
-
-const (
-	NoProtection KingProtectionT = iota
-	QSideProtection
-	KSideProtection
-)
-
-// Which white king positions qualify for protection eval - index 0 is square A1, index 63 is square H8
-var wKingProtectionTypes = [64]KingProtectionT{
-	QSideProtection, QSideProtection, QSideProtection, NoProtection, NoProtection, NoProtection, KSideProtection, KSideProtection,
-	QSideProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, KSideProtection,
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection,
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection,
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection,
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection,
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection,
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection}
-
-// Bitboard locations of white king protecting pieces indexes by protection type
-var wKingProtectionBbs = [3]uint64{
-	0x0,                // NoProtection
-	0x0007070000000000, // QSideProtection
-	0x00e0e00000000000} // KSideProtection
-
-// Which black king positions qualify for protection eval
-var bKingProtectionTypes = [64]KingProtectionT{
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection,
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection,
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection,
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection,
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection,
-	NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection,
-	QSideProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, NoProtection, KSideProtection,
-	QSideProtection, QSideProtection, QSideProtection, NoProtection, NoProtection, NoProtection, KSideProtection, KSideProtection}
-
-// Bitboard locations of black king protecting pieces indexes by protection type
-var bKingProtectionBbs = [3]uint64{
-	0x0,                // NoProtection
-	0x0000000000070700, // QSideProtection
-	0x0000000000e0e000} // KSideProtection
-
-// Bonus for pieces that are protecting the king
-const kingProtectorVal = 8
-
-// Additional bonus for pawns that are protecting the king
-const kingPawnProtectorVal = 11
-
-// Naive king protection - count pieces around the king if the king is in the corner
-// From White's perspective
-func kingProtectionVal(board *dragon.Board, endGameRatio float64) EvalCp {
-	if endGameRatio == 1.0 {
-		return 0
-	}
-
-	wBbs := board.White
-	wKingPos := bits.TrailingZeros64(wBbs.Kings)
-	wKingProtectionType := wKingProtectionTypes[wKingPos]
-	wKingProtectionBb := wKingProtectionBbs[wKingProtectionType]
-
-	wNonKingPieces := wBbs.All & ^wBbs.Kings
-	wKingProtectors := wNonKingPieces & wKingProtectionBb
-
-	wKingPawnProtectors := wBbs.Pawns & wKingProtectionBb
-
-	wKingProtectionVal := bits.OnesCount64(wKingProtectors)*kingProtectorVal + bits.OnesCount64(wKingPawnProtectors)*kingPawnProtectorVal
-
-	bBbs := board.Black
-	bKingPos := bits.TrailingZeros64(bBbs.Kings)
-	bKingProtectionType := bKingProtectionTypes[bKingPos]
-	bKingProtectionBb := bKingProtectionBbs[bKingProtectionType]
+	wPieces := board.White.All & ^board.White.Pawns
+	wPiecesProtectedByPawns := WPawnAttacks(board.White.Pawns) & wPieces
 
-	bNonKingPieces := bBbs.All & ^bBbs.Kings
-	bKingProtectors := bNonKingPieces & bKingProtectionBb
+	bPieces := board.Black.All & ^board.Black.Pawns
+	bPiecesProtectedByPawns := BPawnAttacks(board.Black.Pawns) & bPieces
 
-	bKingPawnProtectors := bBbs.Pawns & bKingProtectionBb
+	nPProtPieces := bits.OnesCount64(wPiecesProtectedByPawns) - bits.OnesCount64(bPiecesProtectedByPawns)
 
-	bKingProtectionVal := bits.OnesCount64(bKingProtectors)*kingProtectorVal + bits.OnesCount64(bKingPawnProtectors)*kingPawnProtectorVal
+	eval := pawns.eval + EvalCp(nPProtPieces*ev.pProtPieceVal)
+	endgameEval := pawns.endgameEval + EvalCp(nPProtPieces*ev.pProtPieceEndgameVal)
 
-	// King protection in end-game is irrelevant
-	return EvalCp(float64(wKingProtectionVal-bKingProtectionVal) * (1.0 - endGameRatio))
+	return eval, endgameEval
 }
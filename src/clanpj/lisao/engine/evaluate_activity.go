@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"math/bits"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Bonus per safe square a piece attacks, by piece type - a square is safe if it's not occupied by our own pieces
+// and is not attacked by enemy pawns
+var mobilityVals = [7]int{0, 0, 4, 5, 2, 1, 0}
+var mobilityEndgameVals = [7]int{0, 0, 4, 5, 4, 2, 0}
+
+// Bonus for rooks on a file with no pawns
+var rookOpenFileVal = 25
+var rookOpenFileEndgameVal = 10
+
+// Bonus for rooks on a file with no pawns of our own
+var rookSemiOpenFileVal = 10
+var rookSemiOpenFileEndgameVal = 5
+
+// Bonus for rooks on the (relative) 7th rank
+var rookSeventhRankVal = 10
+var rookSeventhRankEndgameVal = 20
+
+// Bonus for having both bishops
+var bishopPairVal = 30
+var bishopPairEndgameVal = 50
+
+// Bonus for knights and bishops on outposts - squares in the enemy half protected by our pawns that enemy pawns can never attack
+var knightOutpostVal = 20
+var knightOutpostEndgameVal = 10
+var bishopOutpostVal = 10
+var bishopOutpostEndgameVal = 5
+
+// Ranks 4-6 for white and ranks 3-5 for black
+const wOutpostRanks uint64 = 0x0000ffffff000000
+const bOutpostRanks uint64 = 0x000000ffffff0000
+
+const wSeventhRank uint64 = 0x00ff000000000000
+const bSeventhRank uint64 = 0x000000000000ff00
+
+// Piece activity - mobility, rooks on open files and the 7th rank, the bishop pair, and outposts
+// From White's perspective - middle-game and end-game
+func (ev *evalT) activityVal(board *dragon.Board) (EvalCp, EvalCp) {
+	occupied := board.White.All | board.Black.All
+	allPawns := board.White.Pawns | board.Black.Pawns
+
+	wPawnAtt := WPawnAttacks(board.White.Pawns)
+	bPawnAtt := BPawnAttacks(board.Black.Pawns)
+
+	// Pawns only move forward, so they can never attack squares behind their (future) attacks
+	wOutposts := wOutpostRanks & wPawnAtt & ^SFill(bPawnAtt)
+	bOutposts := bOutpostRanks & bPawnAtt & ^NFill(wPawnAtt)
+
+	wEval, wEndgameEval := ev.sideActivityVal(&board.White, occupied, allPawns, bPawnAtt, wOutposts, wSeventhRank)
+	bEval, bEndgameEval := ev.sideActivityVal(&board.Black, occupied, allPawns, wPawnAtt, bOutposts, bSeventhRank)
+
+	return EvalCp(wEval - bEval), EvalCp(wEndgameEval - bEndgameEval)
+}
+
+// Piece activity for one side
+func (ev *evalT) sideActivityVal(bbs *dragon.Bitboards, occupied uint64, allPawns uint64, enemyPawnAtt uint64, outposts uint64, seventhRank uint64) (int, int) {
+	safe := ^bbs.All & ^enemyPawnAtt
+	eval, endgameEval := 0, 0
+
+	// Knight attacks don't depend on occupancy
+	for knights := bbs.Knights; knights != 0; knights &= knights - 1 {
+		knight := knights & -knights
+		nSafe := bits.OnesCount64(KnightAttacks(knight) & safe)
+		eval += nSafe * ev.mobilityVals[dragon.Knight]
+		endgameEval += nSafe * ev.mobilityEndgameVals[dragon.Knight]
+	}
+
+	for bishops := bbs.Bishops; bishops != 0; bishops &= bishops - 1 {
+		bishop := uint8(bits.TrailingZeros64(bishops))
+		nSafe := bits.OnesCount64(dragon.CalculateBishopMoveBitboard(bishop, occupied) & safe)
+		eval += nSafe * ev.mobilityVals[dragon.Bishop]
+		endgameEval += nSafe * ev.mobilityEndgameVals[dragon.Bishop]
+	}
+
+	for rooks := bbs.Rooks; rooks != 0; rooks &= rooks - 1 {
+		rook := uint8(bits.TrailingZeros64(rooks))
+		nSafe := bits.OnesCount64(dragon.CalculateRookMoveBitboard(rook, occupied) & safe)
+		eval += nSafe * ev.mobilityVals[dragon.Rook]
+		endgameEval += nSafe * ev.mobilityEndgameVals[dragon.Rook]
+
+		rookBb := uint64(1) << rook
+		file := NFill(rookBb) | SFill(rookBb)
+		if file&allPawns == 0 {
+			eval += ev.rookOpenFileVal
+			endgameEval += ev.rookOpenFileEndgameVal
+		} else if file&bbs.Pawns == 0 {
+			eval += ev.rookSemiOpenFileVal
+			endgameEval += ev.rookSemiOpenFileEndgameVal
+		}
+	}
+
+	for queens := bbs.Queens; queens != 0; queens &= queens - 1 {
+		queen := uint8(bits.TrailingZeros64(queens))
+		attacks := dragon.CalculateBishopMoveBitboard(queen, occupied) | dragon.CalculateRookMoveBitboard(queen, occupied)
+		nSafe := bits.OnesCount64(attacks & safe)
+		eval += nSafe * ev.mobilityVals[dragon.Queen]
+		endgameEval += nSafe * ev.mobilityEndgameVals[dragon.Queen]
+	}
+
+	nSeventhRankRooks := bits.OnesCount64(bbs.Rooks & seventhRank)
+	eval += nSeventhRankRooks * ev.rookSeventhRankVal
+	endgameEval += nSeventhRankRooks * ev.rookSeventhRankEndgameVal
+
+	if bits.OnesCount64(bbs.Bishops) >= 2 {
+		eval += ev.bishopPairVal
+		endgameEval += ev.bishopPairEndgameVal
+	}
+
+	nKnightOutposts := bits.OnesCount64(bbs.Knights & outposts)
+	nBishopOutposts := bits.OnesCount64(bbs.Bishops & outposts)
+	eval += nKnightOutposts*ev.knightOutpostVal + nBishopOutposts*ev.bishopOutpostVal
+	endgameEval += nKnightOutposts*ev.knightOutpostEndgameVal + nBishopOutposts*ev.bishopOutpostEndgameVal
+
+	return eval, endgameEval
+}
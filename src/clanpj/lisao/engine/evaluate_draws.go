@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"math/bits"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Likely draws have their eval scaled down by drawScale()/normalDrawScale
+const normalDrawScale = 64
+
+// Opposite-coloured bishop end-games with only pawns left are often drawn even a pawn or two up
+const oppositeBishopsDrawScale = 32
+
+// Scale the eval (from White's perspective) towards a draw if the side that's ahead is unlikely to be able to win.
+// Insufficient material is always a draw.
+func (ev *evalT) drawScaledEval(board *dragon.Board, eval EvalCp) EvalCp {
+	if IsInsufficientMaterial(board) {
+		return DrawEval
+	}
+	var scale int
+	switch {
+	case eval > 0:
+		scale = ev.drawScale(&board.White, &board.Black, true)
+	case eval < 0:
+		scale = ev.drawScale(&board.Black, &board.White, false)
+	default:
+		return eval
+	}
+	return EvalCp(int(eval) * scale / normalDrawScale)
+}
+
+// Scale factor out of normalDrawScale for the side that's ahead
+func (ev *evalT) drawScale(strong *dragon.Bitboards, weak *dragon.Bitboards, strongIsWhite bool) int {
+	strongPieces := ev.nonPawnMaterial(strong)
+	weakPieces := ev.nonPawnMaterial(weak)
+
+	if strong.Pawns == 0 {
+		// K+N+N v K can't be forced
+		if strong.All == strong.Kings|strong.Knights && bits.OnesCount64(strong.Knights) == 2 && weak.All == weak.Kings {
+			return 0
+		}
+		// Without pawns we need at least a rook more than a minor piece to win
+		if strongPieces-weakPieces <= ev.pieceVals[dragon.Bishop] {
+			if strongPieces < ev.pieceVals[dragon.Rook] {
+				return 0
+			}
+			if weakPieces <= ev.pieceVals[dragon.Bishop] {
+				return 4
+			}
+			return 14
+		}
+	}
+
+	// Rook pawns with the wrong-coloured bishop can't promote if the defending king reaches the corner
+	if weak.All == weak.Kings && strong.All == strong.Kings|strong.Bishops|strong.Pawns && bits.OnesCount64(strong.Bishops) == 1 &&
+		(strong.Pawns&^A == 0 || strong.Pawns&^H == 0) {
+		promoSq := uint64(1) << 56
+		if !strongIsWhite {
+			promoSq = 1
+		}
+		if strong.Pawns&H != 0 {
+			promoSq <<= 7
+		}
+		isWrongBishop := (strong.Bishops&DarkSquares != 0) != (promoSq&DarkSquares != 0)
+		if isWrongBishop && weak.Kings&(promoSq|KingAttacks(promoSq)) != 0 {
+			return 0
+		}
+	}
+
+	// Opposite-coloured bishops with only pawns besides
+	if strong.All == strong.Kings|strong.Bishops|strong.Pawns && weak.All == weak.Kings|weak.Bishops|weak.Pawns &&
+		bits.OnesCount64(strong.Bishops) == 1 && bits.OnesCount64(weak.Bishops) == 1 &&
+		(strong.Bishops&DarkSquares == 0) != (weak.Bishops&DarkSquares == 0) {
+		return oppositeBishopsDrawScale
+	}
+
+	return normalDrawScale
+}
+
+// Material value of the knights, bishops, rooks and queens
+func (ev *evalT) nonPawnMaterial(bbs *dragon.Bitboards) EvalCp {
+	return EvalCp(bits.OnesCount64(bbs.Knights))*ev.pieceVals[dragon.Knight] +
+		EvalCp(bits.OnesCount64(bbs.Bishops))*ev.pieceVals[dragon.Bishop] +
+		EvalCp(bits.OnesCount64(bbs.Rooks))*ev.pieceVals[dragon.Rook] +
+		EvalCp(bits.OnesCount64(bbs.Queens))*ev.pieceVals[dragon.Queen]
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"math/bits"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Attack units per king zone square attacked, by attacking piece type
+var kingAttackWeights = [7]int{0, 0, 2, 2, 3, 5, 0}
+
+// Attack units to king danger - slow to start and then steep, since one attacker is rarely dangerous but
+// a co-ordinated attack usually is. From https://www.chessprogramming.org/King_Safety
+var kingSafetyTable = [100]int{
+	0, 0, 1, 2, 3, 5, 7, 9, 12, 15,
+	18, 22, 26, 30, 35, 39, 44, 50, 56, 62,
+	68, 75, 82, 85, 89, 97, 105, 113, 122, 131,
+	140, 150, 169, 180, 191, 202, 213, 225, 237, 248,
+	260, 272, 283, 295, 307, 319, 330, 342, 354, 366,
+	377, 389, 401, 412, 424, 436, 448, 459, 471, 483,
+	494, 500, 500, 500, 500, 500, 500, 500, 500, 500,
+	500, 500, 500, 500, 500, 500, 500, 500, 500, 500,
+	500, 500, 500, 500, 500, 500, 500, 500, 500, 500,
+	500, 500, 500, 500, 500, 500, 500, 500, 500, 500}
+
+// A single attacker is not counted
+const minKingAttackers = 2
+
+// Bonus for our pawns one and two ranks in front of the king, on the king's file and the files either side
+var pawnShieldVals = [2]int{12, 6}
+
+// Penalty for enemy pawns up to three ranks in front of the king, on the king's file and the files either side
+var pawnStormPenalty = -8
+
+// Penalty for files with no pawns, and for files with no pawns of our own, next to or on the king's file
+var kingOpenFilePenalty = -25
+var kingSemiOpenFilePenalty = -15
+
+// King safety - middle-game only
+// From White's perspective
+func (ev *evalT) kingSafetyVal(board *dragon.Board, pawns *PawnHashEntryT) EvalCp {
+	occupied := board.White.All | board.Black.All
+
+	wKingSafety := ev.sideKingSafetyVal(&board.White, &board.Black, occupied, pawns.pawnFiles[0], pawns.pawnFiles[1], N)
+	bKingSafety := ev.sideKingSafetyVal(&board.Black, &board.White, occupied, pawns.pawnFiles[1], pawns.pawnFiles[0], S)
+
+	return EvalCp(wKingSafety - bKingSafety)
+}
+
+// King safety for one side, where forward is N for white and S for black
+func (ev *evalT) sideKingSafetyVal(own *dragon.Bitboards, enemy *dragon.Bitboards, occupied uint64, ownPawnFiles uint8, enemyPawnFiles uint8, forward func(uint64) uint64) int {
+	king := own.Kings
+	kingAtt := KingAttacks(king)
+	// The squares around the king and one more rank forward
+	kingZone := king | kingAtt | forward(kingAtt)
+
+	// Enemy attacks on the king zone
+	nAttackers, attackUnits := 0, 0
+	addAttacker := func(attacks uint64, piece dragon.Piece) {
+		if zoneAttacks := attacks & kingZone; zoneAttacks != 0 {
+			nAttackers++
+			attackUnits += ev.kingAttackWeights[piece] * bits.OnesCount64(zoneAttacks)
+		}
+	}
+	for knights := enemy.Knights; knights != 0; knights &= knights - 1 {
+		addAttacker(KnightAttacks(knights&-knights), dragon.Knight)
+	}
+	for bishops := enemy.Bishops; bishops != 0; bishops &= bishops - 1 {
+		addAttacker(dragon.CalculateBishopMoveBitboard(uint8(bits.TrailingZeros64(bishops)), occupied), dragon.Bishop)
+	}
+	for rooks := enemy.Rooks; rooks != 0; rooks &= rooks - 1 {
+		addAttacker(dragon.CalculateRookMoveBitboard(uint8(bits.TrailingZeros64(rooks)), occupied), dragon.Rook)
+	}
+	for queens := enemy.Queens; queens != 0; queens &= queens - 1 {
+		queen := uint8(bits.TrailingZeros64(queens))
+		addAttacker(dragon.CalculateBishopMoveBitboard(queen, occupied)|dragon.CalculateRookMoveBitboard(queen, occupied), dragon.Queen)
+	}
+
+	eval := 0
+	if nAttackers >= minKingAttackers {
+		if attackUnits >= len(ev.kingSafetyTable) {
+			attackUnits = len(ev.kingSafetyTable) - 1
+		}
+		eval -= ev.kingSafetyTable[attackUnits]
+	}
+
+	// Pawn shield and pawn storm on the king's file and the files either side
+	kingFiles := king | W(king) | E(king)
+	shield1 := forward(kingFiles)
+	shield2 := forward(shield1)
+	stormZone := shield1 | shield2 | forward(shield2)
+
+	eval += bits.OnesCount64(own.Pawns&shield1)*ev.pawnShieldVals[0] + bits.OnesCount64(own.Pawns&shield2)*ev.pawnShieldVals[1]
+	eval += bits.OnesCount64(enemy.Pawns&stormZone) * ev.pawnStormPenalty
+
+	// Open files near the king
+	kingFile := bits.TrailingZeros64(king) & 7
+	for file := kingFile - 1; file <= kingFile+1; file++ {
+		fileBit := uint8(1) << uint(file&7)
+		if file < 0 || file > 7 || ownPawnFiles&fileBit != 0 {
+			continue
+		}
+		if enemyPawnFiles&fileBit == 0 {
+			eval += ev.kingOpenFilePenalty
+		} else {
+			eval += ev.kingSemiOpenFilePenalty
+		}
+	}
+
+	return eval
+}
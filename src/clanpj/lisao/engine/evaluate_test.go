@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+func TestGamePhase(t *testing.T) {
+	tests := []struct {
+		fen   string
+		phase int
+	}{
+		{dragon.Startpos, MaxGamePhase},
+		{"4k3/pppppppp/8/8/8/8/PPPPPPPP/4K3 w - - 0 1", 0},
+		{"r3k3/8/8/8/8/8/8/3QK3 w - - 0 1", 6},
+		{"QQQQk3/8/8/8/8/8/8/QQQQK3 w - - 0 1", MaxGamePhase}, // promotions
+	}
+	for _, test := range tests {
+		board := dragon.ParseFen(test.fen)
+		if phase := GamePhase(&board); phase != test.phase {
+			t.Errorf("Game phase of %s is %d expected %d\n", test.fen, phase, test.phase)
+		}
+	}
+}
+
+// Pure pawn endings use only the end-game weights
+func TestTaperedEval(t *testing.T) {
+	board := dragon.ParseFen("4k3/8/8/8/8/8/4P3/4K3 w - - 0 1")
+	e2, e1, e8 := 12, 4, 60
+	e := NewEngine(nil)
+	expected := e.pieceEndgameVals[dragon.Pawn] +
+		EvalCp(e.whitePieceEndgamePosVals[dragon.Pawn][e2]) + EvalCp(e.whitePassedPawnEndgamePosVals[e2]) +
+		EvalCp(e.whitePieceEndgamePosVals[dragon.King][e1]) - EvalCp(e.blackPieceEndgamePosVals[dragon.King][e8])
+	if eval := e.StaticEval(&board); eval != expected {
+		t.Errorf("KPvK eval is %d expected %d\n", eval, expected)
+	}
+
+	if eval := taperedEval(100, 200, MaxGamePhase/2); eval != 150 {
+		t.Errorf("Half-way tapered eval is %d expected 150\n", eval)
+	}
+}
+
+// Mirror the ranks and swap the colours
+func flipFen(fen string) string {
+	fields := strings.Fields(fen)
+	ranks := strings.Split(fields[0], "/")
+	for i, j := 0, len(ranks)-1; i < j; i, j = i+1, j-1 {
+		ranks[i], ranks[j] = ranks[j], ranks[i]
+	}
+	swapCase := func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}
+	fields[0] = strings.Map(swapCase, strings.Join(ranks, "/"))
+	if fields[1] == "w" {
+		fields[1] = "b"
+	} else {
+		fields[1] = "w"
+	}
+	if fields[2] != "-" {
+		fields[2] = strings.Map(swapCase, fields[2])
+	}
+	if fields[3] != "-" {
+		fields[3] = string(fields[3][0]) + string('1'+'8'-fields[3][1])
+	}
+	return strings.Join(fields, " ")
+}
+
+var evalTestFens = []string{
+	"r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4",
+	"r2q1rk1/pp2bppp/2n1pn2/3p4/2PP4/2N1PN2/PP3PPP/R2QKB1R w KQ - 0 9",
+	"2r3k1/1p3ppp/p3p3/3nP3/3P4/P4N2/1P3PPP/2R3K1 b - - 0 25",
+	"8/5pk1/R5p1/7p/7P/6P1/r4PK1/8 w - - 0 40",
+	"4r1k1/1R3ppp/8/3N4/8/8/5PPP/6K1 w - - 0 30",
+}
+
+func TestEvalIsSymmetric(t *testing.T) {
+	e := NewEngine(nil)
+	for _, fen := range evalTestFens {
+		board := dragon.ParseFen(fen)
+		flipped := dragon.ParseFen(flipFen(fen))
+		if eval, flippedEval := e.StaticEval(&board), e.StaticEval(&flipped); eval != -flippedEval {
+			t.Errorf("Eval of %s is %d but eval of colour-flipped %s is %d\n", fen, eval, flipFen(fen), flippedEval)
+		}
+	}
+}
+
+func TestActivityVal(t *testing.T) {
+	tests := []struct {
+		fen1, fen2 string // fen1 should be better for white
+		reason     string
+	}{
+		{"4k3/8/8/8/8/8/8/2B1KB2 w - - 0 1", "4k3/8/8/8/8/8/8/1NB1K3 w - - 0 1", "bishop pair"},
+		{"4k3/pp6/8/8/8/8/PP6/3RK3 w - - 0 1", "4k3/pp1p4/8/8/8/8/PP1P4/3RK3 w - - 0 1", "rook on open file"},
+		{"4k3/8/8/3pN3/3P4/8/8/4K3 w - - 0 1", "4k3/5p2/8/3pN3/3P4/8/8/4K3 w - - 0 1", "outpost"},
+	}
+	e := NewEngine(nil)
+	for _, test := range tests {
+		board1, board2 := dragon.ParseFen(test.fen1), dragon.ParseFen(test.fen2)
+		eval1, _ := e.activityVal(&board1)
+		eval2, _ := e.activityVal(&board2)
+		if eval1 <= eval2 {
+			t.Errorf("Activity eval %d of %s is not better than %d of %s (%s)\n", eval1, test.fen1, eval2, test.fen2, test.reason)
+		}
+	}
+}
+
+func TestKingSafetyVal(t *testing.T) {
+	tests := []struct {
+		fen1, fen2 string // fen1 should be safer for white
+		reason     string
+	}{
+		{"4k3/8/8/8/8/8/5PPP/6K1 w - - 0 1", "4k3/8/8/8/5PPP/8/8/6K1 w - - 0 1", "pawn shield"},
+		{"4k3/8/8/8/8/8/5PPP/6K1 w - - 0 1", "4k3/8/8/8/8/8/5P1P/6K1 w - - 0 1", "open file"},
+		{"4k3/8/8/8/8/8/5PPP/6K1 w - - 0 1", "4k3/8/8/8/6p1/8/5PPP/6K1 w - - 0 1", "pawn storm"},
+		{"3qk3/8/8/8/8/8/5PPP/6K1 w - - 0 1", "4k3/8/8/7q/5n2/8/5PPP/6K1 w - - 0 1", "attackers"},
+	}
+	e := NewEngine(nil)
+	for _, test := range tests {
+		board1, board2 := dragon.ParseFen(test.fen1), dragon.ParseFen(test.fen2)
+		pawns1, pawns2 := e.pawnStructure(&board1, 0), e.pawnStructure(&board2, 0)
+		eval1 := e.kingSafetyVal(&board1, &pawns1)
+		eval2 := e.kingSafetyVal(&board2, &pawns2)
+		if eval1 <= eval2 {
+			t.Errorf("King safety eval %d of %s is not better than %d of %s (%s)\n", eval1, test.fen1, eval2, test.fen2, test.reason)
+		}
+	}
+}
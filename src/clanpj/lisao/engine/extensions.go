@@ -0,0 +1,74 @@
+package engine
+
+import (
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Is the move a pawn push to the seventh rank (from the mover's perspective) - i.e. threatening to promote?
+func isSeventhRankPawnPush(board *dragon.Board, move dragon.Move) bool {
+	from, to := move.From(), move.To()
+	if board.Wtomove {
+		return to>>3 == 6 && board.White.Pawns&(uint64(1)<<from) != 0
+	}
+	return to>>3 == 1 && board.Black.Pawns&(uint64(1)<<from) != 0
+}
+
+// The extension in plies for a move from the node at depthFromRoot - 0 if the path's extension budget is used up.
+// Only one extension applies per move.
+func (s *SearchT) moveExtension(depthFromRoot int, givesCheck bool, isOneReply bool, isPawnPush bool, isSingular bool) int {
+	if !(givesCheck || isOneReply || isPawnPush || isSingular) {
+		return 0
+	}
+	if s.pathExtensions[depthFromRoot] >= s.opts.MaxPathExtensions {
+		s.stats.ExtensionCapHits++
+		return 0
+	}
+
+	switch {
+	case givesCheck:
+		s.stats.CheckExts++
+	case isOneReply:
+		s.stats.OneReplyExts++
+	case isPawnPush:
+		s.stats.PawnPushExts++
+	default:
+		s.stats.SingularExts++
+	}
+	return 1
+}
+
+// Singular extension verification - is the ttMove better than all other moves by at least SingularMargin?
+// The other moves are searched shallower with a zero window just below the TT eval less the margin - if they all fail
+// low then the ttMove is singular.
+func (s *SearchT) isSingularMove(legalMoves []dragon.Move, ttMove dragon.Move, ttEval EvalCp, depthToGo int, depthFromRoot int) bool {
+	s.stats.SingularSearches++
+	singularBeta := ttEval - SingularMargin
+
+	for _, move := range legalMoves {
+		if move == ttMove {
+			continue
+		}
+
+		// Make the move
+		s.pathMoves[depthFromRoot] = move
+		unapply := s.board.Apply(move)
+		// Add to the move history
+		repetitions := s.ht.Add(s.board.Hash())
+
+		eval := s.negaDrawEval(depthFromRoot)
+		if !s.opts.UsePosRepetition || repetitions <= 1 {
+			_, eval = s.childNegAlphaBeta(depthToGo-SingularDepthReduction, depthFromRoot, singularBeta-1, singularBeta, NoMove, 0)
+		}
+
+		// Remove from the move history
+		s.ht.Remove(s.board.Hash())
+		// Take back the move
+		unapply()
+
+		if eval >= singularBeta {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+func TestSeventhRankPawnPush(t *testing.T) {
+	var tests = []struct {
+		fen        string
+		move       string
+		isPawnPush bool
+	}{
+		{"4k3/8/2P5/8/8/8/8/4K3 w - - 0 1", "c6c7", true},
+		{"4k3/8/8/2P5/8/8/8/4K3 w - - 0 1", "c5c6", false},
+		{"4k3/8/8/8/8/5p2/8/4K3 b - - 0 1", "f3f2", true},
+		{"4k3/8/8/8/8/5r2/8/4K3 b - - 0 1", "f3f2", false},
+	}
+
+	for i, test := range tests {
+		board := dragon.ParseFen(test.fen)
+		move, _ := dragon.ParseMove(test.move)
+		if isPawnPush := isSeventhRankPawnPush(&board, move); isPawnPush != test.isPawnPush {
+			t.Errorf("Test %d: expected %v for %s in %s but got %v\n", i, test.isPawnPush, test.move, test.fen, isPawnPush)
+		}
+	}
+}
+
+// Re8+ Rxe8 Rxe8# is only found at depth 2 if the checks are extended, and only within the path's extension budget.
+// Q-search checks would also find it so they're disabled.
+func TestCheckExtensions(t *testing.T) {
+	var tests = []struct {
+		useCheckExtensions bool
+		maxPathExtensions  int
+		isMate             bool
+	}{
+		{false, 8, false},
+		{true, 0, false},
+		{true, 8, true},
+	}
+
+	for i, test := range tests {
+		opts := NewOptions()
+		opts.UseCheckExtensions, opts.MaxPathExtensions, opts.UseQSearchChecks = test.useCheckExtensions, test.maxPathExtensions, false
+
+		board := dragon.ParseFen("r5k1/5ppp/8/8/8/8/4RPPP/4R1K1 w - - 0 1")
+		var stats SearchStatsT
+		var timeout uint32
+		s := NewSearchT(NewEngine(opts), opts, &board, make(HistoryTableT), NewMoveHistory(), make([]dragon.Move, MaxDepth), &stats, &timeout)
+
+		_, eval := s.NegAlphaBeta(2, 0, YourCheckMateEval, MyCheckMateEval, NoMove, false)
+		if isMate := eval > TBWinEval; isMate != test.isMate {
+			t.Errorf("Test %d: expected mate %v but got eval %d with %d check extensions\n", i, test.isMate, eval, stats.CheckExts)
+		}
+	}
+}
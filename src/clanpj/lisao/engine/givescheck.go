@@ -0,0 +1,116 @@
+// Fast gives-check test - without making the move and asking whether the opponent's king is in check.
+
+package engine
+
+import (
+	"math/bits"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Diagonal and orthogonal rays from each square on an empty board.
+// A slider can only (directly or by discovery) check the king along one of the king's rays.
+var bishopRays [64]uint64
+var rookRays [64]uint64
+
+func init() {
+	for sq := uint8(0); sq < 64; sq++ {
+		bishopRays[sq] = dragon.CalculateBishopMoveBitboard(sq, 0)
+		rookRays[sq] = dragon.CalculateRookMoveBitboard(sq, 0)
+	}
+}
+
+// Does the (legal) move give check - either directly by the moved piece, or by discovery from a slider behind it?
+func givesCheck(board *dragon.Board, move dragon.Move) bool {
+	us, them := &board.White, &board.Black
+	if !board.Wtomove {
+		us, them = &board.Black, &board.White
+	}
+	from, to := move.From(), move.To()
+	fromBb, toBb := uint64(1)<<from, uint64(1)<<to
+	king := them.Kings
+	kingSq := uint8(bits.TrailingZeros64(king))
+
+	// The squares vacated by the move, and the occupancy after the move
+	vacated := fromBb
+	occupied := (board.White.All|board.Black.All)&^fromBb | toBb
+
+	piece := dragon.Piece(dragon.Nothing)
+	switch {
+	case us.Pawns&fromBb != 0:
+		piece = dragon.Pawn
+		if promo := move.Promote(); promo != dragon.Nothing {
+			piece = promo
+		} else if (from^to)&7 != 0 && them.All&toBb == 0 {
+			// En-passant - the captured pawn is behind the destination square
+			if board.Wtomove {
+				vacated |= toBb >> 8
+			} else {
+				vacated |= toBb << 8
+			}
+			occupied &^= vacated
+		}
+	case us.Knights&fromBb != 0:
+		piece = dragon.Knight
+	case us.Bishops&fromBb != 0:
+		piece = dragon.Bishop
+	case us.Rooks&fromBb != 0:
+		piece = dragon.Rook
+	case us.Queens&fromBb != 0:
+		piece = dragon.Queen
+	default:
+		piece = dragon.King
+	}
+
+	// Direct check
+	switch piece {
+	case dragon.Pawn:
+		pawnAttacks := WPawnAttacks(toBb)
+		if !board.Wtomove {
+			pawnAttacks = BPawnAttacks(toBb)
+		}
+		if pawnAttacks&king != 0 {
+			return true
+		}
+	case dragon.Knight:
+		if KnightAttacks(toBb)&king != 0 {
+			return true
+		}
+	case dragon.Bishop:
+		if bishopRays[kingSq]&toBb != 0 && dragon.CalculateBishopMoveBitboard(to, occupied)&king != 0 {
+			return true
+		}
+	case dragon.Rook:
+		if rookRays[kingSq]&toBb != 0 && dragon.CalculateRookMoveBitboard(to, occupied)&king != 0 {
+			return true
+		}
+	case dragon.Queen:
+		if bishopRays[kingSq]&toBb != 0 && dragon.CalculateBishopMoveBitboard(to, occupied)&king != 0 ||
+			rookRays[kingSq]&toBb != 0 && dragon.CalculateRookMoveBitboard(to, occupied)&king != 0 {
+			return true
+		}
+	case dragon.King:
+		// Castling - the rook can give check from its new square
+		if int(to)-int(from) == 2 || int(from)-int(to) == 2 {
+			rookFrom, rookTo := to+1, to-1 // short
+			if to < from {
+				rookFrom, rookTo = to-2, to+1 // long
+			}
+			occupied = occupied&^(uint64(1)<<rookFrom) | uint64(1)<<rookTo
+			if rookRays[kingSq]&(uint64(1)<<rookTo) != 0 && dragon.CalculateRookMoveBitboard(rookTo, occupied)&king != 0 {
+				return true
+			}
+		}
+	}
+
+	return isDiscoveredCheck(us, kingSq, fromBb, vacated, occupied)
+}
+
+// Does vacating squares uncover a check from one of our sliders (other than the moved piece)?
+// Occupied is the occupancy after the move, which includes the moved piece on its destination square.
+func isDiscoveredCheck(us *dragon.Bitboards, kingSq uint8, fromBb uint64, vacated uint64, occupied uint64) bool {
+	if bishopRays[kingSq]&vacated != 0 && dragon.CalculateBishopMoveBitboard(kingSq, occupied)&(us.Bishops|us.Queens)&^fromBb != 0 {
+		return true
+	}
+	return rookRays[kingSq]&vacated != 0 && dragon.CalculateRookMoveBitboard(kingSq, occupied)&(us.Rooks|us.Queens)&^fromBb != 0
+}
@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// givesCheck must agree with making the move and testing for check - over all legal moves in positions with discovered
+// checks, castling checks, en-passant and promotions, and in random games
+func TestGivesCheck(t *testing.T) {
+	fens := []string{
+		dragon.Startpos,
+		"4k3/8/8/8/8/8/4N3/4RK2 w - - 0 1",  // discovered check by the knight
+		"5k2/8/8/8/8/8/8/4K2R w K - 0 1",    // castling check from the rook
+		"8/8/8/KPp4r/8/8/8/7k w - c6 0 1",   // en-passant - and the capture exposes our own king
+		"k7/8/8/1K1Pp2q/8/8/8/8 w - e6 0 1", // en-passant into check is illegal
+		"8/8/8/R2Pp2k/8/8/8/K7 w - e6 0 1",  // en-passant discovered check along the rank
+		"3k4/1P6/8/8/8/8/8/4K3 w - - 0 1",   // promotions
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	}
+
+	check := func(board *dragon.Board) {
+		moves, _ := board.GenerateLegalMoves2(false)
+		for _, move := range moves {
+			expected := func() bool {
+				unapply := board.Apply(move)
+				defer unapply()
+				return board.OurKingInCheck()
+			}()
+			if gc := givesCheck(board, move); gc != expected {
+				t.Errorf("Move %s in %s: givesCheck is %v, expected %v\n", &move, board.ToFen(), gc, expected)
+			}
+		}
+	}
+
+	for _, fen := range fens {
+		board := dragon.ParseFen(fen)
+		check(&board)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for game := 0; game < 50; game++ {
+		board := dragon.ParseFen(dragon.Startpos)
+		for ply := 0; ply < 200; ply++ {
+			check(&board)
+			moves, _ := board.GenerateLegalMoves2(false)
+			if len(moves) == 0 {
+				break
+			}
+			board.Apply(moves[rnd.Intn(len(moves))])
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// History scores are kept within +/- maxHistory
+const maxHistory = 1 << 14
+
+// Quiet move ordering - the butterfly history heuristic and countermoves
+type MoveHistoryT struct {
+	// Butterfly history by [side to move][from][to] - positive for quiet moves that caused beta cut-offs, negative for
+	// quiet moves that were tried before a beta cut-off
+	history [2][64][64]int32
+	// The quiet move that refuted each move by [side that made the move][from][to] of the refuted move
+	counterMoves [2][64][64]dragon.Move
+}
+
+func NewMoveHistory() *MoveHistoryT {
+	return &MoveHistoryT{}
+}
+
+func sideIndex(wtomove bool) int {
+	if wtomove {
+		return 0
+	}
+	return 1
+}
+
+// History score of a quiet move for the side to move
+func (mh *MoveHistoryT) score(wtomove bool, move dragon.Move) int32 {
+	return mh.history[sideIndex(wtomove)][move.From()][move.To()]
+}
+
+// The countermove to the previous move, which was made by the other side
+func (mh *MoveHistoryT) counterMove(wtomove bool, prevMove dragon.Move) dragon.Move {
+	if prevMove == NoMove {
+		return NoMove
+	}
+	return mh.counterMoves[sideIndex(!wtomove)][prevMove.From()][prevMove.To()]
+}
+
+// Add a bonus (or malus if negative) - scaled down as the score approaches +/- maxHistory so it never overflows
+func (mh *MoveHistoryT) update(wtomove bool, move dragon.Move, bonus int32) {
+	h := &mh.history[sideIndex(wtomove)][move.From()][move.To()]
+	absBonus := bonus
+	if absBonus < 0 {
+		absBonus = -absBonus
+	}
+	*h += bonus - *h*absBonus/maxHistory
+}
+
+// Record a beta cut-off by a quiet move - a depth-squared bonus for the move and a malus for the quiet moves tried
+// before it, and the move becomes the countermove to the previous move (NoMove if none, e.g. a null move)
+func (mh *MoveHistoryT) addCutoff(opts *OptionsT, wtomove bool, move dragon.Move, prevMove dragon.Move, quietsTried []dragon.Move, depthToGo int) {
+	if opts.UseHistoryHeuristic {
+		bonus := int32(depthToGo * depthToGo)
+		if bonus > maxHistory {
+			bonus = maxHistory
+		}
+		mh.update(wtomove, move, bonus)
+		for _, quiet := range quietsTried {
+			mh.update(wtomove, quiet, -bonus)
+		}
+	}
+
+	if opts.UseCounterMoves && prevMove != NoMove {
+		mh.counterMoves[sideIndex(!wtomove)][prevMove.From()][prevMove.To()] = move
+	}
+}
+
+// Halve the history scores between searches so that recent results count for more - countermoves are kept
+func (mh *MoveHistoryT) Decay() {
+	for side := range mh.history {
+		for from := range mh.history[side] {
+			for to := range mh.history[side][from] {
+				mh.history[side][from][to] /= 2
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+func TestMoveHistory(t *testing.T) {
+	mh := NewMoveHistory()
+	opts := NewOptions()
+	e2e4, _ := dragon.ParseMove("e2e4")
+	d2d4, _ := dragon.ParseMove("d2d4")
+	e7e5, _ := dragon.ParseMove("e7e5")
+
+	// Scores must saturate within +/- maxHistory
+	for i := 0; i < 1000; i++ {
+		mh.addCutoff(opts, true, e2e4, NoMove, []dragon.Move{d2d4}, 20)
+	}
+	if score := mh.score(true, e2e4); score <= 0 || score > maxHistory {
+		t.Errorf("Bonus history score %d is out of range\n", score)
+	}
+	if score := mh.score(true, d2d4); score >= 0 || score < -maxHistory {
+		t.Errorf("Malus history score %d is out of range\n", score)
+	}
+	if score := mh.score(false, e2e4); score != 0 {
+		t.Errorf("History score %d for the other side is not 0\n", score)
+	}
+
+	before := mh.score(true, e2e4)
+	mh.Decay()
+	if after := mh.score(true, e2e4); after != before/2 {
+		t.Errorf("History score %d decayed to %d\n", before, after)
+	}
+
+	// Black's refutation of e2e4
+	mh.addCutoff(opts, false, e7e5, e2e4, nil, 4)
+	if counterMove := mh.counterMove(false, e2e4); counterMove != e7e5 {
+		t.Errorf("Countermove to e2e4 is %v - expected e7e5\n", &counterMove)
+	}
+	if counterMove := mh.counterMove(false, NoMove); counterMove != NoMove {
+		t.Errorf("Countermove to a null move is %v\n", &counterMove)
+	}
+}
+
+// The engine keeps the move history from one search to the next until it's reset
+func TestEngineMoveHistory(t *testing.T) {
+	isEmpty := func(mh *MoveHistoryT) bool { return *mh == MoveHistoryT{} }
+
+	e := NewEngine(nil)
+	board := dragon.ParseFen(dragon.Startpos)
+	if _, err := Search(SearchRequestT{Engine: e, Board: &board, History: make(HistoryTableT), Depth: 5}); err != nil {
+		t.Fatalf("Search failed: %v\n", err)
+	}
+	if isEmpty(e.moveHistory) {
+		t.Errorf("Expected move history after a search\n")
+	}
+
+	e.ResetMoveHistory()
+	if !isEmpty(e.moveHistory) {
+		t.Errorf("Expected no move history after a reset\n")
+	}
+}
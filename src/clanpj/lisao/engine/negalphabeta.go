@@ -4,6 +4,8 @@ import (
 	"math/bits"
 
 	dragon "github.com/Bubblyworld/dragontoothmg"
+
+	"clanpj/lisao/syzygy"
 )
 
 // Return the best eval attainable through alpha-beta from the given position (with killer-move hint), along with the move leading to the principal variation.
@@ -20,15 +22,37 @@ func (s *SearchT) NegAlphaBeta(depthToGo int, depthFromRoot int, alpha EvalCp, b
 	if depthFromRoot < MaxDepthStats {
 		s.stats.NonLeafsAt[depthFromRoot]++
 	}
+	if uint64(depthFromRoot) > s.stats.SelDepth {
+		s.stats.SelDepth = uint64(depthFromRoot)
+	}
+	s.checkNodeLimit()
+	s.checkProgress()
+
+	// Are we restricted to a subset of the root moves? If so then TT entries for the root position don't apply.
+	isRestrictedRoot := depthFromRoot == 0 && s.rootMoves != nil
 
 	// Remember this to check whether our final eval is a lower or upper bound - for TT
 	origBeta := beta
 	origAlpha := alpha
 
+	// Draws by rule - before probing the TT since TT entries don't know the fifty-move counter
+	if s.opts.UseDrawRules && depthFromRoot > 0 {
+		if IsFiftyMoveDraw(s.board) {
+			s.stats.FiftyMoveDraws++
+			return NoMove, s.negaDrawEval(depthFromRoot)
+		}
+		if IsInsufficientMaterial(s.board) {
+			s.stats.MaterialDraws++
+			return NoMove, s.negaDrawEval(depthFromRoot)
+		}
+	}
+
 	// Probe the Transposition Table
 	var ttMove = NoMove
-	if UseTT {
-		ttEntry, isTTHit := probeTT(tt, s.board.Hash())
+	// The TT entry of the same parity, if any, for singular extension verification
+	var ttParityEntry TTParityEntryT
+	if s.opts.UseTT && !isRestrictedRoot {
+		ttEntry, isTTHit := probeTT(s.engine.tt, s.board.Hash())
 
 		if isTTHit {
 			s.stats.TTHits++
@@ -39,6 +63,9 @@ func (s *SearchT) NegAlphaBeta(depthToGo int, depthFromRoot int, alpha EvalCp, b
 				ttpEntry = &ttEntry.parityHits[depthToGoParity(depthToGo)^1]
 			}
 			ttMove = ttpEntry.bestMove
+			if (int(ttpEntry.depthToGo) & 1) == (depthToGo & 1) {
+				ttParityEntry = *ttpEntry
+			}
 
 			// If the TT hit is for exactly the same depth then use the eval; otherwise we just use the bestMove as a move hint.
 			// We use a deeper TT hit only for the same parity since our eval in start-game is unstable between even/odd plies.
@@ -47,10 +74,14 @@ func (s *SearchT) NegAlphaBeta(depthToGo int, depthFromRoot int, alpha EvalCp, b
 			if depthToGo == int(ttpEntry.depthToGo) {
 				s.stats.TTDepthHits++
 				canUseTTEval = true
-			} else if HeurUseTTDeeperHits && depthToGo < int(ttpEntry.depthToGo) && (depthToGo&1) == (int(ttpEntry.depthToGo)&1) {
+			} else if s.opts.HeurUseTTDeeperHits && depthToGo < int(ttpEntry.depthToGo) && (depthToGo&1) == (int(ttpEntry.depthToGo)&1) {
 				s.stats.TTDeeperHits++
 				canUseTTEval = true
 			}
+			// The root must always come up with a move, but cut-off entries (e.g. from null-move pruning) don't have one
+			if depthFromRoot == 0 && ttMove == NoMove {
+				canUseTTEval = false
+			}
 			if canUseTTEval {
 				ttEval := ttpEntry.eval
 				// If the eval is exact then we're done
@@ -82,6 +113,14 @@ func (s *SearchT) NegAlphaBeta(depthToGo int, depthFromRoot int, alpha EvalCp, b
 		}
 	}
 
+	// Probe the Syzygy tablebases - WDL ignores the fifty-move counter so only probe straight after a zeroing move
+	if s.opts.UseSyzygy && depthFromRoot > 0 && s.board.Halfmoveclock == 0 && bits.OnesCount64(s.board.White.All|s.board.Black.All) <= syzygy.MaxPieces() {
+		if wdl, ok := syzygy.ProbeWdl(s.board); ok {
+			s.stats.TBHits++
+			return NoMove, s.negaTBEval(wdl, depthFromRoot)
+		}
+	}
+
 	// Maximise eval with beta cut-off
 	bestMove := NoMove
 	bestEval := YourCheckMateEval
@@ -96,11 +135,13 @@ done:
 		hintMove := ttMove
 
 		// Try hint move before doing move-gen if we have a known valid move hint
-		if UseEarlyMoveHint {
+		if s.opts.UseEarlyMoveHint {
 			if hintMove != NoMove {
 				s.stats.ValidHintMoves++
 				// Make the move
+				s.pathMoves[depthFromRoot] = hintMove
 				unapply := s.board.Apply(hintMove)
+				s.pathExtensions[depthFromRoot+1] = s.pathExtensions[depthFromRoot]
 				// Add to the move history
 				repetitions := s.ht.Add(s.board.Hash())
 
@@ -108,16 +149,16 @@ done:
 				var eval EvalCp
 				// We consider 2-fold repetition to be a draw, since if a repeat can be forced then it can be forced again.
 				// This reduces the search tree a bit and is common practice in chess engines.
-				if UsePosRepetition && repetitions > 1 {
+				if s.opts.UsePosRepetition && repetitions > 1 {
 					s.stats.PosRepetitions++
-					eval = DrawEval
+					eval = s.negaDrawEval(depthFromRoot + 1) // child's perspective - negated below
 				} else if depthToGo <= 1 {
 					s.stats.Nodes++
-					if UseQSearch {
+					if s.opts.UseQSearch {
 						// Quiesce
-						childKiller, eval, _ = s.QSearchNegAlphaBeta(QSearchDepth, depthFromRoot+1 /*depthFromQRoot*/, 0, -beta, -alpha, childKiller)
+						childKiller, eval, _ = s.QSearchNegAlphaBeta(s.opts.QSearchDepth, depthFromRoot+1 /*depthFromQRoot*/, 0, -beta, -alpha, childKiller)
 					} else {
-						eval = NegaStaticEval(s.board)
+						eval = s.engine.NegaStaticEval(s.board, s.stats)
 					}
 				} else {
 					childKiller, eval = s.NegAlphaBeta(depthToGo-1, depthFromRoot+1, -beta, -alpha, childKiller, false)
@@ -160,25 +201,63 @@ done:
 
 		// Generate all legal moves
 		legalMoves, isInCheck := s.board.GenerateLegalMoves2(false /*all moves*/)
+		if isRestrictedRoot {
+			legalMoves = s.rootMoves
+		}
 
 		// Check for checkmate or stalemate
 		if len(legalMoves) == 0 {
 			s.stats.Mates++
-			bestMove, bestEval = NoMove, negaMateEval(s.board, depthFromRoot) // TODO use isInCheck
+			bestMove, bestEval = NoMove, s.negaMateEval(s.board, depthFromRoot) // TODO use isInCheck
 
 			break done
 		}
 
+		// Frontier pruning on the static eval - never in check, at the root or in PV nodes (i.e. only in zero-window searches)
+		isFutile, futileEval := false, YourCheckMateEval
+		if !isInCheck && depthFromRoot > 0 && beta-alpha == 1 && depthToGo <= MaxFrontierPruningDepth &&
+			(s.opts.UseReverseFutilityPruning || s.opts.UseRazoring || s.opts.UseFutilityPruning) {
+			staticNegaEval := s.engine.NegaStaticEval(s.board, s.stats)
+
+			// Reverse futility - we're so far above beta that the remaining plies are unlikely to bring us back down
+			if s.opts.UseReverseFutilityPruning && beta < TBWinEval-MaxDepth {
+				if eval := staticNegaEval - EvalCp(s.engine.reverseFutilityMargins[depthToGo]); eval >= beta {
+					s.stats.RevFutilityCuts++
+					bestMove, bestEval = NoMove, eval
+					break done
+				}
+			}
+
+			// Razoring - we're so far below alpha that only captures are likely to help, so verify with q-search only
+			if s.opts.UseRazoring && s.opts.UseQSearch && staticNegaEval+EvalCp(s.engine.razoringMargins[depthToGo]) <= alpha {
+				_, qEval, _ := s.QSearchNegAlphaBeta(s.opts.QSearchDepth, depthFromRoot /*depthFromQRoot*/, 0, alpha, beta, NoMove)
+				if qEval <= alpha {
+					s.stats.RazoringCuts++
+					bestMove, bestEval = NoMove, qEval
+					break done
+				}
+			}
+
+			// Futility - quiet moves are unlikely to bring us up to alpha, so prune them in the move loop
+			if s.opts.UseFutilityPruning {
+				futileEval = staticNegaEval + EvalCp(s.engine.futilityMargins[depthToGo])
+				isFutile = futileEval <= alpha
+			}
+		}
+
 		// Try null-move heuristic
-		if HeurUseNullMove {
+		if s.opts.HeurUseNullMove {
 			const nullMoveDepthSkip = 3 // must be odd to cope with our even/odd ply eval instability
-			// Try null-move - but never 2 null moves in a row, and never in check otherwise king gets captured
-			if !isInCheck && !parentNullMove && beta != MyCheckMateEval && depthToGo > nullMoveDepthSkip {
+			// Try null-move - but never 2 null moves in a row, never in check otherwise king gets captured, and never at the
+			// root which must come up with a real move (it's not full-window with aspiration windows)
+			if !isInCheck && !parentNullMove && depthFromRoot > 0 && beta != MyCheckMateEval && depthToGo > nullMoveDepthSkip {
 				// Use piece count to determine end-game for zugzwang avoidance - TODO improve this
 				nNonPawns := bits.OnesCount64((s.board.White.All & ^s.board.White.Pawns) | (s.board.Black.All & ^s.board.Black.Pawns))
 				// Proceed with null-move heuristic if there are at least 4 non-pawn pieces (note the count includes the two kings)
 				if nNonPawns >= 6 {
+					s.pathMoves[depthFromRoot] = NoMove
 					unapply := s.board.ApplyNullMove()
+					s.pathExtensions[depthFromRoot+1] = s.pathExtensions[depthFromRoot]
 					_, nullMoveEval := s.NegAlphaBeta(depthToGo-nullMoveDepthSkip, depthFromRoot+1, -beta, -alpha, NoMove /*killer???*/ /*parentNullMove*/, true)
 					nullMoveEval = -nullMoveEval // back to our perspective
 					unapply()
@@ -210,18 +289,22 @@ done:
 		}
 
 		killerMove := NoMove
-		if UseKillerMoves {
+		if s.opts.UseKillerMoves {
 			killerMove = killer
 		}
 		deepKiller := NoMove
-		if UseDeepKillerMoves {
+		if s.opts.UseDeepKillerMoves {
 			deepKiller = s.deepKillers[depthFromRoot]
 		}
+		counterMove := NoMove
+		if s.opts.UseCounterMoves {
+			counterMove = s.mh.counterMove(s.board.Wtomove, s.prevMove(depthFromRoot))
+		}
 
 		// Sort the moves heuristically
-		if UseMoveOrdering {
+		if s.opts.UseMoveOrdering {
 			if len(legalMoves) > 1 {
-				if UseIDMoveHint && depthToGo >= MinIDMoveHintDepth {
+				if s.opts.UseIDMoveHint && depthToGo >= s.opts.MinIDMoveHintDepth {
 					idKiller := killerMove
 					if idKiller == NoMove {
 						idKiller = ttMove
@@ -233,43 +316,95 @@ done:
 					ttMove, _ = s.NegAlphaBeta(depthToGo-2, depthFromRoot, alpha, beta, idKiller, false)
 
 				}
-				orderMoves(s.board, legalMoves, ttMove, killerMove, deepKiller, &s.stats.Killers, &s.stats.DeepKillers)
+				orderMoves(s.opts, s.board, legalMoves, ttMove, killerMove, deepKiller, counterMove, s.mh, &s.stats.Killers, &s.stats.DeepKillers)
 			}
-		} else if UseKillerMoves {
+		} else if s.opts.UseKillerMoves {
 			// Place killer-move (or deep killer move) first if it's there
-			prioritiseKillerMove(legalMoves, killer, UseDeepKillerMoves, s.deepKillers[depthFromRoot], &s.stats.Killers, &s.stats.DeepKillers)
+			prioritiseKillerMove(legalMoves, killer, s.opts.UseDeepKillerMoves, s.deepKillers[depthFromRoot], &s.stats.Killers, &s.stats.DeepKillers)
+		}
+
+		// Singular extension - if the TT says the ttMove is good then verify whether all the other moves are much worse
+		singularMove := NoMove
+		if s.opts.UseSingularExtensions && depthToGo >= s.opts.SingularMinDepth && ttMove != NoMove && ttMove == ttParityEntry.bestMove && len(legalMoves) > 1 &&
+			(ttParityEntry.evalType == TTEvalLowerBound || ttParityEntry.evalType == TTEvalExact) && int(ttParityEntry.depthToGo) >= depthToGo-SingularDepthReduction &&
+			-TBWinEval+MaxDepth < ttParityEntry.eval && ttParityEntry.eval < TBWinEval-MaxDepth && s.pathExtensions[depthFromRoot] < s.opts.MaxPathExtensions {
+			if s.isSingularMove(legalMoves, ttMove, ttParityEntry.eval, depthToGo, depthFromRoot) {
+				singularMove = ttMove
+			}
 		}
 
+		// Quiet moves that didn't cause a beta cut-off, for the history heuristic
+		var quietsTried [64]dragon.Move
+		nQuietsTried := 0
+
 		for i, move := range legalMoves {
+			if depthFromRoot == 0 {
+				s.currMove, s.currMoveNumber = move, i+1
+			}
 			// Don't repeat the hintMove
-			if UseEarlyMoveHint && move == hintMove {
+			if s.opts.UseEarlyMoveHint && move == hintMove {
 				continue
 			}
 
+			// Only quiet moves are candidates for late move reductions
+			isQuiet := move.Promote() == dragon.Nothing && moveVictim(s.board, move) == dragon.Nothing
+			isPawnPush := s.opts.UsePawnPushExtensions && isSeventhRankPawnPush(s.board, move)
+
 			// Make the move
+			s.pathMoves[depthFromRoot] = move
 			unapply := s.board.Apply(move)
 			// Add to the move history
 			repetitions := s.ht.Add(s.board.Hash())
+			givesCheck := s.board.OurKingInCheck()
 
 			// Get the (deep) eval
 			var eval EvalCp
+			isPruned := false
 			// We consider 2-fold repetition to be a draw, since if a repeat can be forced then it can be forced again.
 			// This reduces the search tree a bit and is common practice in chess engines.
-			if UsePosRepetition && repetitions > 1 {
+			if s.opts.UsePosRepetition && repetitions > 1 {
 				s.stats.PosRepetitions++
-				eval = DrawEval
-			} else if depthToGo <= 1 {
-				s.stats.Nodes++
-				if UseQSearch {
-					// Quiesce
-					childKiller, eval, _ = s.QSearchNegAlphaBeta(QSearchDepth, depthFromRoot+1 /*depthFromQRoot*/, 0, -beta, -alpha, childKiller)
-				} else {
-					eval = NegaStaticEval(s.board)
-				}
+				eval = s.negaDrawEval(depthFromRoot)
+			} else if isFutile && i > 0 && isQuiet && !givesCheck && !isPawnPush && move != singularMove {
+				// Futility pruning - a quiet move is unlikely to bring us up to alpha, so fail soft at the futility eval
+				s.stats.FutilityPrunes++
+				eval, isPruned = futileEval, true
 			} else {
-				childKiller, eval = s.NegAlphaBeta(depthToGo-1, depthFromRoot+1, -beta, -alpha, childKiller, false)
+				// Search extensions - forcing moves are searched deeper
+				extension := s.moveExtension(depthFromRoot, s.opts.UseCheckExtensions && givesCheck, s.opts.UseOneReplyExtensions && len(legalMoves) == 1, isPawnPush, move == singularMove)
+
+				// Late move reductions - quiet moves ordered late are unlikely to be best, so search them shallower first
+				reduction := 0
+				if s.opts.UseLateMoveReductions && extension == 0 && depthToGo >= s.opts.LMRMinDepth && i >= s.opts.LMRMinMoveIndex && !isInCheck && isQuiet &&
+					move != ttMove && move != killerMove && move != deepKiller && !givesCheck {
+					reduction = lmrReduction(depthToGo, i)
+				}
+
+				if reduction > 0 {
+					s.stats.LMRReductions++
+					// Zero-window search to prove that the move is no better than alpha
+					childKiller, eval = s.childNegAlphaBeta(depthToGo-reduction, depthFromRoot, alpha, alpha+1, childKiller, 0)
+					if eval > alpha {
+						// Not proven - search to full depth after all
+						s.stats.LMRReSearches++
+						reduction = 0
+					}
+				}
+
+				if reduction == 0 {
+					if s.opts.UsePVS && i > 0 {
+						// Principal variation search - assume that the first move is best and just prove that the others are no better than alpha
+						childKiller, eval = s.childNegAlphaBeta(depthToGo, depthFromRoot, alpha, alpha+1, childKiller, extension)
+						if alpha < eval && eval < beta {
+							// Not proven - re-search with the full window to get the true eval
+							s.stats.PVSReSearches++
+							childKiller, eval = s.childNegAlphaBeta(depthToGo, depthFromRoot, alpha, beta, childKiller, extension)
+						}
+					} else {
+						childKiller, eval = s.childNegAlphaBeta(depthToGo, depthFromRoot, alpha, beta, childKiller, extension)
+					}
+				}
 			}
-			eval = -eval // back to our perspective
 
 			// Remove from the move history
 			s.ht.Remove(s.board.Hash())
@@ -281,6 +416,15 @@ done:
 				break
 			}
 
+			// A pruned move was never searched so it can't be the best move - its eval is just a (fail-soft) bound, and it's
+			// no better than alpha so it can't cause a cut-off
+			if isPruned {
+				if eval > bestEval {
+					bestEval = eval
+				}
+				continue
+			}
+
 			// Maximise our eval.
 			// Note - this MUST be strictly > because we fail-soft AT the current best evel - beware!
 			if eval > bestEval {
@@ -300,6 +444,8 @@ done:
 					s.stats.KillerCuts++
 				} else if bestMove == deepKiller {
 					s.stats.DeepKillerCuts++
+				} else if bestMove == counterMove {
+					s.stats.CounterMoveCuts++
 				}
 				if i == 0 {
 					s.stats.FirstChildCuts++
@@ -307,8 +453,16 @@ done:
 						s.stats.FirstChildCutsAt[depthFromRoot]++
 					}
 				}
+				if isQuiet {
+					s.mh.addCutoff(s.opts, s.board.Wtomove, move, s.prevMove(depthFromRoot), quietsTried[:nQuietsTried], depthToGo)
+				}
 				break
 			}
+
+			if isQuiet && nQuietsTried < len(quietsTried) {
+				quietsTried[nQuietsTried] = move
+				nQuietsTried++
+			}
 		}
 
 		// If we didn't get a beta cut-off then we visited all children.
@@ -318,7 +472,7 @@ done:
 		s.deepKillers[depthFromRoot] = bestMove
 	} // end of fake run-once loop
 
-	if UseTT {
+	if s.opts.UseTT && !isRestrictedRoot {
 		// Update the TT - but only if the search was not truncated due to a time-out
 		if !isTimedOut(s.timeout) {
 			evalType := TTEvalExact
@@ -328,7 +482,7 @@ done:
 				evalType = TTEvalUpperBound
 			}
 			// Write back the TT entry - this is an update if the TT already contains an entry for this hash
-			writeTTEntry(tt, s.board.Hash(), bestEval, bestMove, depthToGo, evalType)
+			writeTTEntry(s.engine.tt, s.board.Hash(), bestEval, bestMove, depthToGo, evalType)
 		}
 	}
 
@@ -336,15 +490,58 @@ done:
 	return bestMove, bestEval
 }
 
+// Search the current position as a child of a node at depthToGo and depthFromRoot - i.e. to depthToGo-1, plus extension plies.
+// Alpha and beta and the returned eval are from the parent's perspective.
+func (s *SearchT) childNegAlphaBeta(depthToGo int, depthFromRoot int, alpha EvalCp, beta EvalCp, killer dragon.Move, extension int) (dragon.Move, EvalCp) {
+	depthToGo += extension
+	s.pathExtensions[depthFromRoot+1] = s.pathExtensions[depthFromRoot] + extension
+
+	var eval EvalCp
+	if depthToGo <= 1 {
+		s.stats.Nodes++
+		if s.opts.UseQSearch {
+			// Quiesce
+			killer, eval, _ = s.QSearchNegAlphaBeta(s.opts.QSearchDepth, depthFromRoot+1 /*depthFromQRoot*/, 0, -beta, -alpha, killer)
+		} else {
+			eval = s.engine.NegaStaticEval(s.board, s.stats)
+		}
+	} else {
+		killer, eval = s.NegAlphaBeta(depthToGo-1, depthFromRoot+1, -beta, -alpha, killer, false)
+	}
+	return killer, -eval // back to the parent's perspective
+}
+
 // Return the eval for stalemate or checkmate from curent mover's perspective
 // Only valid if there are no legal moves.
-func negaMateEval(board *dragon.Board, depthFromRoot int) EvalCp {
+func (s *SearchT) negaMateEval(board *dragon.Board, depthFromRoot int) EvalCp {
 	if board.OurKingInCheck() {
 		// checkmate - closer to root is better
 		return YourCheckMateEval + EvalCp(depthFromRoot)
 	}
 	// stalemate
-	return DrawEval
+	return s.negaDrawEval(depthFromRoot)
+}
+
+// Tablebase win or loss - closer to root is better
+func (s *SearchT) negaTBEval(wdl syzygy.WdlT, depthFromRoot int) EvalCp {
+	switch wdl {
+	case syzygy.Win:
+		return TBWinEval - EvalCp(depthFromRoot)
+	case syzygy.Loss:
+		return -TBWinEval + EvalCp(depthFromRoot)
+	}
+	// Cursed wins and blessed losses are draws under the fifty-move rule
+	return s.negaDrawEval(depthFromRoot)
+}
+
+// Return the eval for a draw from the current mover's perspective.
+// Contempt is from the perspective of the root mover - i.e. the engine - so a positive contempt makes draws look
+// bad for the engine and good for the opponent.
+func (s *SearchT) negaDrawEval(depthFromRoot int) EvalCp {
+	if depthFromRoot%2 == 0 {
+		return DrawEval - EvalCp(s.opts.Contempt)
+	}
+	return DrawEval + EvalCp(s.opts.Contempt)
 }
 
 // Move the killer or deep-killer move to the front of the legal moves list, if it's in the legal moves list.
@@ -3,15 +3,17 @@ package engine
 import dragon "github.com/Bubblyworld/dragontoothmg"
 
 // Quiescence search - differs from full search as follows:
-//   - we only look at captures, promotions and check evasion - we could/should also possibly look at checks, but check detection is currently expensive
-//   - we consider 'standing pat' - i.e. do alpha/beta cutoff according to the node's static eval (TODO)
+//   - we only look at captures, promotions and check evasion - and quiet checks in the first QSearchCheckDepth plies if UseQSearchChecks
+//   - we consider 'standing pat' - i.e. do alpha/beta cutoff according to the node's static eval - except when in check if UseQSearchChecks
 // Return best-move, best-eval, isQuiesced
-// TODO - better static eval if we bottom out without quiescing, e.g. static exchange evaluation (SEE)
 // TODO - include moving away from attacks too?
 func (s *SearchT) QSearchNegAlphaBeta(qDepthToGo int, depthFromRoot int, depthFromQRoot int, alpha EvalCp, beta EvalCp, killer dragon.Move) (dragon.Move, EvalCp, bool) {
 
 	s.stats.QNodes++
 	s.stats.QNonLeafs++
+	if uint64(depthFromRoot) > s.stats.SelDepth {
+		s.stats.SelDepth = uint64(depthFromRoot)
+	}
 	if depthFromQRoot < MaxQDepthStats {
 		s.stats.QNonLeafsAt[depthFromQRoot]++
 	}
@@ -20,11 +22,15 @@ func (s *SearchT) QSearchNegAlphaBeta(qDepthToGo int, depthFromRoot int, depthFr
 	origBeta := beta
 	origAlpha := alpha
 
-	staticNegaEval := NegaStaticEval(s.board)
+	staticNegaEval := s.engine.NegaStaticEval(s.board, s.stats)
+
+	// We only check for check up front if we're searching quiet checks, since otherwise in-check q-nodes are rare
+	isInCheck := s.opts.UseQSearchChecks && s.board.OurKingInCheck()
 
 	// Stand pat - equivalent to considering the null move as a valid move.
 	// Essentially the player to move doesn't _have_ to make a 'noisy' move - assuming that there is a quiet move available.
-	if alpha < staticNegaEval {
+	// When in check we have to find an evasion so there is no stand pat.
+	if alpha < staticNegaEval && !isInCheck {
 		alpha = staticNegaEval
 	}
 
@@ -40,8 +46,8 @@ func (s *SearchT) QSearchNegAlphaBeta(qDepthToGo int, depthFromRoot int, depthFr
 
 	// Probe the Quiescence Transposition Table
 	var qttMove = NoMove
-	if UseQSearchTT {
-		qttEntry, isQttHit := probeQtt(qtt, s.board.Hash())
+	if s.opts.UseQSearchTT {
+		qttEntry, isQttHit := probeQtt(s.engine.qtt, s.board.Hash())
 
 		if isQttHit {
 			s.stats.QttHits++
@@ -90,19 +96,26 @@ func (s *SearchT) QSearchNegAlphaBeta(qDepthToGo int, depthFromRoot int, depthFr
 	// Maximise eval with beta cut-off
 	bestMove := NoMove
 	bestEval := staticNegaEval // stand pat value
+	if isInCheck {
+		bestEval = YourCheckMateEval
+	}
 
 	// Did we reach quiescence at all leaves?
 	isQuiesced := false
 
-	// Generate all noisy legal moves
-	legalMoves, isInCheck := s.board.GenerateLegalMoves2( /*onlyCapturesPromosCheckEvasion*/ true)
+	// Generate all noisy legal moves - or all legal moves if we're including quiet checks at this depth
+	isCheckPly := s.opts.UseQSearchChecks && depthFromQRoot < s.opts.QSearchCheckDepth
+	legalMoves, isInCheck := s.board.GenerateLegalMoves2( /*onlyCapturesPromosCheckEvasion*/ !isCheckPly)
+	if isCheckPly && !isInCheck {
+		legalMoves = noisyAndCheckingMoves(s.board, legalMoves, s.stats)
+	}
 
 	if len(legalMoves) == 0 {
 		// No noisy moves - checkmate or stalemate or just quiesced
 		isQuiesced = true
 		if isInCheck {
 			s.stats.QMates++
-			bestMove, bestEval = NoMove, negaMateEval(s.board, depthFromRoot) // TODO checks for mate again expensively
+			bestMove, bestEval = NoMove, s.negaMateEval(s.board, depthFromRoot) // TODO checks for mate again expensively
 		} else {
 			// Already quiesced - just return static eval
 			bestMove, bestEval = NoMove, staticNegaEval
@@ -112,25 +125,25 @@ func (s *SearchT) QSearchNegAlphaBeta(qDepthToGo int, depthFromRoot int, depthFr
 		nMovesToUse := len(legalMoves)
 
 		killerMove := NoMove
-		if UseQKillerMoves {
+		if s.opts.UseQKillerMoves {
 			killerMove = killer
 		}
 		deepKiller := NoMove
-		if UseQDeepKillerMoves {
+		if s.opts.UseQDeepKillerMoves {
 			deepKiller = s.deepKillers[depthFromRoot]
 		}
 
 		// Sort the moves heuristically
-		if UseQSearchMoveOrdering {
+		if s.opts.UseQSearchMoveOrdering {
 			if len(legalMoves) > 1 {
-				orderMoves(s.board, legalMoves, qttMove, killerMove, deepKiller, &s.stats.QKillers, &s.stats.QDeepKillers)
-				if UseQSearchRampagePruning {
-					nMovesToUse = pruneQueenRampages(s.board, legalMoves, depthFromQRoot, s.stats)
+				orderMoves(s.opts, s.board, legalMoves, qttMove, killerMove, deepKiller, NoMove, s.mh, &s.stats.QKillers, &s.stats.QDeepKillers)
+				if s.opts.UseQSearchRampagePruning {
+					nMovesToUse = pruneQueenRampages(s.board, legalMoves, depthFromQRoot, s.opts.QSearchRampagePruningDepth, s.stats)
 				}
 			}
-		} else if UseQKillerMoves {
+		} else if s.opts.UseQKillerMoves {
 			// Place killer-move (or deep killer move) first if it's there
-			prioritiseKillerMove(legalMoves, killer, UseQDeepKillerMoves, s.deepKillers[depthFromRoot], &s.stats.QKillers, &s.stats.QDeepKillers)
+			prioritiseKillerMove(legalMoves, killer, s.opts.UseQDeepKillerMoves, s.deepKillers[depthFromRoot], &s.stats.QKillers, &s.stats.QDeepKillers)
 		}
 
 		// We're quiesced as long as all children (we visit) are quiesced.
@@ -141,6 +154,12 @@ func (s *SearchT) QSearchNegAlphaBeta(qDepthToGo int, depthFromRoot int, depthFr
 		for i := 0; i < nMovesToUse; i++ {
 			move := legalMoves[i]
 
+			// Losing captures can't improve on stand pat - but we must try all check evasions
+			if s.opts.UseQSearchSEEPruning && !isInCheck && SEE(s.board, move) < 0 {
+				s.stats.QSEEPrunes++
+				continue
+			}
+
 			// Get the (deep) eval
 			var eval EvalCp
 
@@ -154,7 +173,7 @@ func (s *SearchT) QSearchNegAlphaBeta(qDepthToGo int, depthFromRoot int, depthFr
 				// We hit max depth before quiescing
 				isQuiesced = false
 				// Ignore mate check to avoid generating moves at all leaf nodes
-				eval = NegaStaticEval(s.board)
+				eval = s.engine.NegaStaticEval(s.board, s.stats)
 			} else {
 				var isChildQuiesced bool
 				childKiller, eval, isChildQuiesced = s.QSearchNegAlphaBeta(qDepthToGo-1, depthFromRoot+1, depthFromQRoot+1, -beta, -alpha, childKiller)
@@ -209,7 +228,7 @@ func (s *SearchT) QSearchNegAlphaBeta(qDepthToGo int, depthFromRoot int, depthFr
 	}
 
 	// Update the QTT
-	if UseQSearchTT {
+	if s.opts.UseQSearchTT {
 		evalType := TTEvalExact
 		if origBeta <= bestEval {
 			evalType = TTEvalLowerBound
@@ -217,18 +236,34 @@ func (s *SearchT) QSearchNegAlphaBeta(qDepthToGo int, depthFromRoot int, depthFr
 			evalType = TTEvalUpperBound
 		}
 		// Write back the QTT entry - this is an update if the TT already contains an entry for this hash
-		writeQttEntry(qtt, s.board.Hash(), bestEval, bestMove, qDepthToGo, evalType, isQuiesced)
+		writeQttEntry(s.engine.qtt, s.board.Hash(), bestEval, bestMove, qDepthToGo, evalType, isQuiesced)
 	}
 
 	return bestMove, bestEval, isQuiesced
 }
 
+// Filter the moves in place to just captures, promotions and quiet moves that give check
+func noisyAndCheckingMoves(board *dragon.Board, moves []dragon.Move, stats *SearchStatsT) []dragon.Move {
+	nMoves := 0
+	for _, move := range moves {
+		if move.Promote() != dragon.Nothing || moveVictim(board, move) != dragon.Nothing {
+			moves[nMoves] = move
+			nMoves++
+		} else if givesCheck(board, move) {
+			stats.QChecks++
+			moves[nMoves] = move
+			nMoves++
+		}
+	}
+	return moves[:nMoves]
+}
+
 // Do rampage move pruning.
 // Note: assumes queen captures appear first in the moves list which is true for MVV-LVA.
 // Returns the number of moves to look at.
-func pruneQueenRampages(board *dragon.Board, moves []dragon.Move, depthFromQRoot int, stats *SearchStatsT) int {
+func pruneQueenRampages(board *dragon.Board, moves []dragon.Move, depthFromQRoot int, rampagePruningDepth int, stats *SearchStatsT) int {
 	nMovesToUse := len(moves)
-	if depthFromQRoot >= QSearchRampagePruningDepth {
+	if depthFromQRoot >= rampagePruningDepth {
 		victim0 := board.PieceAt(moves[0].To())
 		// If the top-rated move is not a queen capture, likely a promo, then delay rampage pruning
 		if victim0 == dragon.Queen {
@@ -0,0 +1,281 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// UCI option type
+type OptionTypeT int
+
+const (
+	OptionCheck  OptionTypeT = iota // true or false
+	OptionSpin                      // an int in [Min, Max]
+	OptionCombo                     // one of Vars
+	OptionString                    // free text - "<empty>" for the empty string
+	OptionButton                    // no value, setting it triggers an action
+)
+
+func (t OptionTypeT) String() string {
+	switch t {
+	case OptionCheck:
+		return "check"
+	case OptionSpin:
+		return "spin"
+	case OptionCombo:
+		return "combo"
+	case OptionString:
+		return "string"
+	case OptionButton:
+		return "button"
+	default:
+		return "unknown"
+	}
+}
+
+// A single option
+type OptionT struct {
+	Name    string
+	Type    OptionTypeT
+	Default string   // as reported in the UCI handshake - not used for buttons
+	Min     int      // only valid for OptionSpin
+	Max     int      // only valid for OptionSpin
+	Vars    []string // only valid for OptionCombo
+	set     func(value string) error
+}
+
+func CheckOption(name string, defaultValue bool, set func(value bool)) OptionT {
+	return OptionT{Name: name, Type: OptionCheck, Default: strconv.FormatBool(defaultValue), set: func(value string) error {
+		set(value == "true")
+		return nil
+	}}
+}
+
+func SpinOption(name string, defaultValue int, min int, max int, set func(value int)) OptionT {
+	return OptionT{Name: name, Type: OptionSpin, Default: strconv.Itoa(defaultValue), Min: min, Max: max, set: func(value string) error {
+		n, _ := strconv.Atoi(value)
+		set(n)
+		return nil
+	}}
+}
+
+// The setter gets the value as spelled in vars
+func ComboOption(name string, defaultValue string, vars []string, set func(value string)) OptionT {
+	return OptionT{Name: name, Type: OptionCombo, Default: defaultValue, Vars: vars, set: func(value string) error {
+		set(value)
+		return nil
+	}}
+}
+
+// The setter gets "" for "<empty>", and can reject the value, e.g. if it's a file that can't be loaded
+func StringOption(name string, defaultValue string, set func(value string) error) OptionT {
+	if defaultValue == "" {
+		defaultValue = "<empty>"
+	}
+	return OptionT{Name: name, Type: OptionString, Default: defaultValue, set: set}
+}
+
+func ButtonOption(name string, press func()) OptionT {
+	return OptionT{Name: name, Type: OptionButton, set: func(value string) error {
+		press()
+		return nil
+	}}
+}
+
+// The option declaration for the UCI handshake, e.g. "option name UseTT type check default true"
+func (o *OptionT) UciString() string {
+	s := "option name " + o.Name + " type " + o.Type.String()
+	switch o.Type {
+	case OptionCheck, OptionString:
+		s += " default " + o.Default
+	case OptionSpin:
+		s += fmt.Sprintf(" default %s min %d max %d", o.Default, o.Min, o.Max)
+	case OptionCombo:
+		s += " default " + o.Default
+		for _, v := range o.Vars {
+			s += " var " + v
+		}
+	}
+	return s
+}
+
+// Validate the value and call the setter
+func (o *OptionT) Set(value string) error {
+	switch o.Type {
+	case OptionCheck:
+		value = strings.ToLower(value)
+		if value != "true" && value != "false" {
+			return fmt.Errorf("bot: %s value '%s' is not true or false", o.Name, value)
+		}
+	case OptionSpin:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("bot: %s value '%s' is not an int", o.Name, value)
+		}
+		if n < o.Min || n > o.Max {
+			return fmt.Errorf("bot: %s value %d is out of range [%d, %d]", o.Name, n, o.Min, o.Max)
+		}
+	case OptionCombo:
+		isVar := false
+		for _, v := range o.Vars {
+			if strings.EqualFold(v, value) {
+				value, isVar = v, true
+				break
+			}
+		}
+		if !isVar {
+			return fmt.Errorf("bot: %s value '%s' is not one of %s", o.Name, value, strings.Join(o.Vars, ", "))
+		}
+	case OptionString:
+		if value == "<empty>" {
+			value = ""
+		}
+	}
+	if err := o.set(value); err != nil {
+		return fmt.Errorf("bot: failed to set %s to '%s' (%v)", o.Name, value, err)
+	}
+	return nil
+}
+
+// Options in declaration order, looked up case-insensitively by name like UCI setoption.
+// The UCI handshake, setoption and config file loading are all driven by the registry.
+type OptionRegistryT struct {
+	options []*OptionT
+	byName  map[string]*OptionT
+
+	// Config files currently being loaded - a config file can set ConfigFile, so we need to catch cycles
+	loadingFiles map[string]bool
+}
+
+// A registry with the search options, which are bound to opts.
+// Front-ends add their own options, e.g. the opening book.
+func NewOptionRegistry(opts *OptionsT) *OptionRegistryT {
+	r := &OptionRegistryT{byName: make(map[string]*OptionT), loadingFiles: make(map[string]bool)}
+	r.Add(ComboOption("SearchAlgorithm", opts.SearchAlgorithmString(), []string{"NegAlphaBeta"}, func(value string) {
+		opts.SearchAlgorithm = NegAlphaBeta
+	}))
+	r.AddFields(opts)
+	return r
+}
+
+// Add an option - names are case-insensitive so they must be unique ignoring case
+func (r *OptionRegistryT) Add(option OptionT) {
+	key := strings.ToLower(option.Name)
+	if _, ok := r.byName[key]; ok {
+		panic("bot: duplicate option " + option.Name)
+	}
+	r.options = append(r.options, &option)
+	r.byName[key] = &option
+}
+
+// Add an option for each exported bool and int field of the struct that fields points to, named after the field.
+// Int fields are spin options and need min and max tags, e.g. `min:"1" max:"1024"`. Fields tagged `uci:"-"` are
+// skipped.
+func (r *OptionRegistryT) AddFields(fields interface{}) {
+	v := reflect.ValueOf(fields).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("uci") == "-" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			r.Add(CheckOption(field.Name, value.Bool(), func(b bool) { value.SetBool(b) }))
+		case reflect.Int:
+			min, minErr := strconv.Atoi(field.Tag.Get("min"))
+			max, maxErr := strconv.Atoi(field.Tag.Get("max"))
+			if minErr != nil || maxErr != nil {
+				panic("bot: option field " + field.Name + " needs int min and max tags")
+			}
+			r.Add(SpinOption(field.Name, int(value.Int()), min, max, func(n int) { value.SetInt(int64(n)) }))
+		default:
+			panic("bot: option field " + field.Name + " has unsupported type " + field.Type.String())
+		}
+	}
+}
+
+func (r *OptionRegistryT) Options() []*OptionT {
+	return r.options
+}
+
+// Look up an option case-insensitively - nil if there's no such option
+func (r *OptionRegistryT) Lookup(name string) *OptionT {
+	return r.byName[strings.ToLower(name)]
+}
+
+func (r *OptionRegistryT) Set(name string, value string) error {
+	option := r.Lookup(name)
+	if option == nil {
+		return fmt.Errorf("bot: unknown option '%s'", name)
+	}
+	return option.Set(value)
+}
+
+// Set an option from the arguments of a UCI setoption command, i.e. "name <id> [value <x>]" where the id and value can
+// contain spaces.
+func (r *OptionRegistryT) SetUci(args []string) error {
+	if len(args) < 2 || args[0] != "name" {
+		return errors.New("bot: malformed setoption command")
+	}
+	nameEnd := len(args)
+	for i := 1; i < len(args); i++ {
+		if args[i] == "value" {
+			nameEnd = i
+			break
+		}
+	}
+	name := strings.Join(args[1:nameEnd], " ")
+	value := ""
+	if nameEnd < len(args) {
+		value = strings.Join(args[nameEnd+1:], " ")
+	}
+	return r.Set(name, value)
+}
+
+// Set an option from a string of the form "Name=Value"
+func (r *OptionRegistryT) SetNameValue(nameValue string) error {
+	nv := strings.SplitN(nameValue, "=", 2)
+	if len(nv) != 2 {
+		return fmt.Errorf("bot: malformed option '%s' - expected Name=Value", nameValue)
+	}
+	return r.Set(strings.TrimSpace(nv[0]), strings.TrimSpace(nv[1]))
+}
+
+// Load options from a config file with one Name=Value per line - blank lines and lines starting with # are ignored.
+// We stop at the first bad line, or if the file (indirectly) loads itself.
+func (r *OptionRegistryT) LoadFile(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if r.loadingFiles[absPath] {
+		return fmt.Errorf("bot: config file %s loads itself", path)
+	}
+	r.loadingFiles[absPath] = true
+	defer delete(r.loadingFiles, absPath)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := r.SetNameValue(line); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOptionRegistry(t *testing.T) {
+	opts := NewOptions()
+	r := NewOptionRegistry(opts)
+
+	// One per search option
+	if len(r.Options()) != 50 {
+		t.Errorf("Expected 50 options but got %d\n", len(r.Options()))
+	}
+
+	var tests = []struct {
+		uci string
+	}{
+		{"option name SearchAlgorithm type combo default NegAlphaBeta var NegAlphaBeta"},
+		{"option name UseTT type check default true"},
+		{"option name Contempt type spin default 0 min -1000 max 1000"},
+		{"option name PawnHashSize type spin default 2 min 1 max 1024"},
+	}
+	for _, test := range tests {
+		name := strings.Fields(test.uci)[2]
+		if option := r.Lookup(name); option == nil || option.UciString() != test.uci {
+			t.Errorf("Expected '%s' for %s\n", test.uci, name)
+		}
+	}
+
+	// Names are case-insensitive and the setters write to our options
+	if err := r.SetUci([]string{"name", "usett", "value", "False"}); err != nil || opts.UseTT {
+		t.Errorf("Failed to set UseTT (%v)\n", err)
+	}
+	if err := r.SetNameValue("QSearchDepth=8"); err != nil || opts.QSearchDepth != 8 {
+		t.Errorf("Failed to set QSearchDepth (%v)\n", err)
+	}
+
+	// Bad values are rejected and don't change anything
+	var badTests = []struct {
+		name  string
+		value string
+	}{
+		{"NoSuchOption", "1"},
+		{"UseQDeepKillerMoves", "maybe"},
+		{"Contempt", "lots"},
+		{"Contempt", "1001"},
+		{"SearchAlgorithm", "MiniMax"},
+	}
+	for _, test := range badTests {
+		err := r.Set(test.name, test.value)
+		if err == nil {
+			t.Errorf("Expected an error setting %s to %s\n", test.name, test.value)
+		} else if test.name != "NoSuchOption" && !strings.Contains(err.Error(), test.name) {
+			t.Errorf("Error '%v' doesn't name the option %s\n", err, test.name)
+		}
+	}
+	if !opts.UseQDeepKillerMoves || opts.Contempt != 0 {
+		t.Errorf("Bad values changed the options\n")
+	}
+}
+
+func TestOptionRegistryLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "options")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lisao.cfg")
+	config := "# Test config\n\nContempt = 20\nUseSingularExtensions=true\n"
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := NewOptions()
+	if err := NewOptionRegistry(opts).LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if opts.Contempt != 20 || !opts.UseSingularExtensions {
+		t.Errorf("Config file options were not applied\n")
+	}
+
+	// Errors report the line
+	if err := ioutil.WriteFile(path, []byte("Contempt=20\nUseTT\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = NewOptionRegistry(NewOptions()).LoadFile(path)
+	if err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("Expected an error at line 2 but got %v\n", err)
+	}
+
+	// Config files can load other config files, but not in a cycle
+	r := NewOptionRegistry(NewOptions())
+	r.Add(StringOption("ConfigFile", "", func(path string) error { return r.LoadFile(path) }))
+	path2 := filepath.Join(dir, "lisao2.cfg")
+	if err := ioutil.WriteFile(path, []byte("ConfigFile="+path2+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path2, []byte("Contempt=20\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.LoadFile(path); err != nil {
+		t.Errorf("Nested config file failed: %v\n", err)
+	}
+	// Loading the same file again is fine
+	if err := r.LoadFile(path); err != nil {
+		t.Errorf("Reloading config file failed: %v\n", err)
+	}
+	if err := ioutil.WriteFile(path2, []byte("ConfigFile="+path+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.LoadFile(path); err == nil || !strings.Contains(err.Error(), "loads itself") {
+		t.Errorf("Expected a config file cycle error but got %v\n", err)
+	}
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"math/bits"
+	"math/rand"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Pawn structure eval and info that depends only on the pawns.
+// Members ordered by descending size for better packing - 32 bytes.
+type PawnHashEntryT struct {
+	key         uint64    // pawn-only zobrist key
+	passedPawns [2]uint64 // white, black
+	eval        EvalCp    // from white's perspective
+	endgameEval EvalCp
+	pawnFiles   [2]uint8 // white, black - files with at least one pawn, bit 0 is the A file
+}
+
+const pawnHashEntrySize = 32
+
+// Random keys per colour and square - fixed seed so that pawn keys are reproducible
+var pawnZobristKeys [2][64]uint64
+
+func init() {
+	r := rand.New(rand.NewSource(0x5eed))
+	for colour := range pawnZobristKeys {
+		for sq := range pawnZobristKeys[colour] {
+			pawnZobristKeys[colour][sq] = r.Uint64()
+		}
+	}
+}
+
+// Largest power of 2 number of entries that fits in the given size
+func pawnHashEntries(megabytes int) int {
+	n := megabytes * 1024 * 1024 / pawnHashEntrySize
+	if n < 1 {
+		n = 1
+	}
+	return 1 << uint(bits.Len(uint(n))-1)
+}
+
+// Drop the pawn hash if opts disable it, otherwise make it PawnHashSize - a new table if the size has changed
+func (e *EngineT) configurePawnHash(opts *OptionsT) {
+	if !opts.UsePawnHash {
+		e.pawnHash = nil
+	} else if n := pawnHashEntries(opts.PawnHashSize); n != len(e.pawnHash) {
+		e.pawnHash = make([]PawnHashEntryT, n)
+	}
+}
+
+// Clear the pawn hash - nothing to do if it's disabled.
+// Note that an all-zero entry is a valid entry for the position with no pawns.
+func (e *EngineT) ResetPawnHash() {
+	for i := range e.pawnHash {
+		e.pawnHash[i] = PawnHashEntryT{}
+	}
+}
+
+// Zobrist key of just the pawns
+func PawnKey(board *dragon.Board) uint64 {
+	var key uint64
+	for pawns := board.White.Pawns; pawns != 0; pawns &= pawns - 1 {
+		key ^= pawnZobristKeys[0][bits.TrailingZeros64(pawns)]
+	}
+	for pawns := board.Black.Pawns; pawns != 0; pawns &= pawns - 1 {
+		key ^= pawnZobristKeys[1][bits.TrailingZeros64(pawns)]
+	}
+	return key
+}
+
+// Pawn structure for the board - from the pawn hash if possible, otherwise calculated and cached.
+// Stats may be nil.
+func (e *EngineT) probePawnHash(board *dragon.Board, stats *SearchStatsT) PawnHashEntryT {
+	if e.pawnHash == nil {
+		return e.pawnStructure(board, 0)
+	}
+
+	key := PawnKey(board)
+	// Note: assumes pawn hash size is a power of 2!!!
+	index := int(key) & (len(e.pawnHash) - 1)
+	if stats != nil {
+		stats.PawnHashProbes++
+	}
+
+	entry := e.pawnHash[index]
+	if entry.key == key {
+		if stats != nil {
+			stats.PawnHashHits++
+		}
+		return entry
+	}
+
+	entry = e.pawnStructure(board, key)
+	e.pawnHash[index] = entry
+	return entry
+}
+
+// Calculate the pawn-only eval terms - passed pawns, pawns protected by pawns and doubled pawns
+func (ev *evalT) pawnStructure(board *dragon.Board, key uint64) PawnHashEntryT {
+	wPawns := board.White.Pawns
+	bPawns := board.Black.Pawns
+
+	// Passed pawns
+	wPawnScope := WPawnScope(wPawns)
+	bPawnScope := BPawnScope(bPawns)
+
+	wPassedPawns := wPawns & ^bPawnScope
+	bPassedPawns := bPawns & ^wPawnScope
+
+	ppVal := pieceTypePiecesPosVal(wPassedPawns, &ev.whitePassedPawnPosVals) - pieceTypePiecesPosVal(bPassedPawns, &ev.blackPassedPawnPosVals)
+	ppEndgameVal := pieceTypePiecesPosVal(wPassedPawns, &ev.whitePassedPawnEndgamePosVals) - pieceTypePiecesPosVal(bPassedPawns, &ev.blackPassedPawnEndgamePosVals)
+
+	// Pawns protected by pawns
+	wPawnsProtectedByPawns := WPawnAttacks(wPawns) & wPawns
+	bPawnsProtectedByPawns := BPawnAttacks(bPawns) & bPawns
+
+	nPProtPawns := bits.OnesCount64(wPawnsProtectedByPawns) - bits.OnesCount64(bPawnsProtectedByPawns)
+
+	// Doubled pawns
+	wPawnTelestop := NFill(N(wPawns))
+	wDoubledPawns := wPawnTelestop & wPawns
+
+	bPawnTelestop := SFill(S(bPawns))
+	bDoubledPawns := bPawnTelestop & bPawns
+
+	nDoubledPawns := bits.OnesCount64(wDoubledPawns) - bits.OnesCount64(bDoubledPawns)
+
+	return PawnHashEntryT{
+		key:         key,
+		passedPawns: [2]uint64{wPassedPawns, bPassedPawns},
+		eval:        ppVal + EvalCp(nPProtPawns*ev.pProtPawnVal+nDoubledPawns*ev.doubledPawnPenalty),
+		endgameEval: ppEndgameVal + EvalCp(nPProtPawns*ev.pProtPawnEndgameVal+nDoubledPawns*ev.doubledPawnEndgamePenalty),
+		pawnFiles:   [2]uint8{pawnFiles(wPawns), pawnFiles(bPawns)},
+	}
+}
+
+// Files with at least one pawn, bit 0 is the A file
+func pawnFiles(pawns uint64) uint8 {
+	// Everything ends up on the first rank
+	return uint8(SFill(pawns))
+}
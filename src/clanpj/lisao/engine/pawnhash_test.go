@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// The pawn key must only depend on the pawns
+func TestPawnKey(t *testing.T) {
+	board1 := dragon.ParseFen("4k3/pp6/8/8/8/8/PP6/R3K3 w - - 0 1")
+	board2 := dragon.ParseFen("3k4/pp6/8/8/8/8/PP3N2/4K3 b - - 0 1")
+	board3 := dragon.ParseFen("4k3/pp6/8/8/8/P7/1P6/R3K3 w - - 0 1")
+
+	if PawnKey(&board1) != PawnKey(&board2) {
+		t.Errorf("Pawn keys differ for the same pawns\n")
+	}
+	if PawnKey(&board1) == PawnKey(&board3) {
+		t.Errorf("Pawn keys are the same for different pawns\n")
+	}
+}
+
+// Evals must be the same with and without the pawn hash, and the second probe of the same pawns must hit
+func TestPawnHash(t *testing.T) {
+	opts := NewOptions()
+	opts.UsePawnHash = false
+	noPawnHash := NewEngine(opts)
+	e := NewEngine(nil)
+
+	for _, fen := range evalTestFens {
+		board := dragon.ParseFen(fen)
+
+		expected := noPawnHash.StaticEval(&board)
+
+		var stats SearchStatsT
+		for i := 0; i < 2; i++ {
+			if eval := e.staticEval(&board, &stats); eval != expected {
+				t.Errorf("Eval %d with pawn hash is not %d for %s\n", eval, expected, fen)
+			}
+		}
+		if stats.PawnHashProbes != 2 || stats.PawnHashHits < 1 {
+			t.Errorf("Expected 2 pawn hash probes with at least 1 hit for %s but got %d probes and %d hits\n", fen, stats.PawnHashProbes, stats.PawnHashHits)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package engine
+
+// Frontier pruning is only done at nodes up to this depth to go - the margins are indexed by depth to go
+const MaxFrontierPruningDepth = 3
+
+// Quiet moves are pruned if the static eval plus the margin can't reach alpha.
+// The margins here are the EvalParamsT defaults - they depend on the eval scale but aren't tuned.
+var futilityMargins = [MaxFrontierPruningDepth + 1]int{0, 200, 350, 500}
+
+// The node is cut if the static eval less the margin is still at least beta
+var reverseFutilityMargins = [MaxFrontierPruningDepth + 1]int{0, 120, 240, 360}
+
+// The node is only searched by q-search if the static eval plus the margin can't reach alpha
+var razoringMargins = [MaxFrontierPruningDepth + 1]int{0, 300, 450, 600}
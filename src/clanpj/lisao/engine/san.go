@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Indexed by piece type - pawns have no SAN letter
+var sanPieceLetters = [7]string{"", "", "N", "B", "R", "Q", "K"}
+
+// Indexed by promo piece type - only N, B, R, Q valid
+var sanPromoSuffixes = [7]string{"", "", "=N", "=B", "=R", "=Q", ""}
+
+// Return the SAN for a legal move in the given position, including the check or mate suffix.
+func MoveToSan(board *dragon.Board, move dragon.Move) string {
+	san := moveToSanNoSuffix(board, move)
+
+	unapply := board.Apply(move)
+	if board.OurKingInCheck() {
+		if len(board.GenerateLegalMoves()) == 0 {
+			san += "#"
+		} else {
+			san += "+"
+		}
+	}
+	unapply()
+
+	return san
+}
+
+// SAN without the check/mate suffix
+func moveToSanNoSuffix(board *dragon.Board, move dragon.Move) string {
+	from, to := move.From(), move.To()
+	piece := board.PieceAt(from)
+
+	if piece == dragon.King {
+		if int(to)-int(from) == 2 {
+			return "O-O"
+		} else if int(to)-int(from) == -2 {
+			return "O-O-O"
+		}
+	}
+
+	isCapture := dragon.IsCapture(move, board)
+	toStr := dragon.IndexToAlgebraic(dragon.Square(to))
+	fromStr := dragon.IndexToAlgebraic(dragon.Square(from))
+
+	var sb strings.Builder
+	if piece == dragon.Pawn {
+		if isCapture {
+			sb.WriteByte(fromStr[0])
+			sb.WriteString("x")
+		}
+		sb.WriteString(toStr)
+		sb.WriteString(sanPromoSuffixes[move.Promote()])
+		return sb.String()
+	}
+
+	sb.WriteString(sanPieceLetters[piece])
+
+	// Disambiguate if another piece of the same type can move to the same square
+	ambiguous, sameFile, sameRank := false, false, false
+	for _, other := range board.GenerateLegalMoves() {
+		otherFrom := other.From()
+		if other.To() != to || otherFrom == from || board.PieceAt(otherFrom) != piece {
+			continue
+		}
+		ambiguous = true
+		if otherFrom%8 == from%8 {
+			sameFile = true
+		}
+		if otherFrom/8 == from/8 {
+			sameRank = true
+		}
+	}
+	if ambiguous {
+		if !sameFile {
+			sb.WriteByte(fromStr[0])
+		} else if !sameRank {
+			sb.WriteByte(fromStr[1])
+		} else {
+			sb.WriteString(fromStr)
+		}
+	}
+
+	if isCapture {
+		sb.WriteString("x")
+	}
+	sb.WriteString(toStr)
+
+	return sb.String()
+}
+
+// Strip check/mate and annotation suffixes, and normalise zero-castling
+func normaliseSan(san string) string {
+	san = strings.TrimRight(san, "+#!?")
+	san = strings.Replace(san, "0-0-0", "O-O-O", 1)
+	san = strings.Replace(san, "0-0", "O-O", 1)
+	return san
+}
+
+// Find the legal move matching the given SAN in the given position.
+// For robustness we also accept UCI long algebraic moves, e.g. "e2e4".
+func ParseSan(board *dragon.Board, san string) (dragon.Move, error) {
+	san = normaliseSan(san)
+	// Some sources drop the '=' from promotions
+	altSan := ""
+	if n := len(san); n >= 2 && strings.ContainsAny(san[n-1:], "NBRQ") && san[n-2] >= '1' && san[n-2] <= '8' {
+		altSan = san[:n-1] + "=" + san[n-1:]
+	}
+
+	for _, move := range board.GenerateLegalMoves() {
+		moveSan := moveToSanNoSuffix(board, move)
+		if moveSan == san || moveSan == altSan || move.String() == strings.ToLower(san) {
+			return move, nil
+		}
+	}
+
+	return NoMove, errors.New("bot: no legal move matching '" + san + "' in position " + board.ToFen())
+}
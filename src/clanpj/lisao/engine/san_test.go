@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+func TestSan(t *testing.T) {
+	tests := []struct {
+		fen     string
+		uci     string
+		san     string
+		altSans []string // other notations ParseSan accepts
+	}{
+		// Disambiguation by file, by rank, and by both
+		{"4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1", "b1d2", "Nbd2", nil},
+		{"4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1", "f1d2", "Nfd2", nil},
+		{"4k3/8/8/R7/8/8/8/R3K3 w - - 0 1", "a1a3", "R1a3", nil},
+		{"4k3/8/8/R7/8/8/8/R3K3 w - - 0 1", "a5a3", "R5a3", nil},
+		{"4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1", "a1b2", "Qa1b2", nil},
+		{"4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1", "a3b2", "Q3b2", nil},
+		{"4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1", "c1b2", "Qcb2", nil},
+		// Not ambiguous if the other piece is pinned
+		{"k3r3/8/8/8/8/8/4N3/2N1K3 w - - 0 1", "c1d3", "Nd3", nil},
+		// Pawns
+		{dragon.Startpos, "e2e4", "e4", nil},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", "exd6", nil},
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e7e8q", "e8=Q", []string{"e8Q"}},
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e7e8n", "e8=N", []string{"e8N"}},
+		{"3r4/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e7d8q", "exd8=Q", []string{"exd8Q"}},
+		// Castling
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "O-O", []string{"0-0"}},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1c1", "O-O-O", []string{"0-0-0"}},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8g8", "O-O", []string{"0-0"}},
+		// Check and mate
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "a1a8", "Ra8+", []string{"Ra8", "Ra8!"}},
+		{"6k1/5ppp/8/8/8/8/8/R3K3 w - - 0 1", "a1a8", "Ra8#", []string{"Ra8", "Ra8+"}},
+		{"6k1/5ppp/8/8/8/8/8/R3K3 w - - 0 1", "e1e2", "Ke2", nil},
+	}
+
+	for _, test := range tests {
+		board := dragon.ParseFen(test.fen)
+		move, err := dragon.ParseMove(test.uci)
+		if err != nil {
+			t.Fatalf("Bad test move %s: %v", test.uci, err)
+		}
+		// ParseMove doesn't know about the position
+		for _, legalMove := range board.GenerateLegalMoves() {
+			if legalMove.String() == move.String() {
+				move = legalMove
+			}
+		}
+
+		if san := MoveToSan(&board, move); san != test.san {
+			t.Errorf("MoveToSan %s in %s is %s expected %s\n", test.uci, test.fen, san, test.san)
+		}
+		for _, san := range append([]string{test.san, test.uci}, test.altSans...) {
+			if parsed, err := ParseSan(&board, san); err != nil || parsed != move {
+				t.Errorf("ParseSan %s in %s is %s (%v) expected %s\n", san, test.fen, &parsed, err, test.uci)
+			}
+		}
+		if board.ToFen() != test.fen {
+			t.Errorf("SAN conversion changed the position %s to %s\n", test.fen, board.ToFen())
+		}
+	}
+
+	// Moves that aren't legal, or are ambiguous without disambiguation
+	board := dragon.ParseFen("4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1")
+	for _, san := range []string{"Nd2", "e4", "Nxd2", "O-O"} {
+		if move, err := ParseSan(&board, san); err == nil {
+			t.Errorf("ParseSan %s is %s expected an error\n", san, &move)
+		}
+	}
+}
@@ -3,11 +3,15 @@ package engine
 import (
 	"errors"
 	"fmt"
+	"math"
 	"sort"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	dragon "github.com/Bubblyworld/dragontoothmg"
+
+	"clanpj/lisao/syzygy"
 )
 
 // MUST be a power of 2 cos we use & instead of % for fast hash table index
@@ -15,51 +19,138 @@ const TTSize = 1024 * 1024
 
 //const QttSize = 256*1024
 
-// Want this to be per-thread, but for now we're single-threaded so global is ok
-var tt []TTEntryT = make([]TTEntryT, TTSize)
-
-func ResetTT() {
-	tt = make([]TTEntryT, TTSize)
-}
-
 // MUST be a power of 2 cos we use & instead of % for fast hash table index
 const QttSize = 64 * 1024
 
 //const QttSize = 256*1024
 
-// Want this to be per-thread, but for now we're single-threaded so global is ok
-var qtt []QSearchTTEntryT = make([]QSearchTTEntryT, QttSize)
-
-func ResetQtt() {
-	qtt = make([]QSearchTTEntryT, QttSize)
-}
-
 // Search tree encapsulation
 type SearchT struct {
+	engine      *EngineT // hash tables and eval weights
+	opts        *OptionsT
 	board       *dragon.Board
 	ht          HistoryTableT
+	mh          *MoveHistoryT
 	deepKillers []dragon.Move
 	stats       *SearchStatsT
 	timeout     *uint32
+	// The move made at each depth from root on the current search path - NoMove for null moves
+	pathMoves [MaxDepth + 1]dragon.Move
+	// The number of extension plies on the current search path to each depth from root
+	pathExtensions [MaxDepth + 1]int
+	// Stop the search after this many nodes - 0 for no limit
+	nodeLimit uint64
+	// Only search these moves at the root - nil for all legal moves
+	rootMoves []dragon.Move
+	// Search info reporting - the listener is nil if nobody is listening
+	info             InfoFuncT
+	startTime        time.Time
+	lastProgressTime time.Time
+	depth            int         // the iterative deepening depth
+	currMove         dragon.Move // the root move currently being searched
+	currMoveNumber   int
 }
 
-func NewSearchT(board *dragon.Board, ht HistoryTableT, deepKillers []dragon.Move, stats *SearchStatsT, timeout *uint32) *SearchT {
+func NewSearchT(e *EngineT, opts *OptionsT, board *dragon.Board, ht HistoryTableT, mh *MoveHistoryT, deepKillers []dragon.Move, stats *SearchStatsT, timeout *uint32) *SearchT {
 	return &SearchT{
+		engine:      e,
+		opts:        opts,
 		board:       board,
 		ht:          ht,
+		mh:          mh,
 		deepKillers: deepKillers,
 		stats:       stats,
 		timeout:     timeout,
 	}
 }
 
-// Return eval from white's perspective, and the best move plus some search stats
+// The move leading to the current node, or NoMove at the root or after a null move
+func (s *SearchT) prevMove(depthFromRoot int) dragon.Move {
+	if depthFromRoot == 0 {
+		return NoMove
+	}
+	return s.pathMoves[depthFromRoot-1]
+}
+
+// Stop the search if we've reached the node limit - the search then unwinds just like a time-out
+func (s *SearchT) checkNodeLimit() {
+	if s.nodeLimit != 0 && s.stats.Nodes >= s.nodeLimit {
+		atomic.StoreUint32(s.timeout, 1)
+	}
+}
+
+// Report progress if it's been at least ProgressIntervalMs since the last report - we check the time every 1024 non-leaf nodes
+func (s *SearchT) checkProgress() {
+	if s.info == nil || s.stats.NonLeafs&1023 != 0 {
+		return
+	}
+	now := time.Now()
+	if now.Sub(s.lastProgressTime) < time.Duration(s.opts.ProgressIntervalMs)*time.Millisecond {
+		return
+	}
+	s.lastProgressTime = now
+
+	info := s.searchInfo(InfoProgress)
+	info.CurrMove, info.CurrMoveNumber = s.currMove, s.currMoveNumber
+	s.info(info)
+}
+
+// Search info with the fields common to all kinds of info filled in
+func (s *SearchT) searchInfo(kind InfoKindT) SearchInfoT {
+	elapsedMs := uint64(time.Since(s.startTime) / time.Millisecond)
+	var nps uint64
+	if elapsedMs > 0 {
+		nps = s.stats.Nodes * 1000 / elapsedMs
+	}
+	return SearchInfoT{
+		Kind:      kind,
+		Depth:     s.depth,
+		SelDepth:  int(s.stats.SelDepth),
+		Nodes:     s.stats.Nodes,
+		ElapsedMs: elapsedMs,
+		Nps:       nps,
+		HashFull:  s.engine.TTHashFull(),
+		TBHits:    s.stats.TBHits,
+	}
+}
+
+// Send free text to the info listener - the operands are formatted as by fmt.Println
+func (s *SearchT) infoString(a ...interface{}) {
+	if s.info != nil {
+		s.info(SearchInfoT{Kind: InfoString, Text: strings.TrimSuffix(fmt.Sprintln(a...), "\n")})
+	}
+}
+
+// The legal moves that are in searchMoves - nil if there are none, in which case we search all legal moves
+func restrictRootMoves(board *dragon.Board, searchMoves []dragon.Move) []dragon.Move {
+	var rootMoves []dragon.Move
+	for _, move := range board.GenerateLegalMoves() {
+		for _, searchMove := range searchMoves {
+			if move == searchMove {
+				rootMoves = append(rootMoves, move)
+				break
+			}
+		}
+	}
+	return rootMoves
+}
+
+// Return the best move, eval and PV plus some search stats
 // Does iterative deepening until depth or timeout
-// If depth param != 0 then we do fixed depth search.
-// If targetTimeMs != 0 then we try to limit tame waste by returning early from a full search at some depth when
+// The move history should be kept for the whole game so that quiet move ordering improves from move to move; if
+//   it is nil then we start with an empty move history.
+// If the request depth != 0 then we do fixed depth search.
+// If the request TargetTimeMs != 0 then we try to limit tame waste by returning early from a full search at some depth when
 //   we reckon there is not enough time to do the full next-level search.
-// Return best-move, eval, stats, final-depth, error
-func Search(board *dragon.Board, ht HistoryTableT, depth int, targetTimeMs int, timeout *uint32) (dragon.Move, EvalCp, SearchStatsT, int, error) {
+// The limits can further restrict the search by node count, by stopping at a short enough mate, or by root move.
+// If the request has an Info listener then it gets the results of each depth, free text, and progress reports at most
+//   every ProgressIntervalMs while the search is running.
+func Search(req SearchRequestT) (SearchResultT, error) {
+	board, timeout, limits := req.Board, req.Timeout, req.Limits
+	// Without a timeout the search only stops at the depth or limits - but the node limit still needs somewhere to signal
+	if timeout == nil {
+		timeout = new(uint32)
+	}
 	var deepKillers [MaxDepth]dragon.Move
 	var stats SearchStatsT
 	var bestMove = NoMove
@@ -69,65 +160,124 @@ func Search(board *dragon.Board, ht HistoryTableT, depth int, targetTimeMs int,
 	var fullDepth = 0
 	var fullBestMove = NoMove
 	var fullEval EvalCp = 0
+	var fullPV []dragon.Move
 	// TODO our eval is somewhat unstable between odd/even plies, so we smooth this by returning our
 	//   final eval as the average of the evals for the last two plies.
 	var prevFullEval EvalCp = 0
 
 	// Best results from previous depth in case the timeout depth didn't get as far as returning a result
 
+	// Root evals (from the root mover's perspective) of the last completed searches by depth parity - the aspiration
+	// window is centred on the eval from two plies back since our eval is unstable between odd/even plies.
+	var parityNegaEvals [2]EvalCp
+	var hasParityNegaEval [2]bool
+	// Did the root eval drop at the last completed depth? If so we allow more time to resolve the problem.
+	var isScoreDropped = false
+
 	var maxDepthToGo = MaxDepth
-	if depth > 0 {
-		maxDepthToGo = depth
+	if req.Depth > 0 {
+		maxDepthToGo = req.Depth
+	}
+	// In mate mode a mate in N moves is at most 2N-1 plies deep, so if we haven't found one a little deeper than that then
+	// there isn't one - the margin covers mates that are hidden by reductions at the nominal depth
+	if mateDepth := 2*limits.Mate - 1 + MateSearchMarginPlies; limits.Mate > 0 && mateDepth < maxDepthToGo {
+		maxDepthToGo = mateDepth
 	}
 
 	originalStart := time.Now()
 
-	fmt.Println("info string using", SearchAlgorithmString(), "max depth", maxDepthToGo)
+	opts := req.Options
+	if opts == nil {
+		opts = NewOptions()
+	}
+
+	e := req.Engine
+	if e == nil {
+		e = NewEngine(opts)
+	} else {
+		e.configurePawnHash(opts)
+	}
+
+	// Results from previous searches are less relevant
+	mh := e.moveHistory
+	mh.Decay()
+
+	s := NewSearchT(e, opts, board, req.History, mh, deepKillers[:], &stats, timeout)
+	s.nodeLimit = limits.Nodes
+	s.rootMoves = restrictRootMoves(board, limits.SearchMoves)
+	s.info = req.Info
+	s.startTime, s.lastProgressTime = originalStart, originalStart
+
+	// If the position is in the tablebases then play the DTZ-optimal move without searching
+	if s.opts.UseSyzygy && s.rootMoves == nil {
+		if tbMove, wdl, dtz, ok := syzygy.ProbeRoot(board); ok {
+			stats.TBHits++
+			s.infoString("syzygy root probe wdl", wdl, "dtz", dtz)
+			negaEval := s.negaTBEval(wdl, 0)
+			eval = negaEval
+			if !board.Wtomove {
+				eval = -eval
+			}
+			scoreType, score := negaScore(negaEval)
+			return SearchResultT{BestMove: tbMove, PV: []dragon.Move{tbMove}, Eval: eval, ScoreType: scoreType, Score: score, Stats: stats}, nil
+		}
+	}
 
-	s := NewSearchT(board, ht, deepKillers[:], &stats, timeout)
+	s.infoString("using", s.opts.SearchAlgorithmString(), "max depth", maxDepthToGo)
 
 	var depthToGo int
+	// Set in mate mode once we've found a short enough mate
+	var isMateFound = false
 	// Iterative deepening
 	for depthToGo = MinDepth; depthToGo <= maxDepthToGo; depthToGo++ {
-		// Time the search
-		start := time.Now()
+		s.depth = depthToGo
 
-		switch SearchAlgorithm {
+		var negaEval EvalCp
+		switch s.opts.SearchAlgorithm {
 		case NegAlphaBeta:
 			// Use the best move from the previous depth as the killer move for this depth
-			var negaEval EvalCp
-			bestMove, negaEval = s.NegAlphaBeta(depthToGo /*depthFromRoot*/, 0, YourCheckMateEval, MyCheckMateEval, fullBestMove, false)
+			parity := depthToGo & 1
+			if s.opts.UseAspirationWindows && depthToGo >= s.opts.AspirationMinDepth && hasParityNegaEval[parity] {
+				var isFailedLow bool
+				bestMove, negaEval, isFailedLow = s.aspirationSearch(depthToGo, parityNegaEvals[parity], fullBestMove)
+				isScoreDropped = isFailedLow
+			} else {
+				bestMove, negaEval = s.NegAlphaBeta(depthToGo /*depthFromRoot*/, 0, YourCheckMateEval, MyCheckMateEval, fullBestMove, false)
+			}
+			if !isTimedOut(timeout) {
+				parityNegaEvals[parity], hasParityNegaEval[parity] = negaEval, true
+				// Mate in N moves is at most 2N-1 plies from the root
+				isMateFound = limits.Mate > 0 && negaEval >= MyCheckMateEval-EvalCp(2*limits.Mate-1)
+			}
 			eval = negaEval
 			if !board.Wtomove {
 				eval = -negaEval
 			}
 
 		default:
-			return NoMove, 0, stats, 0, errors.New("bot: unrecognised search algorithm")
+			return SearchResultT{Stats: stats}, errors.New("bot: unrecognised search algorithm")
 		}
 
-		elapsedSecs := time.Since(start).Seconds()
+		pv := principalVariation(e.tt, board, bestMove, depthToGo)
 
-		// Reduce the output noise
-		if maxDepthToGo <= 4 || depthToGo > 0 && bestMove != NoMove {
-			// UCI wants eval always from white perspective
-			evalForWhite := eval
-			if !board.Wtomove {
-				evalForWhite = -eval
-			}
-			// Print summary stats for the depth - slightly inaccurate because it includes accumulation of previous depths
-			fmt.Println("info depth", depthToGo, "score cp", evalForWhite, "nodes", stats.Nodes, "time", uint64(elapsedSecs*1000), "nps", uint64(float64(stats.Nodes)/elapsedSecs), "pv", &bestMove)
+		// Only report completed depths, and reduce the output noise
+		if s.info != nil && !isTimedOut(timeout) && (maxDepthToGo <= 4 || depthToGo > 0 && bestMove != NoMove) {
+			// Summary stats for the depth - slightly inaccurate because it includes accumulation of previous depths
+			info := s.searchInfo(InfoDepth)
+			info.ScoreType, info.Score = negaScore(negaEval)
+			info.PV = pv
+			s.info(info)
 		}
 
 		// Have we timed out? If so, then ignore the results for this depth unless we got a valid partial result
 		if isTimedOut(timeout) {
-			fmt.Println("info string timed out in search for depth", depthToGo)
+			s.infoString("timed out in search for depth", depthToGo)
 			if bestMove == NoMove {
-				fmt.Println("info string no useful result before time-out at depth", depthToGo)
+				s.infoString("no useful result before time-out at depth", depthToGo)
 				break
-			} else if SearchAlgorithm != NegAlphaBeta || !UseKillerMoves {
+			} else if s.opts.SearchAlgorithm != NegAlphaBeta || !s.opts.UseKillerMoves {
 				// Only NegAlphaBeta supports a valid partial result and only if UseKillerMoves is enabled
-				fmt.Println("info string ignoring partial search result - only supported for NegAlphaBeta with UseKillerMoves enabled", depthToGo)
+				s.infoString("ignoring partial search result - only supported for NegAlphaBeta with UseKillerMoves enabled", depthToGo)
 				break
 			}
 		}
@@ -136,17 +286,28 @@ func Search(board *dragon.Board, ht HistoryTableT, depth int, targetTimeMs int,
 		prevFullEval = fullEval
 		fullEval = eval
 		fullDepth = depthToGo
+		fullPV = pv
 
 		// Then always bail on time-out
 		if isTimedOut(timeout) {
 			break
 		}
 
+		// In mate mode we're done as soon as we've found a mate
+		if isMateFound {
+			s.infoString("found mate in", limits.Mate, "or less at depth", depthToGo)
+			break
+		}
+
 		// Bail early if we don't think we can get another full search level done
-		if targetTimeMs > 0 {
+		if req.TargetTimeMs > 0 {
 			totalElapsedSecs := time.Since(originalStart).Seconds()
 			totalElapsedMs := int(totalElapsedSecs * 1000)
-			cutoffMs := targetTimeMs * SearchCutoffPercent / 100
+			cutoffPercent := s.opts.SearchCutoffPercent
+			if isScoreDropped {
+				cutoffPercent = s.opts.ScoreDropCutoffPercent
+			}
+			cutoffMs := req.TargetTimeMs * cutoffPercent / 100
 			if totalElapsedMs > cutoffMs {
 				break
 			}
@@ -155,11 +316,101 @@ func Search(board *dragon.Board, ht HistoryTableT, depth int, targetTimeMs int,
 
 	// If we didn't get a move at all then barf
 	if fullBestMove == NoMove {
-		return NoMove, 0, stats, fullDepth, errors.New("bot: no legal move found in search")
+		return SearchResultT{Depth: fullDepth, Stats: stats}, errors.New("bot: no legal move found in search")
+	}
+
+	result := SearchResultT{BestMove: fullBestMove, PV: fullPV, Depth: fullDepth, SelDepth: int(stats.SelDepth), Stats: stats}
+	if len(fullPV) > 1 {
+		result.PonderMove = fullPV[1]
+	}
+
+	// We smooth the odd/even instability by using the average eval of the last two depths - but a mate eval is exact
+	result.Eval = (fullEval + prevFullEval) / 2
+	if fullEval > MyCheckMateEval-MaxDepth || fullEval < YourCheckMateEval+MaxDepth {
+		result.Eval = fullEval
+	}
+	negaEval := result.Eval
+	if !board.Wtomove {
+		negaEval = -negaEval
+	}
+	result.ScoreType, result.Score = negaScore(negaEval)
+
+	return result, nil
+}
+
+// Search the root with an aspiration window centred on the expected eval, widening the window on fail-low or fail-high.
+// Returns the best move, eval and whether the search failed low at least once - i.e. the eval dropped below the window.
+func (s *SearchT) aspirationSearch(depthToGo int, expectedNegaEval EvalCp, killer dragon.Move) (dragon.Move, EvalCp, bool) {
+	// Mate evals jump around too much for a narrow window to be useful
+	if expectedNegaEval <= -TBWinEval+MaxDepth || TBWinEval-MaxDepth <= expectedNegaEval {
+		bestMove, negaEval := s.NegAlphaBeta(depthToGo /*depthFromRoot*/, 0, YourCheckMateEval, MyCheckMateEval, killer, false)
+		return bestMove, negaEval, false
+	}
+
+	isFailedLow := false
+	window := s.opts.AspirationWindow
+	alpha := aspirationBound(int(expectedNegaEval) - window)
+	beta := aspirationBound(int(expectedNegaEval) + window)
+	for {
+		bestMove, negaEval := s.NegAlphaBeta(depthToGo /*depthFromRoot*/, 0, alpha, beta, killer, false)
+		if isTimedOut(s.timeout) {
+			return bestMove, negaEval, isFailedLow
+		}
+
+		// Widen the window on the failing side only, re-centred on the (fail-soft) result
+		window *= 2
+		if negaEval <= alpha && alpha != YourCheckMateEval {
+			s.stats.AspirationLows++
+			isFailedLow = true
+			alpha = aspirationBound(int(negaEval) - window)
+		} else if beta <= negaEval && beta != MyCheckMateEval {
+			s.stats.AspirationHighs++
+			beta = aspirationBound(int(negaEval) + window)
+			// The fail-high move is at least as good as the previous best move, so try it first
+			killer = bestMove
+		} else {
+			return bestMove, negaEval, isFailedLow
+		}
 	}
+}
 
-	// We smooth the odd/even instability by using the average eval of the last two depths
-	return fullBestMove, (fullEval + prevFullEval) / 2, stats, fullDepth, nil
+// Clamp an aspiration window bound to the eval range
+func aspirationBound(bound int) EvalCp {
+	if bound <= int(YourCheckMateEval) {
+		return YourCheckMateEval
+	}
+	if int(MyCheckMateEval) <= bound {
+		return MyCheckMateEval
+	}
+	return EvalCp(bound)
+}
+
+// Late move reductions by [depthToGo][move index] - logarithmic in both
+var lmrReductions [64][64]int
+
+func init() {
+	for depthToGo := 1; depthToGo < 64; depthToGo++ {
+		for i := 1; i < 64; i++ {
+			r := int(LMRBase + math.Log(float64(depthToGo))*math.Log(float64(i))/LMRDivisor)
+			// Reductions are even to cope with our even/odd ply eval instability (see null-move)
+			lmrReductions[depthToGo][i] = r &^ 1
+		}
+	}
+}
+
+// The reduction for a late move, leaving at least a depth 1 search
+func lmrReduction(depthToGo int, i int) int {
+	if depthToGo > 63 {
+		depthToGo = 63
+	}
+	if i > 63 {
+		i = 63
+	}
+	r := lmrReductions[depthToGo][i]
+	if r > depthToGo-2 {
+		r = (depthToGo - 2) &^ 1
+	}
+	return r
 }
 
 func isTimedOut(timeout *uint32) bool {
@@ -167,13 +418,24 @@ func isTimedOut(timeout *uint32) bool {
 }
 
 // TT move is prefered to all others
-const ttMoveValue uint8 = 255
+const ttMoveValue int32 = 1 << 30
 
 // ...then the killer move
-const killerValue uint8 = 254
+const killerValue int32 = ttMoveValue - 1
 
 // ...then the second (deep) killer
-const killer2Value uint8 = 253
+const killer2Value int32 = ttMoveValue - 2
+
+// ...then promotions and captures
+const captureValueBase int32 = 1 << 20
+
+// ...then the countermove, ahead of the other quiet moves
+const counterMoveValue int32 = captureValueBase - 1
+
+// ...then quiet moves, ordered by history in the range +/- maxHistory
+
+// Captures that lose material according to SEE go after all of the quiet moves
+const losingCaptureValue int32 = -(1 << 20)
 
 // Indexed by promo piece type - only N, B, R, Q valid
 var promoMOValue = [8]uint8{0, 0 /*N*/, 105 /*B*/, 103 /*R*/, 104 /*Q*/, 109, 0, 0}
@@ -196,7 +458,7 @@ var captureMOValue = [8][8]uint8{
 // Sorting interface
 type byMoValueDesc struct {
 	moves  []dragon.Move
-	values []uint8
+	values []int32
 }
 
 func (mo *byMoValueDesc) Len() int {
@@ -218,9 +480,11 @@ func (mo *byMoValueDesc) Less(i, j int) bool {
 // 1. Promotions by promo type
 // 2. MMV-LVA for captures
 //     (most valuable victim first, then least-valuable attacker second)
-func orderMoves(board *dragon.Board, moves []dragon.Move, ttMove dragon.Move, killer dragon.Move, killer2 dragon.Move, killersStat *uint64, deepKillersStat *uint64) {
+// 3. Quiet moves by history heuristic if mh is not nil
+// 4. Captures that lose material according to SEE
+func orderMoves(opts *OptionsT, board *dragon.Board, moves []dragon.Move, ttMove dragon.Move, killer dragon.Move, killer2 dragon.Move, counterMove dragon.Move, mh *MoveHistoryT, killersStat *uint64, deepKillersStat *uint64) {
 	// Value of each move - nothing to do with any other eval, just a local ordering metric
-	values := make([]uint8, len(moves))
+	values := make([]int32, len(moves))
 	for i, move := range moves {
 		if move == ttMove {
 			values[i] = ttMoveValue
@@ -230,14 +494,23 @@ func orderMoves(board *dragon.Board, moves []dragon.Move, ttMove dragon.Move, ki
 		} else if move == killer2 {
 			*deepKillersStat++
 			values[i] = killer2Value
+		} else if move == counterMove {
+			values[i] = counterMoveValue
 		} else {
-			from, to := move.From(), move.To()
-			attacker := board.PieceAt(from)
-			// We miss en-passant but it's not worth the effort to do properly
-			victim := board.PieceAt(to)
+			attacker := board.PieceAt(move.From())
+			victim := moveVictim(board, move)
 			promoPiece := move.Promote()
 
-			values[i] = promoMOValue[promoPiece] + captureMOValue[victim][attacker]
+			// Only captures of a less valuable piece can lose material
+			if opts.UseSEEMoveOrdering && victim != dragon.Nothing && seePieceVals[victim] < seePieceVals[attacker] && SEE(board, move) < 0 {
+				values[i] = losingCaptureValue
+			} else if victim != dragon.Nothing || promoPiece != dragon.Nothing {
+				values[i] = captureValueBase + int32(promoMOValue[promoPiece]) + int32(captureMOValue[victim][attacker])
+			} else if mh != nil && opts.UseHistoryHeuristic {
+				values[i] = mh.score(board.Wtomove, move)
+			} else {
+				values[i] = int32(captureMOValue[dragon.Nothing][attacker])
+			}
 		}
 	}
 
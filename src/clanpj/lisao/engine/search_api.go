@@ -0,0 +1,126 @@
+package engine
+
+import (
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Search limits in addition to depth and time - the zero value has no extra limits
+type SearchLimitsT struct {
+	Nodes       uint64        // stop the search after this many nodes - 0 for no limit
+	Mate        int           // stop the search once we find a mate in this many moves - 0 for no limit
+	SearchMoves []dragon.Move // only search these root moves - all legal moves if empty
+}
+
+// Everything the search needs to know
+type SearchRequestT struct {
+	Options      *OptionsT // nil for the default options
+	Engine       *EngineT  // hash tables, eval weights and move ordering history - keep this for the whole game, or nil for a new engine
+	Board        *dragon.Board
+	History      HistoryTableT // positions so far in the game, for repetition detection
+	Depth        int           // fixed depth search if non-zero
+	TargetTimeMs int           // if non-zero we return early when we reckon there isn't time for another full depth
+	Limits       SearchLimitsT
+	Info         InfoFuncT // search info listener - nil for none
+	Timeout      *uint32   // the search bails as soon as this is non-zero - nil for no external time-out
+}
+
+// UCI score type
+type ScoreTypeT int
+
+const (
+	ScoreCp   ScoreTypeT = iota // centipawns
+	ScoreMate                   // moves to mate - negative if we're being mated
+)
+
+// The outcome of a search
+type SearchResultT struct {
+	BestMove   dragon.Move
+	PonderMove dragon.Move   // the expected reply to BestMove - NoMove if we don't know it
+	PV         []dragon.Move // principal variation, starting with BestMove
+	Eval       EvalCp        // from white's perspective
+	ScoreType  ScoreTypeT    // Score is from the root mover's perspective, like UCI
+	Score      int
+	Depth      int // the last completed depth - 0 if we played straight from the tablebases
+	SelDepth   int
+	Stats      SearchStatsT
+}
+
+// The kind of search info
+type InfoKindT int
+
+const (
+	InfoString   InfoKindT = iota // free text
+	InfoDepth                     // a completed depth - Score and PV are valid
+	InfoProgress                  // periodic progress within a depth - CurrMove and CurrMoveNumber are valid
+)
+
+// Search info, delivered to the listener while the search is running
+type SearchInfoT struct {
+	Kind           InfoKindT
+	Text           string // only valid for InfoString
+	Depth          int
+	SelDepth       int
+	ScoreType      ScoreTypeT
+	Score          int
+	CurrMove       dragon.Move
+	CurrMoveNumber int // 1-based index of CurrMove in the root move order
+	Nodes          uint64
+	ElapsedMs      uint64
+	Nps            uint64
+	HashFull       int // TT occupancy in permill
+	TBHits         uint64
+	PV             []dragon.Move
+}
+
+// Search info listener - called from the search goroutine so it should return quickly
+type InfoFuncT func(info SearchInfoT)
+
+// Score from a root eval from the root mover's perspective
+// Mate evals count down from the checkmate eval by plies from the root.
+func negaScore(negaEval EvalCp) (ScoreTypeT, int) {
+	if negaEval > MyCheckMateEval-MaxDepth {
+		return ScoreMate, (int(MyCheckMateEval-negaEval) + 1) / 2
+	}
+	if negaEval < YourCheckMateEval+MaxDepth {
+		return ScoreMate, -(int(negaEval-YourCheckMateEval) + 1) / 2
+	}
+	return ScoreCp, int(negaEval)
+}
+
+// The principal variation of a search to depth - the best move then the TT best moves, for as long as they are legal
+// and don't repeat a position.
+func principalVariation(tt []TTEntryT, board *dragon.Board, bestMove dragon.Move, depth int) []dragon.Move {
+	b := *board
+	seen := map[uint64]bool{b.Hash(): true}
+	var pv []dragon.Move
+
+	for move := bestMove; move != NoMove && len(pv) < depth && isLegalMove(&b, move); {
+		pv = append(pv, move)
+		b.Apply(move)
+		if seen[b.Hash()] {
+			break
+		}
+		seen[b.Hash()] = true
+
+		move = NoMove
+		if ttEntry, isTTHit := probeTT(tt, b.Hash()); isTTHit {
+			// Pick the right parity if it's available, else anything
+			ttpEntry := &ttEntry.parityHits[depthToGoParity(depth-len(pv))]
+			if ttpEntry.evalType == TTInvalid {
+				ttpEntry = &ttEntry.parityHits[depthToGoParity(depth-len(pv))^1]
+			}
+			move = ttpEntry.bestMove
+		}
+	}
+
+	return pv
+}
+
+func isLegalMove(board *dragon.Board, move dragon.Move) bool {
+	for _, legalMove := range board.GenerateLegalMoves() {
+		if legalMove == move {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Reductions must be even, never decrease with depth or move index, and always leave at least a depth 1 search
+func TestLMRReductions(t *testing.T) {
+	opts := NewOptions()
+	for depthToGo := opts.LMRMinDepth; depthToGo < 100; depthToGo++ {
+		for i := opts.LMRMinMoveIndex; i < 100; i++ {
+			r := lmrReduction(depthToGo, i)
+			if r < 0 || r&1 != 0 || depthToGo-r-1 < 1 {
+				t.Fatalf("Bad reduction %d for depth %d move %d\n", r, depthToGo, i)
+			}
+			if r < lmrReduction(depthToGo-1, i) || r < lmrReduction(depthToGo, i-1) {
+				t.Fatalf("Reduction %d for depth %d move %d is less than for a shallower depth or earlier move\n", r, depthToGo, i)
+			}
+		}
+	}
+	if lmrReduction(12, 20) == 0 {
+		t.Errorf("No reduction for a late move at depth 12\n")
+	}
+}
+
+// A bad guess at the root eval must fail and be re-searched until the eval is inside the window
+func TestAspirationSearch(t *testing.T) {
+	board := dragon.ParseFen("4k3/8/8/8/8/8/8/QQ2K3 w - - 0 1")
+	var stats SearchStatsT
+	var timeout uint32
+	s := NewSearchT(NewEngine(nil), NewOptions(), &board, make(HistoryTableT), NewMoveHistory(), make([]dragon.Move, MaxDepth), &stats, &timeout)
+
+	_, negaEval, isFailedLow := s.aspirationSearch(2, 0, NoMove)
+	if negaEval < 1000 || isFailedLow || stats.AspirationHighs == 0 {
+		t.Errorf("Expected a fail-high re-search with eval > 1000 but got eval %d, failed-low %v and %d fail-highs\n", negaEval, isFailedLow, stats.AspirationHighs)
+	}
+
+	if aspirationBound(int(MyCheckMateEval)+100) != MyCheckMateEval || aspirationBound(int(YourCheckMateEval)-100) != YourCheckMateEval {
+		t.Errorf("Aspiration bounds are not clamped to the eval range\n")
+	}
+}
+
+// Frontier pruning must not hide a mate in 2 or a free queen, and must prune something
+func TestFrontierPruning(t *testing.T) {
+	opts := NewOptions()
+	opts.UseReverseFutilityPruning, opts.UseRazoring, opts.UseFutilityPruning = true, true, true
+
+	var tests = []struct {
+		fen  string
+		move string
+	}{
+		{"r5k1/5ppp/8/8/8/8/4RPPP/4R1K1 w - - 0 1", "e2e8"},
+		{"r1b1kbnr/pppp1ppp/2n5/4p1q1/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 1", "f3g5"},
+	}
+
+	var stats SearchStatsT
+	for i, test := range tests {
+		board := dragon.ParseFen(test.fen)
+		var timeout uint32
+		s := NewSearchT(NewEngine(opts), opts, &board, make(HistoryTableT), NewMoveHistory(), make([]dragon.Move, MaxDepth), &stats, &timeout)
+
+		bestMove, eval := s.NegAlphaBeta(4, 0, YourCheckMateEval, MyCheckMateEval, NoMove, false)
+		if bestMove.String() != test.move {
+			t.Errorf("Test %d: expected %s but got %s with eval %d\n", i, test.move, &bestMove, eval)
+		}
+	}
+	if stats.RevFutilityCuts+stats.RazoringCuts+stats.FutilityPrunes == 0 {
+		t.Errorf("Nothing was pruned\n")
+	}
+}
+
+// Q-search only sees the back-rank mate Rd8# if it includes quiet checks
+func TestQSearchChecks(t *testing.T) {
+	for _, useQSearchChecks := range []bool{false, true} {
+		opts := NewOptions()
+		opts.UseQSearchChecks = useQSearchChecks
+		board := dragon.ParseFen("6k1/5ppp/8/8/8/8/8/3R2K1 w - - 0 1")
+		var stats SearchStatsT
+		var timeout uint32
+		s := NewSearchT(NewEngine(opts), opts, &board, make(HistoryTableT), NewMoveHistory(), make([]dragon.Move, MaxDepth), &stats, &timeout)
+
+		bestMove, eval, _ := s.QSearchNegAlphaBeta(opts.QSearchDepth, 0, 0, YourCheckMateEval, MyCheckMateEval, NoMove)
+		if isMate := bestMove.String() == "d1d8" && eval > TBWinEval; isMate != useQSearchChecks {
+			t.Errorf("With q-search checks %v got move %s eval %d\n", useQSearchChecks, &bestMove, eval)
+		}
+	}
+}
+
+// Negative contempt makes the engine play for a repetition draw from an equal position, and positive contempt avoids it
+func TestContempt(t *testing.T) {
+	board := dragon.ParseFen("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	repeatMove, _ := dragon.ParseMove("g1f3")
+	repeatBoard := board
+	repeatBoard.Apply(repeatMove)
+
+	for _, contempt := range []int{-500, 500} {
+		opts := NewOptions()
+		opts.Contempt = contempt
+		ht := make(HistoryTableT)
+		ht.Add(repeatBoard.Hash())
+		var stats SearchStatsT
+		var timeout uint32
+		s := NewSearchT(NewEngine(opts), opts, &board, ht, NewMoveHistory(), make([]dragon.Move, MaxDepth), &stats, &timeout)
+
+		bestMove, eval := s.NegAlphaBeta(2, 0, YourCheckMateEval, MyCheckMateEval, NoMove, false)
+		if isRepetition := bestMove == repeatMove; isRepetition != (contempt < 0) {
+			t.Errorf("With contempt %d got move %s eval %d\n", contempt, &bestMove, eval)
+		}
+		if contempt < 0 && eval != EvalCp(-contempt) {
+			t.Errorf("With contempt %d expected draw eval %d but got %d\n", contempt, -contempt, eval)
+		}
+	}
+}
+
+// Node limits, mate mode and root move restriction each stop or constrain the search
+func TestSearchLimits(t *testing.T) {
+	// No external timeout - the node limit still stops the search
+	nodesBoard := dragon.ParseFen("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 1")
+	result, err := Search(SearchRequestT{Board: &nodesBoard, History: make(HistoryTableT), Limits: SearchLimitsT{Nodes: 5000}})
+	if err != nil || result.Stats.Nodes < 5000 || result.Stats.Nodes > 6000 {
+		t.Errorf("Node limit 5000: searched %d nodes, error %v\n", result.Stats.Nodes, err)
+	}
+
+	mateBoard := dragon.ParseFen("r5k1/5ppp/8/8/8/8/4RPPP/4R1K1 w - - 0 1")
+	var timeout uint32
+	result, err = Search(SearchRequestT{Board: &mateBoard, History: make(HistoryTableT), Limits: SearchLimitsT{Mate: 2}, Timeout: &timeout})
+	if err != nil || result.BestMove.String() != "e2e8" || result.ScoreType != ScoreMate || result.Score != 2 || result.Depth > 3 {
+		t.Errorf("Mate in 2: got move %s score %d (type %d) at depth %d, error %v\n", &result.BestMove, result.Score, result.ScoreType, result.Depth, err)
+	}
+
+	// With no mate to be found the search still stops, just past the mate distance
+	noMateBoard := dragon.ParseFen(dragon.Startpos)
+	timeout = 0
+	result, err = Search(SearchRequestT{Board: &noMateBoard, History: make(HistoryTableT), Limits: SearchLimitsT{Mate: 1}, Timeout: &timeout})
+	if err != nil || result.ScoreType == ScoreMate || result.Depth != 1+MateSearchMarginPlies {
+		t.Errorf("No mate in 1: got score %d (type %d) at depth %d, error %v\n", result.Score, result.ScoreType, result.Depth, err)
+	}
+
+	searchMove, _ := dragon.ParseMove("a2a3")
+	startBoard := dragon.ParseFen(dragon.Startpos)
+	timeout = 0
+	result, err = Search(SearchRequestT{Board: &startBoard, History: make(HistoryTableT), Depth: 4, Limits: SearchLimitsT{SearchMoves: []dragon.Move{searchMove}}, Timeout: &timeout})
+	if err != nil || result.BestMove != searchMove {
+		t.Errorf("Search moves a2a3: got move %s, error %v\n", &result.BestMove, err)
+	}
+}
+
+// Search info is delivered to the listener with consistent values, and the result PV starts with the best move
+func TestSearchInfo(t *testing.T) {
+	opts := NewOptions()
+	opts.ProgressIntervalMs = 1
+
+	var infos []SearchInfoT
+	listener := func(info SearchInfoT) { infos = append(infos, info) }
+
+	board := dragon.ParseFen("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 1")
+	var timeout uint32
+	result, err := Search(SearchRequestT{Options: opts, Board: &board, History: make(HistoryTableT), Depth: 7, Info: listener, Timeout: &timeout})
+	if err != nil {
+		t.Fatalf("Search failed: %v\n", err)
+	}
+	if result.SelDepth <= result.Depth {
+		t.Errorf("Expected seldepth > depth %d with q-search but got %d\n", result.Depth, result.SelDepth)
+	}
+	if len(result.PV) < 2 || result.PV[0] != result.BestMove || result.PonderMove != result.PV[1] {
+		t.Errorf("Inconsistent PV %v for best move %s and ponder move %s\n", result.PV, &result.BestMove, &result.PonderMove)
+	}
+
+	var nKinds [3]int
+	for i, info := range infos {
+		nKinds[info.Kind]++
+		if info.Kind == InfoString {
+			continue
+		}
+		if info.Depth < 1 || info.Depth > result.Depth || info.Nodes == 0 || info.HashFull < 0 || info.HashFull > 1000 ||
+			(info.Kind == InfoProgress && info.CurrMove != NoMove && info.CurrMoveNumber < 1) ||
+			(info.Kind == InfoDepth && len(info.PV) == 0) {
+			t.Errorf("Info %d is inconsistent: %+v\n", i, info)
+		}
+	}
+	if nKinds[InfoString] == 0 || nKinds[InfoDepth] != result.Depth || nKinds[InfoProgress] == 0 {
+		t.Errorf("Expected info strings, %d depths and progress reports but got %v\n", result.Depth, nKinds)
+	}
+}
+
+// A depth that times out isn't reported as a completed depth, even if it has a partial result
+func TestSearchInfoTimedOutDepth(t *testing.T) {
+	opts := NewOptions()
+	opts.ProgressIntervalMs = 1
+
+	var timeout uint32
+	timedOutDepth := 0
+	var depths []int
+	listener := func(info SearchInfoT) {
+		switch info.Kind {
+		case InfoProgress:
+			// Time out once there's a partial result for the depth
+			if timedOutDepth == 0 && info.Depth >= 4 && info.CurrMoveNumber >= 2 {
+				timedOutDepth = info.Depth
+				timeout = 1
+			}
+		case InfoDepth:
+			depths = append(depths, info.Depth)
+		}
+	}
+
+	board := dragon.ParseFen("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 1")
+	if _, err := Search(SearchRequestT{Options: opts, Board: &board, History: make(HistoryTableT), Depth: 20, Info: listener, Timeout: &timeout}); err != nil {
+		t.Fatalf("Search failed: %v\n", err)
+	}
+	if timedOutDepth == 0 || len(depths) != timedOutDepth-1 {
+		t.Errorf("Timed out at depth %d but got depth info for depths %v\n", timedOutDepth, depths)
+	}
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Fixed piece values for SEE - independent of the (tunable) eval so that move ordering is stable.
+// The king is valued high so that a king capture onto a defended square always loses.
+var seePieceVals = [7]EvalCp{0, 100, 300, 300, 500, 900, 5000}
+
+// The piece captured by a move, including en-passant captures
+func moveVictim(board *dragon.Board, move dragon.Move) dragon.Piece {
+	from, to := move.From(), move.To()
+	victim := board.PieceAt(to)
+	// A pawn changing file to an empty square is en-passant
+	if victim == dragon.Nothing && (from^to)&7 != 0 && board.PieceAt(from) == dragon.Pawn {
+		return dragon.Pawn
+	}
+	return victim
+}
+
+// All pieces of both colours attacking the given square with the given occupancy.
+// Sliders are recalculated from occupied, so removing a piece from occupied reveals x-ray attackers behind it.
+func attackersTo(board *dragon.Board, sq uint8, occupied uint64) uint64 {
+	sqBb := uint64(1) << sq
+	bishops := board.White.Bishops | board.Black.Bishops | board.White.Queens | board.Black.Queens
+	rooks := board.White.Rooks | board.Black.Rooks | board.White.Queens | board.Black.Queens
+
+	// A white pawn attacks sq if a black pawn on sq would attack the white pawn, and vice versa
+	attackers := BPawnAttacks(sqBb)&board.White.Pawns |
+		WPawnAttacks(sqBb)&board.Black.Pawns |
+		KnightAttacks(sqBb)&(board.White.Knights|board.Black.Knights) |
+		KingAttacks(sqBb)&(board.White.Kings|board.Black.Kings) |
+		dragon.CalculateBishopMoveBitboard(sq, occupied)&bishops |
+		dragon.CalculateRookMoveBitboard(sq, occupied)&rooks
+
+	return attackers & occupied
+}
+
+// The least valuable piece of one side in the attackers set, and its bitboard
+func leastValuableAttacker(bbs *dragon.Bitboards, attackers uint64) (dragon.Piece, uint64) {
+	pieceBbs := [7]uint64{0, bbs.Pawns, bbs.Knights, bbs.Bishops, bbs.Rooks, bbs.Queens, bbs.Kings}
+	for piece := dragon.Piece(dragon.Pawn); piece <= dragon.King; piece++ {
+		if bb := pieceBbs[piece] & attackers; bb != 0 {
+			return piece, bb & -bb
+		}
+	}
+	return dragon.Nothing, 0
+}
+
+// Static exchange evaluation of a move - the expected material gain for the player making the move, assuming both
+// sides recapture on the destination square with their least valuable piece for as long as it's profitable.
+// Pins and checks are ignored.
+func SEE(board *dragon.Board, move dragon.Move) EvalCp {
+	from, to := move.From(), move.To()
+	occupied := (board.White.All | board.Black.All) &^ (uint64(1) << from)
+
+	attacker := board.PieceAt(from)
+	victim := moveVictim(board, move)
+	if victim == dragon.Pawn && board.PieceAt(to) == dragon.Nothing {
+		// En-passant - the captured pawn is behind the destination square
+		if board.Wtomove {
+			occupied &^= uint64(1) << (to - 8)
+		} else {
+			occupied &^= uint64(1) << (to + 8)
+		}
+	}
+
+	// gain[d] is the material gain at depth d of the exchange from the perspective of the player capturing at depth d
+	var gain [32]EvalCp
+	gain[0] = seePieceVals[victim]
+	if promo := move.Promote(); promo != dragon.Nothing {
+		gain[0] += seePieceVals[promo] - seePieceVals[dragon.Pawn]
+		attacker = promo
+	}
+
+	isWhite := !board.Wtomove
+	d := 0
+	for d+1 < len(gain) {
+		sideBbs := &board.Black
+		if isWhite {
+			sideBbs = &board.White
+		}
+		piece, pieceBb := leastValuableAttacker(sideBbs, attackersTo(board, to, occupied))
+		if pieceBb == 0 {
+			break
+		}
+
+		d++
+		// Capture the last piece to move onto the square
+		gain[d] = seePieceVals[attacker] - gain[d-1]
+		// A pawn recapturing on the back rank promotes - assume to a queen
+		if piece == dragon.Pawn && (to < 8 || to >= 56) {
+			gain[d] += seePieceVals[dragon.Queen] - seePieceVals[dragon.Pawn]
+			piece = dragon.Queen
+		}
+
+		occupied &^= pieceBb
+		attacker = piece
+		isWhite = !isWhite
+	}
+
+	// Each side can choose to stop capturing - negamax back down the exchange
+	for ; d > 0; d-- {
+		if -gain[d-1] < gain[d] {
+			gain[d-1] = -gain[d]
+		}
+	}
+
+	return gain[0]
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+func TestSEE(t *testing.T) {
+	tests := []struct {
+		fen      string
+		move     string
+		expected EvalCp
+	}{
+		// Undefended pawn
+		{"1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", "e1e5", 100},
+		// Rook takes defended pawn
+		{"1k1r4/1pp4p/p2p4/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", "e1e5", -400},
+		// Defended knight - pawn takes, pawn retakes
+		{"4k3/8/3p4/4n3/3P4/8/8/4K3 w - - 0 1", "d4e5", 300 - 100},
+		// X-ray - the queen behind the rook joins the exchange: RxN, RxR, QxR
+		{"4k3/4r3/8/4n3/8/8/4R3/4QK2 w - - 0 1", "e2e5", 300},
+		// Without the queen the rook is lost for the knight
+		{"4k3/4r3/8/4n3/8/8/4R3/5K2 w - - 0 1", "e2e5", 300 - 500},
+		// En-passant
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 100},
+		// Quiet move to an attacked square loses the piece
+		{"4k3/8/8/4p3/8/8/8/2B1K3 w - - 0 1", "c1f4", 0 - 300},
+		// King takes undefended rook
+		{"4k3/8/8/8/8/8/3r4/4K3 w - - 0 1", "e1d2", 500},
+		// King takes defended rook - illegal so it must never look good
+		{"4k3/8/8/8/8/2b5/3r4/4K3 w - - 0 1", "e1d2", 500 - 5000},
+		// Pawn recaptures on the back rank and promotes
+		{"4k3/8/8/8/8/8/4p3/R2n3K w - - 0 1", "a1d1", 300 - 500 - (900 - 100)},
+		// ... and the promoted queen is recaptured
+		{"4k3/8/8/8/8/1B6/4p3/R2n3K w - - 0 1", "a1d1", 300 - 500 - (900 - 100) + 900},
+	}
+	for _, test := range tests {
+		board := dragon.ParseFen(test.fen)
+		move, err := dragon.ParseMove(test.move)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if see := SEE(&board, move); see != test.expected {
+			t.Errorf("SEE of %s in %s is %d - expected %d\n", test.move, test.fen, see, test.expected)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package engine
 
 type SearchStatsT struct {
 	Nodes             uint64 // #nodes visited
+	SelDepth          uint64 // deepest ply from root reached, including q-search
 	Mates             uint64 // #true terminal nodes
 	NonLeafs          uint64 // #non-leaf nodes
 	FirstChildCuts    uint64 // #non-leaf nodes that (beta-)cut on the first child searched
@@ -13,7 +14,24 @@ type SearchStatsT struct {
 	KillerCuts        uint64 // #nodes with killer move cut
 	DeepKillers       uint64 // #nodes with deep killer move available
 	DeepKillerCuts    uint64 // #nodes with deep killer move cut
+	CounterMoveCuts   uint64 // #nodes with countermove cut
 	PosRepetitions    uint64 // #nodes with repeated position
+	FiftyMoveDraws    uint64 // #nodes drawn by the fifty-move rule
+	MaterialDraws     uint64 // #nodes drawn by insufficient material
+	AspirationLows    uint64 // #root re-searches after the eval fell below the aspiration window
+	AspirationHighs   uint64 // #root re-searches after the eval rose above the aspiration window
+	LMRReductions     uint64 // #moves searched at reduced depth by late move reductions
+	LMRReSearches     uint64 // #reduced moves re-searched at full depth
+	PVSReSearches     uint64 // #zero-window searches re-searched with the full window
+	CheckExts         uint64 // #moves extended because they give check
+	OneReplyExts      uint64 // #moves extended because they are the only legal move
+	PawnPushExts      uint64 // #moves extended because they push a pawn to the seventh rank
+	SingularExts      uint64 // #moves extended because they are singular
+	SingularSearches  uint64 // #singular extension verification searches
+	ExtensionCapHits  uint64 // #moves not extended because the search path used up its extension budget
+	RevFutilityCuts   uint64 // #nodes cut by reverse futility pruning
+	RazoringCuts      uint64 // #nodes cut by razoring
+	FutilityPrunes    uint64 // #quiet moves skipped by futility pruning
 	TTHits            uint64 // #nodes with successful TT probe
 	TTDepthHits       uint64 // #nodes where TT hit was at the same depth
 	TTDeeperHits      uint64 // #nodes where TT hit was deeper (and the same parity)
@@ -21,6 +39,9 @@ type SearchStatsT struct {
 	TTAlphaCuts       uint64 // #nodes with alpha cutoff from TT hit
 	TTLateCuts        uint64 // #nodes with beta cutoff from TT hit
 	TTTrueEvals       uint64 // #nodes with QQT hits that are the same depth and are not a lower bound
+	TBHits            uint64 // #nodes with successful Syzygy tablebase probe
+	PawnHashProbes    uint64 // #static evals that probed the pawn hash
+	PawnHashHits      uint64 // #static evals with successful pawn hash probe
 	QNodes            uint64 // #nodes visited in qsearch
 	QMates            uint64 // #true terminal nodes in qsearch
 	QNonLeafs         uint64 // #non-leaf qnodes
@@ -31,6 +52,8 @@ type SearchStatsT struct {
 	QDeepKillers      uint64 // #qnodes with deep killer move available
 	QDeepKillerCuts   uint64 // #qnodes with deep killer move cut
 	QRampagePrunes    uint64 // #qnodes where we did queen rampage pruning
+	QSEEPrunes        uint64 // #q-search moves skipped because they lose material according to SEE
+	QChecks           uint64 // #quiet checking moves included in q-search
 	QPats             uint64 // #qnodes with stand pat best
 	QPatCuts          uint64 // #qnodes with stand pat cut
 	QQuiesced         uint64 // #qnodes where we successfully quiesced
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"fmt"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// A single tunable eval weight
+type TuningParamT struct {
+	Name     string
+	Min, Max int
+	get      func() int
+	set      func(int)
+}
+
+func (p *TuningParamT) Get() int {
+	return p.get()
+}
+
+// Set the value, clamped to [Min, Max]
+func (p *TuningParamT) Set(val int) {
+	if val < p.Min {
+		val = p.Min
+	} else if val > p.Max {
+		val = p.Max
+	}
+	p.set(val)
+}
+
+// All of the tunable eval weights in p.
+// Setting a param also makes p the eval weights of e, so params are not safe to Set concurrently with evaluation.
+// That clears e's pawn hash, so tune with an engine that has the pawn hash disabled.
+func TuningParams(e *EngineT, p *EvalParamsT) []TuningParamT {
+	var params []TuningParamT
+
+	addInt := func(name string, val *int, min int, max int) {
+		params = append(params, TuningParamT{
+			Name: name,
+			Min:  min,
+			Max:  max,
+			get:  func() int { return *val },
+			set:  func(v int) { *val = v; e.SetEvalParams(p) },
+		})
+	}
+	addEvalCp := func(name string, val *EvalCp, min int, max int) {
+		params = append(params, TuningParamT{
+			Name: name,
+			Min:  min,
+			Max:  max,
+			get:  func() int { return int(*val) },
+			set:  func(v int) { *val = EvalCp(v); e.SetEvalParams(p) },
+		})
+	}
+	addInt8 := func(name string, val *int8) {
+		params = append(params, TuningParamT{
+			Name: name,
+			Min:  -128,
+			Max:  127,
+			get:  func() int { return int(*val) },
+			set:  func(v int) { *val = int8(v); e.SetEvalParams(p) },
+		})
+	}
+
+	pieceNames := [7]string{"", "Pawn", "Knight", "Bishop", "Rook", "Queen", "King"}
+	maxPieceVals := [7]int{0, 1000, 2000, 2000, 3000, 5000, 0}
+	for piece := dragon.Pawn; piece <= dragon.Queen; piece++ {
+		addEvalCp(pieceNames[piece]+"Val", &p.PieceVals[piece], 1, maxPieceVals[piece])
+		addEvalCp(pieceNames[piece]+"EndgameVal", &p.PieceEndgameVals[piece], 1, maxPieceVals[piece])
+	}
+
+	posValsTables := []struct {
+		name         string
+		posVals      *[64]int8
+		minSq, maxSq int
+	}{
+		{"PawnPosVals", &p.PawnPosVals, 8, 55}, // pawns never stand on the 1st or 8th rank
+		{"KnightPosVals", &p.KnightPosVals, 0, 63},
+		{"BishopPosVals", &p.BishopPosVals, 0, 63},
+		{"RookPosVals", &p.RookPosVals, 0, 63},
+		{"QueenPosVals", &p.QueenPosVals, 0, 63},
+		{"KingPosVals", &p.KingPosVals, 0, 63},
+		{"PawnEndgamePosVals", &p.PawnEndgamePosVals, 8, 55},
+		{"KnightEndgamePosVals", &p.KnightEndgamePosVals, 0, 63},
+		{"BishopEndgamePosVals", &p.BishopEndgamePosVals, 0, 63},
+		{"RookEndgamePosVals", &p.RookEndgamePosVals, 0, 63},
+		{"QueenEndgamePosVals", &p.QueenEndgamePosVals, 0, 63},
+		{"KingEndgamePosVals", &p.KingEndgamePosVals, 0, 63},
+	}
+	for _, table := range posValsTables {
+		for sq := table.minSq; sq <= table.maxSq; sq++ {
+			addInt8(fmt.Sprintf("%s[%s]", table.name, dragon.IndexToAlgebraic(dragon.Square(sq))), &table.posVals[sq])
+		}
+	}
+
+	// A 7th rank pawn is always passed, so covered by the pawn-pos-val
+	for rank := 1; rank <= 5; rank++ {
+		addInt8(fmt.Sprintf("PassedPawnVals[%d]", rank), &p.PassedPawnVals[rank])
+		addInt8(fmt.Sprintf("PassedPawnEndgameVals[%d]", rank), &p.PassedPawnEndgameVals[rank])
+	}
+
+	addInt("PawnProtectsPawnVal", &p.PawnProtectsPawnVal, -100, 100)
+	addInt("PawnProtectsPawnEndgameVal", &p.PawnProtectsPawnEndgameVal, -100, 100)
+	addInt("PawnProtectsPieceVal", &p.PawnProtectsPieceVal, -100, 100)
+	addInt("PawnProtectsPieceEndgameVal", &p.PawnProtectsPieceEndgameVal, -100, 100)
+	addInt("DoubledPawnPenalty", &p.DoubledPawnPenalty, -100, 100)
+	addInt("DoubledPawnEndgamePenalty", &p.DoubledPawnEndgamePenalty, -100, 100)
+
+	// The king safety table itself is not tuned - it's a shape rather than a weight
+	for piece := dragon.Knight; piece <= dragon.Queen; piece++ {
+		addInt(pieceNames[piece]+"KingAttackWeight", &p.KingAttackWeights[piece], 0, 20)
+	}
+	addInt("PawnShieldVals[0]", &p.PawnShieldVals[0], -100, 100)
+	addInt("PawnShieldVals[1]", &p.PawnShieldVals[1], -100, 100)
+	addInt("PawnStormPenalty", &p.PawnStormPenalty, -100, 100)
+	addInt("KingOpenFilePenalty", &p.KingOpenFilePenalty, -100, 100)
+	addInt("KingSemiOpenFilePenalty", &p.KingSemiOpenFilePenalty, -100, 100)
+
+	for piece := dragon.Knight; piece <= dragon.Queen; piece++ {
+		addInt(pieceNames[piece]+"MobilityVal", &p.MobilityVals[piece], -50, 50)
+		addInt(pieceNames[piece]+"MobilityEndgameVal", &p.MobilityEndgameVals[piece], -50, 50)
+	}
+
+	addInt("RookOpenFileVal", &p.RookOpenFileVal, -100, 100)
+	addInt("RookOpenFileEndgameVal", &p.RookOpenFileEndgameVal, -100, 100)
+	addInt("RookSemiOpenFileVal", &p.RookSemiOpenFileVal, -100, 100)
+	addInt("RookSemiOpenFileEndgameVal", &p.RookSemiOpenFileEndgameVal, -100, 100)
+	addInt("RookSeventhRankVal", &p.RookSeventhRankVal, -100, 100)
+	addInt("RookSeventhRankEndgameVal", &p.RookSeventhRankEndgameVal, -100, 100)
+	addInt("BishopPairVal", &p.BishopPairVal, -100, 200)
+	addInt("BishopPairEndgameVal", &p.BishopPairEndgameVal, -100, 200)
+	addInt("KnightOutpostVal", &p.KnightOutpostVal, -100, 100)
+	addInt("KnightOutpostEndgameVal", &p.KnightOutpostEndgameVal, -100, 100)
+	addInt("BishopOutpostVal", &p.BishopOutpostVal, -100, 100)
+	addInt("BishopOutpostEndgameVal", &p.BishopOutpostEndgameVal, -100, 100)
+
+	return params
+}
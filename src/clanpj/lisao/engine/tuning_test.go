@@ -0,0 +1,26 @@
+package engine
+
+import (
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Changing the (white) weights must change the black weights symmetrically, so the start position stays balanced
+func TestTuningParamsAreSymmetric(t *testing.T) {
+	board := dragon.ParseFen(dragon.Startpos)
+	e := NewEngine(nil)
+	before := e.StaticEval(&board)
+
+	p := e.EvalParams()
+	for _, param := range TuningParams(e, &p) {
+		param.Set(param.Get() + 5)
+	}
+
+	if after := e.StaticEval(&board); after != before {
+		t.Errorf("Start position eval changed from %d to %d after tuning\n", before, after)
+	}
+	if e.EvalParams() != p {
+		t.Errorf("Tuned params are not in use\n")
+	}
+}
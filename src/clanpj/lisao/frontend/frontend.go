@@ -0,0 +1,107 @@
+package frontend
+
+import (
+	"fmt"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+
+	"clanpj/lisao/book"
+	"clanpj/lisao/engine"
+	"clanpj/lisao/syzygy"
+)
+
+// Front-end options that aren't search options - the opening book, the Syzygy tablebases and the eval weights.
+// Each front-end registers them with the same names so that a config file works for any of them.
+type FrontEndT struct {
+	// Opening book - only used if OwnBook is set and a BookFile has been loaded
+	OwnBook bool
+	Book    *book.BookT
+	// Max game ply at which we still use the book (0 for no limit)
+	BookDepth     int
+	BookSelection book.SelectionT
+
+	// Eval weights from EvalFile - the defaults if there's no EvalFile
+	EvalParams engine.EvalParamsT
+	// Called when the eval weights change, e.g. to apply them to a running engine - optional
+	OnEvalParams func(p *engine.EvalParamsT)
+
+	// Informational messages, e.g. the number of tablebases found
+	Info func(a ...interface{})
+}
+
+func NewFrontEnd() *FrontEndT {
+	return &FrontEndT{
+		BookDepth:     book.DefaultMaxDepth,
+		BookSelection: book.SelectWeighted,
+		EvalParams:    engine.DefaultEvalParams,
+		Info:          func(a ...interface{}) { fmt.Println(a...) },
+	}
+}
+
+// Add our options to the registry
+func (f *FrontEndT) AddOptions(r *engine.OptionRegistryT) {
+	r.Add(engine.CheckOption("OwnBook", f.OwnBook, func(value bool) { f.OwnBook = value }))
+	r.Add(engine.StringOption("BookFile", "", func(path string) error {
+		if path == "" {
+			f.Book = nil
+			return nil
+		}
+		b, err := book.Open(path)
+		if err != nil {
+			return err
+		}
+		f.Book = b
+		f.Info("Loaded BookFile", path, "with", b.Len(), "entries")
+		return nil
+	}))
+	r.Add(engine.SpinOption("BookDepth", f.BookDepth, 0, 1024, func(value int) { f.BookDepth = value }))
+	r.Add(engine.ComboOption("BookSelection", book.SelectionString(f.BookSelection), []string{"Weighted", "Best"}, func(value string) {
+		f.BookSelection = book.SelectWeighted
+		if value == "Best" {
+			f.BookSelection = book.SelectBest
+		}
+	}))
+	r.Add(engine.StringOption("SyzygyPath", "", func(path string) error {
+		n, err := syzygy.Init(path)
+		if err != nil {
+			return err
+		}
+		f.Info("Found", n, "tablebases with up to", syzygy.MaxPieces(), "pieces")
+		return nil
+	}))
+	r.Add(engine.StringOption("EvalFile", "", func(path string) error {
+		evalParams := engine.DefaultEvalParams
+		if path != "" {
+			var err error
+			evalParams, err = engine.LoadEvalParams(path)
+			if err != nil {
+				return err
+			}
+		}
+		f.EvalParams = evalParams
+		if f.OnEvalParams != nil {
+			f.OnEvalParams(&f.EvalParams)
+		}
+		if path == "" {
+			f.Info("Using the default eval params")
+		} else {
+			f.Info("Using eval params from", path)
+		}
+		return nil
+	}))
+}
+
+// A book move for the position, if OwnBook is set and the book has one
+func (f *FrontEndT) BookMove(board *dragon.Board) (dragon.Move, bool) {
+	if !f.OwnBook || f.Book == nil {
+		return engine.NoMove, false
+	}
+	return f.Book.Probe(board, f.BookSelection, f.BookDepth)
+}
+
+// A new engine instance with our eval weights
+func (f *FrontEndT) NewEngine(opts *engine.OptionsT) *engine.EngineT {
+	e := engine.NewEngine(opts)
+	e.SetEvalParams(&f.EvalParams)
+	return e
+}
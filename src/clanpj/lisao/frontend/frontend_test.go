@@ -0,0 +1,89 @@
+package frontend
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+
+	"clanpj/lisao/engine"
+	"clanpj/lisao/syzygy"
+)
+
+func newTestRegistry() (*FrontEndT, *engine.OptionRegistryT) {
+	f := NewFrontEnd()
+	f.Info = func(a ...interface{}) {}
+	r := engine.NewOptionRegistry(engine.NewOptions())
+	f.AddOptions(r)
+	return f, r
+}
+
+func TestFrontEndOptions(t *testing.T) {
+	f, r := newTestRegistry()
+
+	for _, name := range []string{"OwnBook", "BookFile", "BookDepth", "BookSelection", "SyzygyPath", "EvalFile"} {
+		if r.Lookup(name) == nil {
+			t.Errorf("Expected a %s option\n", name)
+		}
+	}
+
+	// Missing files are errors rather than silently ignored
+	if err := r.Set("SyzygyPath", "/no/such/syzygy/dir"); err == nil {
+		t.Errorf("Expected an error for a bad SyzygyPath\n")
+	}
+	if err := r.Set("BookFile", "/no/such/book.bin"); err == nil || f.Book != nil {
+		t.Errorf("Expected an error for a bad BookFile\n")
+	}
+	if err := r.Set("EvalFile", "/no/such/params.json"); err == nil {
+		t.Errorf("Expected an error for a bad EvalFile\n")
+	}
+	if err := r.Set("SyzygyPath", "<empty>"); err != nil || syzygy.MaxPieces() != 0 {
+		t.Errorf("Failed to clear SyzygyPath (%v)\n", err)
+	}
+
+	// No book, no book move
+	board := dragon.ParseFen(dragon.Startpos)
+	if err := r.Set("OwnBook", "true"); err != nil || !f.OwnBook {
+		t.Errorf("Failed to set OwnBook (%v)\n", err)
+	}
+	if _, ok := f.BookMove(&board); ok {
+		t.Errorf("Expected no book move without a BookFile\n")
+	}
+}
+
+func TestFrontEndEvalFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frontend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := engine.DefaultEvalParams
+	p.PieceVals[dragon.Knight] += 50
+	path := filepath.Join(dir, "params.json")
+	if err := engine.SaveEvalParams(path, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	f, r := newTestRegistry()
+	var applied *engine.EvalParamsT
+	f.OnEvalParams = func(p *engine.EvalParamsT) { applied = p }
+	if err := r.Set("EvalFile", path); err != nil {
+		t.Fatalf("Failed to set EvalFile: %v", err)
+	}
+	if f.EvalParams != p || applied == nil || *applied != p {
+		t.Errorf("EvalFile params were not loaded and applied\n")
+	}
+
+	// New engines get the loaded weights
+	if e := f.NewEngine(nil); e.EvalParams() != p {
+		t.Errorf("New engine doesn't have the EvalFile params\n")
+	}
+
+	// Back to the defaults
+	if err := r.Set("EvalFile", "<empty>"); err != nil || f.EvalParams != engine.DefaultEvalParams {
+		t.Errorf("Failed to reset EvalFile (%v)\n", err)
+	}
+}
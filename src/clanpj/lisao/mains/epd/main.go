@@ -0,0 +1,396 @@
+// EPD test-suite runner - WAC, ECM, STS and friends.
+// Searches each position with a per-position depth or time limit and reports solved/failed,
+// time to solution and a final score.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+
+	"clanpj/lisao/engine"
+)
+
+var depthFlag = flag.Int("depth", 0, "Maximum search depth per position (0 for no depth limit).")
+var moveTimeFlag = flag.Int("movetime", 0, "Search time per position in ms (default 1000 if no depth limit is given).")
+var jsonFlag = flag.String("json", "", "Write the results as JSON to this file, e.g. for comparing engine versions.")
+var quietFlag = flag.Bool("quiet", false, "Only print failed positions and the summary.")
+
+// A single EPD test position
+type EpdT struct {
+	Id         string
+	Fen        string
+	BestMoves  []string       // bm - SAN
+	AvoidMoves []string       // am - SAN
+	Points     map[string]int // STS-style c0 move points, e.g. "Nf3=10, Nd2=5", by SAN
+}
+
+type ResultT struct {
+	Id               string
+	Fen              string
+	BestMoves        []string `json:",omitempty"`
+	AvoidMoves       []string `json:",omitempty"`
+	Move             string
+	Solved           bool
+	TimeToSolutionMs int64 // -1 if not solved
+	Depth            int
+	TimeMs           int64
+	Nodes            uint64
+	Points           int `json:",omitempty"`
+	MaxPoints        int `json:",omitempty"`
+}
+
+type SummaryT struct {
+	Files      []string
+	Depth      int
+	MoveTimeMs int
+	Total      int
+	Solved     int
+	Points     int `json:",omitempty"`
+	MaxPoints  int `json:",omitempty"`
+	TimeMs     int64
+	Results    []ResultT
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: epd [flags] file.epd ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return
+	}
+
+	moveTimeMs := *moveTimeFlag
+	if moveTimeMs == 0 && *depthFlag == 0 {
+		moveTimeMs = 1000
+	}
+	maxDepth := *depthFlag
+	if maxDepth == 0 {
+		maxDepth = engine.MaxDepth
+	}
+
+	summary := SummaryT{Files: flag.Args(), Depth: *depthFlag, MoveTimeMs: moveTimeMs}
+
+	for _, fileName := range flag.Args() {
+		epds, err := readEpdFile(fileName)
+		if err != nil {
+			log.Fatalf("epd: Error reading %s: %v", fileName, err)
+		}
+
+		for _, epd := range epds {
+			result, err := runEpd(&epd, maxDepth, moveTimeMs)
+			if err != nil {
+				log.Printf("epd: Error running position %s: %v", epd.Id, err)
+				continue
+			}
+
+			summary.Total++
+			if result.Solved {
+				summary.Solved++
+			}
+			summary.Points += result.Points
+			summary.MaxPoints += result.MaxPoints
+			summary.TimeMs += result.TimeMs
+			summary.Results = append(summary.Results, result)
+
+			if !*quietFlag || !result.Solved {
+				printResult(&result)
+			}
+		}
+	}
+
+	fmt.Printf("solved %d/%d [%.2f%%]", summary.Solved, summary.Total, perC(summary.Solved, summary.Total))
+	if summary.MaxPoints > 0 {
+		fmt.Printf(" points %d/%d [%.2f%%]", summary.Points, summary.MaxPoints, perC(summary.Points, summary.MaxPoints))
+	}
+	fmt.Printf(" time %dms\n", summary.TimeMs)
+
+	if *jsonFlag != "" {
+		bytes, err := json.MarshalIndent(&summary, "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(*jsonFlag, bytes, 0644)
+		}
+		if err != nil {
+			log.Fatalf("epd: Error writing JSON results to %s: %v", *jsonFlag, err)
+		}
+	}
+}
+
+func perC(n int, N int) float64 {
+	if N == 0 {
+		return 0
+	}
+	return float64(n) / float64(N) * 100
+}
+
+func printResult(result *ResultT) {
+	status := "FAILED"
+	if result.Solved {
+		status = fmt.Sprintf("solved in %dms", result.TimeToSolutionMs)
+	}
+	expected := ""
+	if len(result.BestMoves) > 0 {
+		expected += " bm " + strings.Join(result.BestMoves, " ")
+	}
+	if len(result.AvoidMoves) > 0 {
+		expected += " am " + strings.Join(result.AvoidMoves, " ")
+	}
+	points := ""
+	if result.MaxPoints > 0 {
+		points = fmt.Sprintf(" points %d/%d", result.Points, result.MaxPoints)
+	}
+	fmt.Printf("%s: %s - played %s%s depth %d nodes %d%s\n", result.Id, status, result.Move, expected, result.Depth, result.Nodes, points)
+}
+
+func readEpdFile(fileName string) ([]EpdT, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var epds []EpdT
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		epd, err := parseEpd(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		if epd.Id == "" {
+			epd.Id = fmt.Sprintf("%s:%d", fileName, lineNo)
+		}
+		epds = append(epds, epd)
+	}
+
+	return epds, scanner.Err()
+}
+
+// Parse an EPD line - four FEN fields followed by semi-colon terminated opcodes, e.g.
+// r1b1k2r/ppppnppp/2n2q2/2b5/3NP3/2P1B3/PP3PPP/RN1QKB1R w KQkq - bm Nxc6; id "WAC.002";
+func parseEpd(line string) (EpdT, error) {
+	var epd EpdT
+
+	fields := strings.SplitN(line, " ", 5)
+	if len(fields) < 4 {
+		return epd, fmt.Errorf("malformed EPD '%s'", line)
+	}
+	// EPD has no clocks (unless the hmvc and fmvn opcodes are present, which we ignore)
+	epd.Fen = strings.Join(fields[:4], " ") + " 0 1"
+
+	if len(fields) < 5 {
+		return epd, nil
+	}
+
+	for _, op := range splitOpcodes(fields[4]) {
+		opFields := strings.SplitN(op, " ", 2)
+		opcode := opFields[0]
+		operands := ""
+		if len(opFields) > 1 {
+			operands = strings.TrimSpace(opFields[1])
+		}
+
+		switch opcode {
+		case "bm":
+			epd.BestMoves = strings.Fields(operands)
+		case "am":
+			epd.AvoidMoves = strings.Fields(operands)
+		case "id":
+			epd.Id = strings.Trim(operands, "\"")
+		case "c0":
+			// STS style move points - only if it looks like "Nf3=10, Nd2=5"
+			points := parseMovePoints(strings.Trim(operands, "\""))
+			if len(points) > 0 {
+				epd.Points = points
+			}
+		}
+	}
+
+	return epd, nil
+}
+
+// Split EPD opcodes on semi-colons, respecting quoted strings
+func splitOpcodes(s string) []string {
+	var ops []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ';':
+			if !inQuotes {
+				if op := strings.TrimSpace(s[start:i]); op != "" {
+					ops = append(ops, op)
+				}
+				start = i + 1
+			}
+		}
+	}
+	if op := strings.TrimSpace(s[start:]); op != "" {
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// Parse STS style move points, e.g. "Nf3=10, Nd2=5"
+func parseMovePoints(s string) map[string]int {
+	points := make(map[string]int)
+	for _, item := range strings.Split(s, ",") {
+		// Split on the last '=' since promotions also use '=', e.g. "e8=Q=10"
+		item = strings.TrimSpace(item)
+		i := strings.LastIndex(item, "=")
+		if i <= 0 {
+			return nil
+		}
+		n, err := strconv.Atoi(item[i+1:])
+		if err != nil {
+			return nil
+		}
+		points[item[:i]] = n
+	}
+	return points
+}
+
+// Map the SAN moves to legal moves
+func parseSanMoves(board *dragon.Board, sans []string) ([]dragon.Move, error) {
+	var moves []dragon.Move
+	for _, san := range sans {
+		move, err := engine.ParseSan(board, san)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, move)
+	}
+	return moves, nil
+}
+
+func containsMove(moves []dragon.Move, move dragon.Move) bool {
+	for _, m := range moves {
+		if m == move {
+			return true
+		}
+	}
+	return false
+}
+
+// Is the move a solution for the position?
+func isSolution(move dragon.Move, bestMoves []dragon.Move, avoidMoves []dragon.Move) bool {
+	if len(bestMoves) > 0 && !containsMove(bestMoves, move) {
+		return false
+	}
+	return !containsMove(avoidMoves, move)
+}
+
+// We use a shared variable using golang sync mechanisms for atomic shared operation.
+// When timeOut != 0 then we bail on the search.
+var timeout uint32
+
+// The engine hash tables are reset for each position
+var lisao = engine.NewEngine(nil)
+
+// Search the position, tracking the best move at each depth so that we can find the time to solution, which is the time
+// at which the engine settled on a solution move (and didn't change its mind).
+// Each position starts with a fresh TT, QTT and move history so that results don't depend on the order of the suite.
+func runEpd(epd *EpdT, maxDepth int, moveTimeMs int) (ResultT, error) {
+	result := ResultT{Id: epd.Id, Fen: epd.Fen, BestMoves: epd.BestMoves, AvoidMoves: epd.AvoidMoves, TimeToSolutionMs: -1}
+
+	board := dragon.ParseFen(epd.Fen)
+	bestMoves, err := parseSanMoves(&board, epd.BestMoves)
+	if err != nil {
+		return result, err
+	}
+	avoidMoves, err := parseSanMoves(&board, epd.AvoidMoves)
+	if err != nil {
+		return result, err
+	}
+	pointsByMove := make(map[dragon.Move]int)
+	for san, points := range epd.Points {
+		move, err := engine.ParseSan(&board, san)
+		if err != nil {
+			return result, err
+		}
+		pointsByMove[move] = points
+		if points > result.MaxPoints {
+			result.MaxPoints = points
+		}
+	}
+	// Positions with only STS move points are solved if we find the top scoring move
+	if len(bestMoves) == 0 && len(avoidMoves) == 0 {
+		for move, points := range pointsByMove {
+			if points == result.MaxPoints {
+				bestMoves = append(bestMoves, move)
+			}
+		}
+	}
+	if len(bestMoves) == 0 && len(avoidMoves) == 0 {
+		return result, fmt.Errorf("no bm, am or c0 move points for position %s", epd.Fen)
+	}
+
+	ht := make(engine.HistoryTableT)
+	ht.Add(board.Hash())
+	lisao.ResetTT()
+	lisao.ResetQtt()
+	lisao.ResetMoveHistory()
+
+	atomic.StoreUint32(&timeout, 0)
+	if moveTimeMs > 0 {
+		timer := time.AfterFunc(time.Duration(moveTimeMs)*time.Millisecond, func() { atomic.StoreUint32(&timeout, 1) })
+		defer timer.Stop()
+	}
+
+	start := time.Now()
+
+	// Track the time to solution from the best move at each depth - it's only solved if it stays solved
+	info := func(info engine.SearchInfoT) {
+		if info.Kind != engine.InfoDepth || len(info.PV) == 0 {
+			return
+		}
+		if isSolution(info.PV[0], bestMoves, avoidMoves) {
+			if result.TimeToSolutionMs < 0 {
+				result.TimeToSolutionMs = int64(info.ElapsedMs)
+			}
+		} else {
+			result.TimeToSolutionMs = -1
+		}
+	}
+	searchResult, err := engine.Search(engine.SearchRequestT{Engine: lisao, Board: &board, History: ht, Depth: maxDepth, Info: info, Timeout: &timeout})
+	bestMove := searchResult.BestMove
+	result.Nodes = searchResult.Stats.Nodes
+	result.Depth = searchResult.Depth
+	// The last depth might have been discarded on time-out
+	if err != nil || !isSolution(bestMove, bestMoves, avoidMoves) {
+		result.TimeToSolutionMs = -1
+	}
+
+	result.TimeMs = time.Since(start).Nanoseconds() / 1e6
+
+	if bestMove == engine.NoMove {
+		return result, fmt.Errorf("no move found for position %s", epd.Fen)
+	}
+
+	result.Move = engine.MoveToSan(&board, bestMove)
+	result.Solved = result.TimeToSolutionMs >= 0
+	result.Points = pointsByMove[bestMove]
+
+	return result, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEpd(t *testing.T) {
+	tests := []struct {
+		line string
+		epd  EpdT
+	}{
+		{`r1b1k2r/ppppnppp/2n2q2/2b5/3NP3/2P1B3/PP3PPP/RN1QKB1R w KQkq - bm Nxc6; id "WAC.002";`,
+			EpdT{Id: "WAC.002", Fen: "r1b1k2r/ppppnppp/2n2q2/2b5/3NP3/2P1B3/PP3PPP/RN1QKB1R w KQkq - 0 1", BestMoves: []string{"Nxc6"}}},
+		// Several best moves, and semi-colons in quoted operands
+		{`8/8/8/8/8/8/8/K1k5 w - - bm Kb1 Ka2; id "semi;colon"; c0 "a; b";`,
+			EpdT{Id: "semi;colon", Fen: "8/8/8/8/8/8/8/K1k5 w - - 0 1", BestMoves: []string{"Kb1", "Ka2"}}},
+		// Avoid moves, and no id
+		{`8/8/8/8/8/8/8/K1k5 w - - am Kb2;`,
+			EpdT{Fen: "8/8/8/8/8/8/8/K1k5 w - - 0 1", AvoidMoves: []string{"Kb2"}}},
+		// STS style move points, including a promotion
+		{`8/4P3/8/8/8/8/8/K1k5 w - - bm e8=Q; id "STS(v1.0) 1"; c0 "e8=Q=10, Kb2=3";`,
+			EpdT{Id: "STS(v1.0) 1", Fen: "8/4P3/8/8/8/8/8/K1k5 w - - 0 1", BestMoves: []string{"e8=Q"}, Points: map[string]int{"e8=Q": 10, "Kb2": 3}}},
+		// A c0 comment that isn't move points
+		{`8/8/8/8/8/8/8/K1k5 w - - bm Kb1; c0 "Mate in 1 = fun";`,
+			EpdT{Fen: "8/8/8/8/8/8/8/K1k5 w - - 0 1", BestMoves: []string{"Kb1"}}},
+		// No opcodes
+		{`8/8/8/8/8/8/8/K1k5 b - -`,
+			EpdT{Fen: "8/8/8/8/8/8/8/K1k5 b - - 0 1"}},
+	}
+
+	for _, test := range tests {
+		epd, err := parseEpd(test.line)
+		if err != nil || !reflect.DeepEqual(epd, test.epd) {
+			t.Errorf("parseEpd %s is %+v (%v) expected %+v\n", test.line, epd, err, test.epd)
+		}
+	}
+
+	if _, err := parseEpd("8/8/8/8/8/8/8/K1k5 w"); err == nil {
+		t.Errorf("Expected an error for a truncated EPD\n")
+	}
+}
+
+func TestSplitOpcodes(t *testing.T) {
+	ops := splitOpcodes(`bm Nf3;  id "a;b" ; c0 "x";;`)
+	expected := []string{"bm Nf3", `id "a;b"`, `c0 "x"`}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Errorf("splitOpcodes is %q expected %q\n", ops, expected)
+	}
+}
+
+func TestParseMovePoints(t *testing.T) {
+	tests := []struct {
+		s      string
+		points map[string]int
+	}{
+		{"Nf3=10", map[string]int{"Nf3": 10}},
+		{"Nf3=10, Nd2=5,Qxe7+=1", map[string]int{"Nf3": 10, "Nd2": 5, "Qxe7+": 1}},
+		{"exd8=Q=10, e8=N=2", map[string]int{"exd8=Q": 10, "e8=N": 2}},
+		{"just a comment", nil},
+		{"Nf3=ten", nil},
+		{"=10", nil},
+	}
+
+	for _, test := range tests {
+		if points := parseMovePoints(test.s); !reflect.DeepEqual(points, test.points) {
+			t.Errorf("parseMovePoints %s is %v expected %v\n", test.s, points, test.points)
+		}
+	}
+}
@@ -17,13 +17,19 @@ import (
 // TODO(guy) should be in state
 var botName = "Lisao"
 
+// Automatic contempt is the rating difference divided by this, capped at maxAutoContempt either way
+const autoContemptRatingDivisor = 10
+const maxAutoContempt = 50
+
 type Game struct {
 	ID         string
 	InitialFen string
 	WeAreWhite bool
+	Contempt   int // engine contempt for this game
 
 	Moves        []string // List of moves in UCI format.
 	HistoryTable engine.HistoryTableT
+	Engine       *engine.EngineT // hash tables and eval weights - each game has its own since games are played concurrently
 
 	isPlaying bool
 	mutex     sync.Mutex
@@ -92,6 +98,7 @@ func playGame(state *State, game *Game) {
 	defer unlockGame(game)
 
 	game.HistoryTable = make(engine.HistoryTableT)
+	game.Engine = state.frontEnd.NewEngine(state.options)
 
 	gameStateCh, err := state.client.StreamGameState(game.ID)
 	if err != nil {
@@ -130,7 +137,7 @@ func handleMessage(state *State, game *Game, msg lichess.GameStateMessage) error
 	var anyErr error
 	switch msg.Type {
 	case lichess.GameFullGameStateType:
-		anyErr = handleInitialGameState(game, msg.Data.(lichess.GameFullGameState))
+		anyErr = handleInitialGameState(state, game, msg.Data.(lichess.GameFullGameState))
 
 	case lichess.GameStateGameStateType:
 		anyErr = handleGameUpdate(game, msg.Data.(lichess.GameStateGameState))
@@ -165,7 +172,7 @@ func handleMessage(state *State, game *Game, msg lichess.GameStateMessage) error
 	return nil
 }
 
-func handleInitialGameState(game *Game, initialState lichess.GameFullGameState) error {
+func handleInitialGameState(state *State, game *Game, initialState lichess.GameFullGameState) error {
 	game.InitialFen = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
 
 	game.Moves = []string{}
@@ -185,9 +192,34 @@ func handleInitialGameState(game *Game, initialState lichess.GameFullGameState)
 		return errors.New(errMsg)
 	}
 
+	game.Contempt = state.options.Contempt
+	if state.autoContempt {
+		us, them := initialState.White, initialState.Black
+		if !game.WeAreWhite {
+			us, them = them, us
+		}
+		// Unrated opponents (e.g. the lichess AI) keep the default contempt
+		if us.Rating != 0 && them.Rating != 0 {
+			game.Contempt = ratingContempt(us.Rating, them.Rating)
+		}
+		log.Printf("bot: Using contempt %d in game %s (%d vs %d)", game.Contempt, game.ID, us.Rating, them.Rating)
+	}
+
 	return nil
 }
 
+// Contempt from the rating difference - we avoid draws against weaker opponents and welcome them against stronger ones
+func ratingContempt(ourRating int64, theirRating int64) int {
+	contempt := int((ourRating - theirRating) / autoContemptRatingDivisor)
+	if contempt > maxAutoContempt {
+		return maxAutoContempt
+	}
+	if contempt < -maxAutoContempt {
+		return -maxAutoContempt
+	}
+	return contempt
+}
+
 func handleGameUpdate(game *Game, update lichess.GameStateGameState) error {
 	game.Moves = []string{}
 	if update.Moves != "" {
@@ -209,40 +241,69 @@ func isOurTurn(game *Game) bool {
 	return whiteToPlay == game.WeAreWhite
 }
 
-func getBoard(game *Game) (*dragon.Board, error) {
+// Replay the game moves, recording each position in a new history table for repetition detection
+func getBoard(game *Game) (*dragon.Board, engine.HistoryTableT, error) {
 	board := dragon.ParseFen(game.InitialFen)
+	ht := make(engine.HistoryTableT)
+	ht.Add(board.Hash())
 	for _, moveStr := range game.Moves {
 		move, err := dragon.ParseMove(moveStr)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		board.Apply(move)
+		ht.Add(board.Hash())
 	}
 
-	return &board, nil
+	return &board, ht, nil
 }
 
+// Checkmate, stalemate, insufficient material or threefold repetition.
+// The fifty-move rule has to be claimed so we keep playing.
 func gameIsOver(game *Game) (bool, error) {
-	board, err := getBoard(game)
+	board, ht, err := getBoard(game)
 	if err != nil {
 		return false, err
 	}
 
-	return len(board.GenerateLegalMoves()) == 0, nil
+	return len(board.GenerateLegalMoves()) == 0 || engine.IsInsufficientMaterial(board) || ht[board.Hash()] >= 3, nil
 }
 
 func makeMove(state *State, game *Game) error {
-	board, err := getBoard(game)
+	board, ht, err := getBoard(game)
 	if err != nil {
 		return err
 	}
+	game.HistoryTable = ht
+
+	if move, ok := state.frontEnd.BookMove(board); ok {
+		log.Printf("bot: Playing book move %s in game %s", &move, game.ID)
+		return state.client.PostMove(game.ID, move.String())
+	}
 
+	options := *state.options
+	options.Contempt = game.Contempt
+	// Only log progress - the per-depth results would swamp the log
+	info := func(info engine.SearchInfoT) {
+		if info.Kind == engine.InfoProgress {
+			log.Printf("bot: Searching game %s at depth %d, move %s, %d nodes, %d nps", game.ID, info.Depth, &info.CurrMove, info.Nodes, info.Nps)
+		}
+	}
 	var timeout uint32
-	move, _, _, _, err := engine.Search(board, game.HistoryTable, 0, 500, &timeout)
+	result, err := engine.Search(engine.SearchRequestT{
+		Options:      &options,
+		Engine:       game.Engine,
+		Board:        board,
+		History:      game.HistoryTable,
+		TargetTimeMs: 500,
+		Info:         info,
+		Timeout:      &timeout,
+	})
 	if err != nil {
 		return err
 	}
 
-	return state.client.PostMove(game.ID, move.String())
+	log.Printf("bot: Playing %s in game %s after a depth %d search", &result.BestMove, game.ID, result.Depth)
+	return state.client.PostMove(game.ID, result.BestMove.String())
 }
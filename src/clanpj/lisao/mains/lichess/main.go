@@ -3,12 +3,23 @@ package main
 import (
 	"flag"
 	"fmt"
+	"strconv"
 	"sync"
 
+	"clanpj/lisao/book"
+	"clanpj/lisao/engine"
+	"clanpj/lisao/frontend"
 	"clanpj/lisao/lichess"
 )
 
 var apiKey = flag.String("api-key", "", "The Lichess API key to use for this bot's requests.")
+var bookFile = flag.String("book", "", "Polyglot opening book (.bin) to play from, if any.")
+var bookDepth = flag.Int("book-depth", book.DefaultMaxDepth, "Max game ply at which the opening book is used (0 for no limit).")
+var bookBest = flag.Bool("book-best", false, "Always play the highest-weighted book move rather than a weighted-random choice.")
+var syzygyPath = flag.String("syzygy-path", "", "Directories containing Syzygy endgame tablebases, separated by the OS path list separator.")
+var contempt = flag.Int("contempt", 0, "Centipawns the bot subtracts from the score of a draw - positive avoids draws, negative seeks them. Overrides Contempt in the -config file.")
+var configFile = flag.String("config", "", "Engine options file with one Name=Value per line, using the UCI option names - including the book, SyzygyPath and EvalFile options.")
+var autoContempt = flag.Bool("auto-contempt", false, "Set the contempt for each game from the rating difference between the bot and its opponent.")
 
 func main() {
 	flag.Parse()
@@ -21,6 +32,47 @@ func main() {
 
 	client := lichess.NewLichessClient(*apiKey)
 	state := NewState(client)
+	state.autoContempt = *autoContempt
+
+	// The front-end options are registered along with the search options, so the config file can set them too
+	state.options = engine.NewOptions()
+	state.frontEnd = frontend.NewFrontEnd()
+	registry := engine.NewOptionRegistry(state.options)
+	state.frontEnd.AddOptions(registry)
+	if *configFile != "" {
+		err := registry.LoadFile(*configFile)
+		if err != nil {
+			fmt.Printf("Failed to load engine options from %s: %v\n", *configFile, err)
+			return
+		}
+	}
+
+	// The book, tablebase and contempt flags are shorthand for the same options, and override the config file
+	var flagOptions [][2]string
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "book":
+			flagOptions = append(flagOptions, [2]string{"OwnBook", "true"}, [2]string{"BookFile", *bookFile})
+		case "book-depth":
+			flagOptions = append(flagOptions, [2]string{"BookDepth", strconv.Itoa(*bookDepth)})
+		case "book-best":
+			selection := book.SelectWeighted
+			if *bookBest {
+				selection = book.SelectBest
+			}
+			flagOptions = append(flagOptions, [2]string{"BookSelection", book.SelectionString(selection)})
+		case "syzygy-path":
+			flagOptions = append(flagOptions, [2]string{"SyzygyPath", *syzygyPath})
+		case "contempt":
+			flagOptions = append(flagOptions, [2]string{"Contempt", strconv.Itoa(*contempt)})
+		}
+	})
+	for _, option := range flagOptions {
+		if err := registry.Set(option[0], option[1]); err != nil {
+			fmt.Printf("Bad command-line flag: %v\n", err)
+			return
+		}
+	}
 
 	var waitGroup sync.WaitGroup
 	waitGroup.Add(3)
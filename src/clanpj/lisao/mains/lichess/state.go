@@ -3,6 +3,8 @@ package main
 import (
 	"sync"
 
+	"clanpj/lisao/engine"
+	"clanpj/lisao/frontend"
 	"clanpj/lisao/lichess"
 )
 
@@ -12,6 +14,15 @@ type State struct {
 
 	challenges  []Challenge
 	activeGames []*Game
+
+	// Opening book, tablebases and eval weights for every game
+	frontEnd *frontend.FrontEndT
+
+	// Engine options for every game - contempt is then set per game
+	options *engine.OptionsT
+
+	// Whether to derive the contempt from the players' ratings rather than use the Contempt option
+	autoContempt bool
 }
 
 func NewState(client *lichess.LichessClient) *State {
@@ -0,0 +1,438 @@
+// Self-play match runner - plays two engine configurations against each other
+// and reports Elo with an optional SPRT stopping rule.
+//
+// Each engine is either the in-process engine ("lisao") or an external UCI engine binary.
+// Two configurations of Lisao can be compared in-process, since each in-process player has its own engine, e.g.
+// match -options1 HeurUseNullMove=false -tc 10+0.1 -sprt
+// or against an external build of the UCI binary with the same options, e.g.
+// match -engine2 ./uci -options2 HeurUseNullMove=false
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+
+	"clanpj/lisao/engine"
+)
+
+var engine1Flag = flag.String("engine1", "lisao", "First engine - 'lisao' for the in-process engine, otherwise the path of a UCI engine binary.")
+var engine2Flag = flag.String("engine2", "lisao", "Second engine - 'lisao' for the in-process engine, otherwise the path of a UCI engine binary.")
+var options1Flag = flag.String("options1", "", "Comma-separated UCI options for the first engine, e.g. 'HeurUseNullMove=false,QSearchDepth=8'.")
+var options2Flag = flag.String("options2", "", "Comma-separated UCI options for the second engine.")
+var openingsFlag = flag.String("openings", "", "Opening file - one FEN/EPD or space-separated list of UCI moves from the start position per line.")
+var gamesFlag = flag.Int("games", 100, "Maximum number of games - each opening is played twice with colours reversed.")
+var tcFlag = flag.String("tc", "10+0.1", "Time control as base+increment in seconds.")
+var moveTimeFlag = flag.Int("movetime", 0, "Fixed time per move in ms - overrides -tc.")
+var pgnFlag = flag.String("pgn", "", "Append the games in PGN format to this file.")
+var maxMovesFlag = flag.Int("maxmoves", 200, "Adjudicate a draw after this many moves.")
+var drawMovesFlag = flag.Int("drawmoves", 10, "Adjudicate a draw if both engines' scores are within -drawscore for this many consecutive moves after move 40 (0 to disable).")
+var drawScoreFlag = flag.Int("drawscore", 10, "Draw adjudication score threshold in centipawns.")
+var resignMovesFlag = flag.Int("resignmoves", 4, "Adjudicate a win if both engines agree the score is beyond -resignscore for this many consecutive moves (0 to disable).")
+var resignScoreFlag = flag.Int("resignscore", 800, "Resign adjudication score threshold in centipawns.")
+var sprtFlag = flag.Bool("sprt", false, "Stop early according to the SPRT.")
+var elo0Flag = flag.Float64("elo0", 0, "SPRT H0 Elo difference.")
+var elo1Flag = flag.Float64("elo1", 5, "SPRT H1 Elo difference.")
+var alphaFlag = flag.Float64("alpha", 0.05, "SPRT type I error.")
+var betaFlag = flag.Float64("beta", 0.05, "SPRT type II error.")
+
+type GameResultT int
+
+const (
+	WhiteWins GameResultT = iota
+	BlackWins
+	Draw
+)
+
+var pgnResults = [3]string{"1-0", "0-1", "1/2-1/2"}
+
+type GameT struct {
+	Round     int
+	White     PlayerT
+	Black     PlayerT
+	StartFen  string
+	Moves     []string // UCI format
+	SanMoves  []string
+	Result    GameResultT
+	Reason    string
+	StartTime time.Time
+}
+
+func main() {
+	flag.Parse()
+
+	clock, err := parseTimeControl(*tcFlag, *moveTimeFlag)
+	if err != nil {
+		log.Fatalf("match: %v", err)
+	}
+
+	openings := []string{dragon.Startpos}
+	if *openingsFlag != "" {
+		openings, err = readOpenings(*openingsFlag)
+		if err != nil {
+			log.Fatalf("match: Error reading openings from %s: %v", *openingsFlag, err)
+		}
+	}
+
+	player1, err := newPlayer(*engine1Flag, *options1Flag)
+	if err != nil {
+		log.Fatalf("match: Error starting engine1 %s: %v", *engine1Flag, err)
+	}
+	defer player1.Close()
+	player2, err := newPlayer(*engine2Flag, *options2Flag)
+	if err != nil {
+		log.Fatalf("match: Error starting engine2 %s: %v", *engine2Flag, err)
+	}
+	defer player2.Close()
+
+	var pgnFile *os.File
+	if *pgnFlag != "" {
+		pgnFile, err = os.OpenFile(*pgnFlag, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatalf("match: Error opening PGN file %s: %v", *pgnFlag, err)
+		}
+		defer pgnFile.Close()
+	}
+
+	sprt := SprtT{Elo0: *elo0Flag, Elo1: *elo1Flag, Alpha: *alphaFlag, Beta: *betaFlag}
+	var score ScoreT
+
+	for round := 0; round < *gamesFlag; round++ {
+		// Each opening is played twice with colours reversed
+		opening := openings[(round/2)%len(openings)]
+		white, black := player1, player2
+		if round%2 == 1 {
+			white, black = player2, player1
+		}
+
+		game, err := playGame(round+1, white, black, opening, clock)
+		if err != nil {
+			log.Fatalf("match: Error in game %d: %v", round+1, err)
+		}
+
+		switch {
+		case game.Result == Draw:
+			score.Draws++
+		case (game.Result == WhiteWins) == (white == player1):
+			score.Wins++
+		default:
+			score.Losses++
+		}
+
+		if pgnFile != nil {
+			writePgn(pgnFile, game, clock)
+		}
+
+		elo, errorMargin := score.Elo()
+		fmt.Printf("Game %d: %s vs %s %s {%s}\n", game.Round, white.Name(), black.Name(), pgnResults[game.Result], game.Reason)
+		fmt.Printf("Score of %s vs %s: %d - %d - %d [%.3f] %d\n", player1.Name(), player2.Name(), score.Wins, score.Losses, score.Draws, score.Mean(), score.Games())
+		fmt.Printf("Elo difference: %.1f +/- %.1f, LOS: %.1f%%\n", elo, errorMargin, score.LOS()*100)
+
+		if *sprtFlag {
+			result, llr := sprt.Test(&score)
+			lower, upper := sprt.Bounds()
+			fmt.Printf("SPRT: llr %.3f, lbound %.3f, ubound %.3f", llr, lower, upper)
+			if result == SprtAcceptH0 {
+				fmt.Println(" - H0 was accepted")
+				break
+			} else if result == SprtAcceptH1 {
+				fmt.Println(" - H1 was accepted")
+				break
+			}
+			fmt.Println()
+		}
+	}
+}
+
+func newPlayer(name string, options string) (PlayerT, error) {
+	var optionList []string
+	if options != "" {
+		optionList = strings.Split(options, ",")
+	}
+	if name == "lisao" {
+		return NewEnginePlayer(optionList)
+	}
+	return NewUciPlayer(name, optionList)
+}
+
+// Time control is base+inc in seconds, e.g. 10+0.1, or just base
+func parseTimeControl(tc string, moveTimeMs int) (ClockT, error) {
+	var clock ClockT
+	if moveTimeMs > 0 {
+		clock.MoveTimeMs = moveTimeMs
+		return clock, nil
+	}
+
+	baseInc := strings.SplitN(tc, "+", 2)
+	base, err := strconv.ParseFloat(baseInc[0], 64)
+	if err != nil || base <= 0 {
+		return clock, fmt.Errorf("malformed time control '%s'", tc)
+	}
+	inc := 0.0
+	if len(baseInc) == 2 {
+		inc, err = strconv.ParseFloat(baseInc[1], 64)
+		if err != nil || inc < 0 {
+			return clock, fmt.Errorf("malformed time control '%s'", tc)
+		}
+	}
+
+	clock.WTimeMs, clock.BTimeMs = int(base*1000), int(base*1000)
+	clock.WIncMs, clock.BIncMs = int(inc*1000), int(inc*1000)
+	return clock, nil
+}
+
+// Openings are either FEN/EPD positions or lists of UCI moves from the start position.
+// Return a FEN for each opening.
+func readOpenings(fileName string) ([]string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var openings []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if strings.Contains(fields[0], "/") {
+			// FEN or EPD - EPD has no clocks and may have opcodes
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("malformed FEN '%s'", line)
+			}
+			fen := strings.Join(fields[:4], " ") + " 0 1"
+			if len(fields) >= 6 {
+				if _, err := strconv.Atoi(fields[4]); err == nil {
+					fen = strings.Join(fields[:6], " ")
+				}
+			}
+			openings = append(openings, fen)
+			continue
+		}
+
+		board := dragon.ParseFen(dragon.Startpos)
+		for _, moveStr := range fields {
+			move, err := parseLegalMove(&board, moveStr)
+			if err != nil {
+				return nil, err
+			}
+			board.Apply(move)
+		}
+		openings = append(openings, board.ToFen())
+	}
+
+	if len(openings) == 0 {
+		return nil, fmt.Errorf("no openings found")
+	}
+	return openings, scanner.Err()
+}
+
+func parseLegalMove(board *dragon.Board, moveStr string) (dragon.Move, error) {
+	for _, move := range board.GenerateLegalMoves() {
+		if move.String() == moveStr {
+			return move, nil
+		}
+	}
+	return engine.NoMove, fmt.Errorf("illegal move %s in position %s", moveStr, board.ToFen())
+}
+
+// Play a single game, adjudicating draws and wins according to the flags
+func playGame(round int, white PlayerT, black PlayerT, startFen string, clock ClockT) (*GameT, error) {
+	game := &GameT{Round: round, White: white, Black: black, StartFen: startFen, StartTime: time.Now()}
+
+	if err := white.NewGame(); err != nil {
+		return nil, err
+	}
+	if err := black.NewGame(); err != nil {
+		return nil, err
+	}
+
+	board := dragon.ParseFen(startFen)
+	repetitions := map[uint64]int{board.Hash(): 1}
+	drawCount, whiteWinCount, blackWinCount := 0, 0, 0
+
+	for {
+		legalMoves := board.GenerateLegalMoves()
+		if len(legalMoves) == 0 {
+			if board.OurKingInCheck() {
+				// The side to move is mated
+				game.Result, game.Reason = BlackWins, "Black mates"
+				if !board.Wtomove {
+					game.Result, game.Reason = WhiteWins, "White mates"
+				}
+			} else {
+				game.Result, game.Reason = Draw, "Stalemate"
+			}
+			return game, nil
+		}
+		if board.Halfmoveclock >= 100 {
+			game.Result, game.Reason = Draw, "Fifty move rule"
+			return game, nil
+		}
+		if repetitions[board.Hash()] >= 3 {
+			game.Result, game.Reason = Draw, "3-fold repetition"
+			return game, nil
+		}
+		if engine.IsInsufficientMaterial(&board) {
+			game.Result, game.Reason = Draw, "Insufficient mating material"
+			return game, nil
+		}
+		if len(game.Moves) >= 2**maxMovesFlag {
+			game.Result, game.Reason = Draw, "Draw by adjudication: maximum game length"
+			return game, nil
+		}
+
+		player, timeLeftMs, incMs := white, &clock.WTimeMs, clock.WIncMs
+		if !board.Wtomove {
+			player, timeLeftMs, incMs = black, &clock.BTimeMs, clock.BIncMs
+		}
+		loss, lossReason := BlackWins, "White"
+		if !board.Wtomove {
+			loss, lossReason = WhiteWins, "Black"
+		}
+
+		start := time.Now()
+		result, err := player.Go(startFen, game.Moves, &clock)
+		elapsedMs := int(time.Since(start).Nanoseconds() / 1e6)
+		if err != nil {
+			log.Printf("match: Engine %s failed: %v", player.Name(), err)
+			game.Result, game.Reason = loss, lossReason+" disconnects"
+			return game, nil
+		}
+
+		if clock.MoveTimeMs == 0 {
+			*timeLeftMs -= elapsedMs
+			if *timeLeftMs < 0 {
+				game.Result, game.Reason = loss, lossReason+" loses on time"
+				return game, nil
+			}
+			*timeLeftMs += incMs
+		}
+
+		move, err := parseLegalMove(&board, result.Move)
+		if err != nil {
+			game.Result, game.Reason = loss, lossReason+" makes an illegal move: "+result.Move
+			return game, nil
+		}
+
+		game.SanMoves = append(game.SanMoves, engine.MoveToSan(&board, move))
+		game.Moves = append(game.Moves, result.Move)
+
+		// Adjudication is based on the score from white's perspective
+		whiteScore, whiteMate := result.Score, result.Mate
+		if !board.Wtomove {
+			whiteScore, whiteMate = -whiteScore, -whiteMate
+		}
+
+		board.Apply(move)
+		repetitions[board.Hash()]++
+
+		// Draw adjudication needs consecutive plies from both engines
+		if *drawMovesFlag > 0 && len(game.Moves) >= 80 && !result.IsMate && abs(whiteScore) <= *drawScoreFlag {
+			drawCount++
+			if drawCount >= 2**drawMovesFlag {
+				game.Result, game.Reason = Draw, "Draw by adjudication"
+				return game, nil
+			}
+		} else {
+			drawCount = 0
+		}
+
+		// Win adjudication needs consecutive plies from both engines agreeing on the winner
+		if *resignMovesFlag > 0 && ((result.IsMate && whiteMate > 0) || (!result.IsMate && whiteScore >= *resignScoreFlag)) {
+			whiteWinCount, blackWinCount = whiteWinCount+1, 0
+		} else if *resignMovesFlag > 0 && ((result.IsMate && whiteMate < 0) || (!result.IsMate && whiteScore <= -*resignScoreFlag)) {
+			whiteWinCount, blackWinCount = 0, blackWinCount+1
+		} else {
+			whiteWinCount, blackWinCount = 0, 0
+		}
+		if *resignMovesFlag > 0 && whiteWinCount >= 2**resignMovesFlag {
+			game.Result, game.Reason = WhiteWins, "White wins by adjudication"
+			return game, nil
+		}
+		if *resignMovesFlag > 0 && blackWinCount >= 2**resignMovesFlag {
+			game.Result, game.Reason = BlackWins, "Black wins by adjudication"
+			return game, nil
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func writePgn(file *os.File, game *GameT, clock ClockT) {
+	w := bufio.NewWriter(file)
+	defer w.Flush()
+
+	timeControl := fmt.Sprintf("%d+%g", clock.WTimeMs/1000, float64(clock.WIncMs)/1000)
+	if clock.MoveTimeMs != 0 {
+		timeControl = fmt.Sprintf("%g/move", float64(clock.MoveTimeMs)/1000)
+	}
+
+	fmt.Fprintf(w, "[Event \"Lisao match\"]\n")
+	fmt.Fprintf(w, "[Site \"?\"]\n")
+	fmt.Fprintf(w, "[Date \"%s\"]\n", game.StartTime.Format("2006.01.02"))
+	fmt.Fprintf(w, "[Round \"%d\"]\n", game.Round)
+	fmt.Fprintf(w, "[White \"%s\"]\n", game.White.Name())
+	fmt.Fprintf(w, "[Black \"%s\"]\n", game.Black.Name())
+	fmt.Fprintf(w, "[Result \"%s\"]\n", pgnResults[game.Result])
+	if game.StartFen != dragon.Startpos {
+		fmt.Fprintf(w, "[SetUp \"1\"]\n")
+		fmt.Fprintf(w, "[FEN \"%s\"]\n", game.StartFen)
+	}
+	fmt.Fprintf(w, "[TimeControl \"%s\"]\n", timeControl)
+	fmt.Fprintf(w, "[PlyCount \"%d\"]\n", len(game.SanMoves))
+	fmt.Fprintf(w, "[Termination \"%s\"]\n\n", game.Reason)
+
+	board := dragon.ParseFen(game.StartFen)
+	moveNo := int(board.Fullmoveno)
+	whiteToMove := board.Wtomove
+
+	var tokens []string
+	for i, san := range game.SanMoves {
+		if whiteToMove {
+			tokens = append(tokens, fmt.Sprintf("%d.", moveNo))
+		} else if i == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d...", moveNo))
+		}
+		tokens = append(tokens, san)
+		if !whiteToMove {
+			moveNo++
+		}
+		whiteToMove = !whiteToMove
+	}
+	tokens = append(tokens, "{"+game.Reason+"}", pgnResults[game.Result])
+
+	// Wrap at 80 columns
+	lineLen := 0
+	for i, token := range tokens {
+		if i > 0 {
+			if lineLen+1+len(token) > 80 {
+				fmt.Fprintln(w)
+				lineLen = 0
+			} else {
+				fmt.Fprint(w, " ")
+				lineLen++
+			}
+		}
+		fmt.Fprint(w, token)
+		lineLen += len(token)
+	}
+	fmt.Fprint(w, "\n\n")
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+
+	"clanpj/lisao/engine"
+)
+
+// Clock state passed to the player to move
+type ClockT struct {
+	MoveTimeMs int // fixed time per move if != 0
+	WTimeMs    int
+	BTimeMs    int
+	WIncMs     int
+	BIncMs     int
+}
+
+type MoveResultT struct {
+	Move   string // UCI format
+	Score  int    // centipawns from the mover's perspective (if !IsMate)
+	Mate   int    // moves to mate from the mover's perspective (if IsMate) - negative if we're being mated
+	IsMate bool
+}
+
+// A match player - either the in-process engine or an external UCI engine
+type PlayerT interface {
+	Name() string
+	NewGame() error
+	// Find a move for the position reached from the start FEN after the given moves (in UCI format)
+	Go(startFen string, moves []string, clock *ClockT) (MoveResultT, error)
+	Close()
+}
+
+// In-process engine player - each has its own engine instance, so both players in a match can be in-process
+type enginePlayer struct {
+	name    string
+	options *engine.OptionsT
+	lisao   *engine.EngineT // hash tables and eval weights
+	timeout uint32
+}
+
+// Options are of the form "Name=Value" with the same names as the UCI options
+func NewEnginePlayer(options []string) (PlayerT, error) {
+	p := &enginePlayer{name: "Lisao in-process", options: engine.NewOptions()}
+	// Tell the players apart in self-play
+	if len(options) > 0 {
+		p.name += " (" + strings.Join(options, ",") + ")"
+	}
+	registry := engine.NewOptionRegistry(p.options)
+	for _, option := range options {
+		if err := registry.SetNameValue(option); err != nil {
+			return nil, err
+		}
+	}
+	p.lisao = engine.NewEngine(p.options)
+	return p, nil
+}
+
+func (p *enginePlayer) Name() string { return p.name }
+
+func (p *enginePlayer) NewGame() error {
+	p.lisao.ResetTT()
+	p.lisao.ResetQtt()
+	p.lisao.ResetMoveHistory()
+	return nil
+}
+
+// Same strategy as the UCI front-end - a fixed fraction of the remaining time
+const timeLeftPerMoveDivisor = 16
+
+func (p *enginePlayer) Go(startFen string, moves []string, clock *ClockT) (MoveResultT, error) {
+	var result MoveResultT
+
+	board := dragon.ParseFen(startFen)
+	ht := make(engine.HistoryTableT)
+	ht.Add(board.Hash())
+	for _, moveStr := range moves {
+		move, err := dragon.ParseMove(moveStr)
+		if err != nil {
+			return result, err
+		}
+		board.Apply(move)
+		ht.Add(board.Hash())
+	}
+
+	timeoutMs := clock.MoveTimeMs
+	if timeoutMs == 0 {
+		ourTimeMs, ourIncMs := clock.WTimeMs, clock.WIncMs
+		if !board.Wtomove {
+			ourTimeMs, ourIncMs = clock.BTimeMs, clock.BIncMs
+		}
+		timeoutMs = ourTimeMs / timeLeftPerMoveDivisor
+		if timeoutMs <= 0 {
+			timeoutMs = ourIncMs
+		}
+	}
+
+	atomic.StoreUint32(&p.timeout, 0)
+	timer := time.AfterFunc(time.Duration(timeoutMs)*time.Millisecond, func() { atomic.StoreUint32(&p.timeout, 1) })
+	defer timer.Stop()
+
+	searchResult, err := engine.Search(engine.SearchRequestT{Options: p.options, Engine: p.lisao, Board: &board, History: ht, TargetTimeMs: timeoutMs, Timeout: &p.timeout})
+	if err != nil {
+		return result, err
+	}
+
+	result.Move = searchResult.BestMove.String()
+	if searchResult.ScoreType == engine.ScoreMate {
+		result.IsMate, result.Mate = true, searchResult.Score
+	} else {
+		result.Score = searchResult.Score
+	}
+
+	return result, nil
+}
+
+func (p *enginePlayer) Close() {}
+
+// External UCI engine player
+type uciPlayer struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+}
+
+// How long we wait for UCI engine responses other than bestmove
+const uciResponseTimeout = 10 * time.Second
+
+// Start the UCI engine and apply the options, which are of the form "Name=Value"
+func NewUciPlayer(path string, options []string) (PlayerT, error) {
+	cmd := exec.Command(path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &uciPlayer{name: path, cmd: cmd, stdin: stdin, lines: make(chan string, 256)}
+
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			p.lines <- scanner.Text()
+		}
+		close(p.lines)
+	}()
+
+	p.send("uci")
+	err = p.waitFor("uciok", uciResponseTimeout, func(line string) {
+		if strings.HasPrefix(line, "id name ") {
+			p.name = strings.TrimPrefix(line, "id name ")
+		}
+	})
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	for _, option := range options {
+		nameValue := strings.SplitN(option, "=", 2)
+		if len(nameValue) != 2 {
+			p.Close()
+			return nil, fmt.Errorf("match: malformed option '%s' - expected Name=Value", option)
+		}
+		p.send("setoption name " + nameValue[0] + " value " + nameValue[1])
+	}
+
+	if err := p.isReady(); err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *uciPlayer) Name() string { return p.name }
+
+func (p *uciPlayer) send(cmd string) {
+	fmt.Fprintln(p.stdin, cmd)
+}
+
+// Wait for a line starting with the given prefix, passing all other lines to the handler (if not nil).
+// Returns the matching line.
+func (p *uciPlayer) waitForLine(prefix string, timeout time.Duration, handler func(line string)) (string, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case line, ok := <-p.lines:
+			if !ok {
+				return "", fmt.Errorf("match: engine %s exited while waiting for %s", p.name, prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line, nil
+			}
+			if handler != nil {
+				handler(line)
+			}
+		case <-timer.C:
+			return "", fmt.Errorf("match: engine %s timed out waiting for %s", p.name, prefix)
+		}
+	}
+}
+
+func (p *uciPlayer) waitFor(prefix string, timeout time.Duration, handler func(line string)) error {
+	_, err := p.waitForLine(prefix, timeout, handler)
+	return err
+}
+
+func (p *uciPlayer) isReady() error {
+	p.send("isready")
+	return p.waitFor("readyok", uciResponseTimeout, nil)
+}
+
+func (p *uciPlayer) NewGame() error {
+	p.send("ucinewgame")
+	return p.isReady()
+}
+
+func (p *uciPlayer) Go(startFen string, moves []string, clock *ClockT) (MoveResultT, error) {
+	var result MoveResultT
+
+	position := "position fen " + startFen
+	if startFen == dragon.Startpos {
+		position = "position startpos"
+	}
+	if len(moves) > 0 {
+		position += " moves " + strings.Join(moves, " ")
+	}
+	p.send(position)
+
+	// Allow some slack for engine and communication latency before we give up - time losses are adjudicated by the caller
+	var limit time.Duration
+	if clock.MoveTimeMs != 0 {
+		p.send(fmt.Sprintf("go movetime %d", clock.MoveTimeMs))
+		limit = time.Duration(clock.MoveTimeMs) * time.Millisecond
+	} else {
+		p.send(fmt.Sprintf("go wtime %d btime %d winc %d binc %d", clock.WTimeMs, clock.BTimeMs, clock.WIncMs, clock.BIncMs))
+		limit = time.Duration(clock.WTimeMs+clock.BTimeMs) * time.Millisecond
+	}
+	limit += uciResponseTimeout
+
+	line, err := p.waitForLine("bestmove", limit, func(line string) {
+		if strings.HasPrefix(line, "info ") {
+			parseUciScore(line, &result)
+		}
+	})
+	if err != nil {
+		return result, err
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return result, fmt.Errorf("match: engine %s sent malformed bestmove '%s'", p.name, line)
+	}
+	result.Move = fields[1]
+
+	return result, nil
+}
+
+// Pick out the score from a UCI info line if it's there
+func parseUciScore(line string, result *MoveResultT) {
+	fields := strings.Fields(line)
+	for i := 0; i+2 < len(fields); i++ {
+		if fields[i] != "score" {
+			continue
+		}
+		n, err := strconv.Atoi(fields[i+2])
+		if err != nil {
+			return
+		}
+		switch fields[i+1] {
+		case "cp":
+			result.Score, result.IsMate = n, false
+		case "mate":
+			result.Mate, result.IsMate = n, true
+		}
+		return
+	}
+}
+
+func (p *uciPlayer) Close() {
+	p.send("quit")
+	p.stdin.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- p.cmd.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(uciResponseTimeout):
+		p.cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,116 @@
+// Elo estimation and Sequential Probability Ratio Test (SPRT) for match results
+
+package main
+
+import (
+	"math"
+)
+
+// Win/draw/loss totals from the perspective of the first engine
+type ScoreT struct {
+	Wins   int
+	Draws  int
+	Losses int
+}
+
+func (s *ScoreT) Games() int { return s.Wins + s.Draws + s.Losses }
+
+// Mean score per game, from 0.0 to 1.0
+func (s *ScoreT) Mean() float64 {
+	n := s.Games()
+	if n == 0 {
+		return 0.5
+	}
+	return (float64(s.Wins) + float64(s.Draws)/2) / float64(n)
+}
+
+// Per-game variance of the score
+func (s *ScoreT) Variance() float64 {
+	n := float64(s.Games())
+	if n == 0 {
+		return 0
+	}
+	mean := s.Mean()
+	return (float64(s.Wins)*(1-mean)*(1-mean) + float64(s.Draws)*(0.5-mean)*(0.5-mean) + float64(s.Losses)*mean*mean) / n
+}
+
+// Logistic Elo difference for a mean score
+func scoreToElo(score float64) float64 {
+	// Clamp to avoid infinities for perfect scores
+	score = math.Max(1e-6, math.Min(1-1e-6, score))
+	return -400 * math.Log10(1/score-1)
+}
+
+// Expected mean score for a logistic Elo difference
+func eloToScore(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+// Elo difference estimate and 95% confidence interval half-width
+func (s *ScoreT) Elo() (float64, float64) {
+	n := float64(s.Games())
+	mean := s.Mean()
+	elo := scoreToElo(mean)
+	if n == 0 {
+		return elo, 0
+	}
+	stdDev := math.Sqrt(s.Variance() / n)
+	const z95 = 1.959964
+	lo := scoreToElo(mean - z95*stdDev)
+	hi := scoreToElo(mean + z95*stdDev)
+	return elo, (hi - lo) / 2
+}
+
+// Likelihood of superiority - the probability that the first engine is stronger (draws are ignored)
+func (s *ScoreT) LOS() float64 {
+	if s.Wins+s.Losses == 0 {
+		return 0.5
+	}
+	return 0.5 * (1 + math.Erf(float64(s.Wins-s.Losses)/math.Sqrt(2*float64(s.Wins+s.Losses))))
+}
+
+type SprtT struct {
+	Elo0  float64 // H0: the Elo difference is elo0
+	Elo1  float64 // H1: the Elo difference is elo1
+	Alpha float64 // Type I error - false positive
+	Beta  float64 // Type II error - false negative
+}
+
+type SprtResultT int
+
+const (
+	SprtContinue SprtResultT = iota
+	SprtAcceptH0
+	SprtAcceptH1
+)
+
+// Log-likelihood ratio bounds
+func (sprt *SprtT) Bounds() (float64, float64) {
+	return math.Log(sprt.Beta / (1 - sprt.Alpha)), math.Log((1 - sprt.Beta) / sprt.Alpha)
+}
+
+// Pseudo-games added to each of the win, draw and loss counts for the LLR, so that the variance estimate is never zero.
+// Without them an all-draw or all-win run would have no variance estimate and never stop.
+const sprtPriorGames = 0.5
+
+// Log-likelihood ratio of H1 vs H0 using the normal approximation to the trinomial (W/D/L) distribution
+func (sprt *SprtT) LLR(s *ScoreT) float64 {
+	wins, draws, losses := float64(s.Wins)+sprtPriorGames, float64(s.Draws)+sprtPriorGames, float64(s.Losses)+sprtPriorGames
+	n := wins + draws + losses
+	mean := (wins + draws/2) / n
+	variance := (wins*(1-mean)*(1-mean) + draws*(0.5-mean)*(0.5-mean) + losses*mean*mean) / n
+	s0, s1 := eloToScore(sprt.Elo0), eloToScore(sprt.Elo1)
+	return (s1 - s0) * (2*mean - s0 - s1) * n / (2 * variance)
+}
+
+// Should we stop? And if so, which hypothesis is accepted?
+func (sprt *SprtT) Test(s *ScoreT) (SprtResultT, float64) {
+	llr := sprt.LLR(s)
+	lower, upper := sprt.Bounds()
+	if llr <= lower {
+		return SprtAcceptH0, llr
+	} else if llr >= upper {
+		return SprtAcceptH1, llr
+	}
+	return SprtContinue, llr
+}
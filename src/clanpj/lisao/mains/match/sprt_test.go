@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func isClose(a float64, b float64, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestElo(t *testing.T) {
+	var tests = []struct {
+		score  ScoreT
+		elo    float64
+		margin float64
+	}{
+		{ScoreT{Wins: 50, Draws: 0, Losses: 50}, 0, 68.99},
+		{ScoreT{Wins: 60, Draws: 20, Losses: 20}, 147.19, 66.01}, // 70%
+		{ScoreT{Wins: 50, Draws: 50, Losses: 0}, 190.85, 45.86},  // 75%
+		{ScoreT{Wins: 20, Draws: 20, Losses: 60}, -147.19, 66.01},
+	}
+
+	for _, test := range tests {
+		elo, margin := test.score.Elo()
+		if !isClose(elo, test.elo, 0.01) || !isClose(margin, test.margin, 0.01) {
+			t.Errorf("W/D/L %d/%d/%d: Elo is %.2f +/- %.2f expected %.2f +/- %.2f\n", test.score.Wins, test.score.Draws, test.score.Losses, elo, margin, test.elo, test.margin)
+		}
+	}
+
+	// Elo and expected score are inverses
+	for _, elo := range []float64{-400, -100, 0, 35, 400} {
+		if e := scoreToElo(eloToScore(elo)); !isClose(e, elo, 1e-6) {
+			t.Errorf("scoreToElo(eloToScore(%.0f)) is %.6f\n", elo, e)
+		}
+	}
+	if !isClose(eloToScore(400), 10.0/11, 1e-9) {
+		t.Errorf("Expected score for +400 Elo is %.6f expected 10/11\n", eloToScore(400))
+	}
+}
+
+func TestLOS(t *testing.T) {
+	var tests = []struct {
+		score ScoreT
+		los   float64
+	}{
+		{ScoreT{Wins: 0, Draws: 10, Losses: 0}, 0.5},
+		{ScoreT{Wins: 30, Draws: 40, Losses: 30}, 0.5},
+		{ScoreT{Wins: 10, Draws: 100, Losses: 5}, 0.9016},
+		{ScoreT{Wins: 5, Draws: 0, Losses: 10}, 0.0984},
+	}
+
+	for _, test := range tests {
+		if los := test.score.LOS(); !isClose(los, test.los, 1e-4) {
+			t.Errorf("W/D/L %d/%d/%d: LOS is %.4f expected %.4f\n", test.score.Wins, test.score.Draws, test.score.Losses, los, test.los)
+		}
+	}
+}
+
+func TestSprt(t *testing.T) {
+	sprt := SprtT{Elo0: 0, Elo1: 5, Alpha: 0.05, Beta: 0.05}
+
+	// The standard bounds for 5% errors are ln(1/19) and ln(19)
+	lower, upper := sprt.Bounds()
+	if !isClose(lower, -2.9444, 1e-4) || !isClose(upper, 2.9444, 1e-4) {
+		t.Errorf("SPRT bounds are [%.4f, %.4f] expected [-2.9444, 2.9444]\n", lower, upper)
+	}
+
+	var tests = []struct {
+		score  ScoreT
+		llr    float64
+		result SprtResultT
+	}{
+		{ScoreT{Wins: 20000, Draws: 10000, Losses: 20000}, -6.4714, SprtAcceptH0},
+		{ScoreT{Wins: 400, Draws: 200, Losses: 400}, -0.1296, SprtContinue},
+		{ScoreT{Wins: 10, Draws: 0, Losses: 0}, 0.7122, SprtContinue},
+		{ScoreT{}, -0.0002, SprtContinue},
+	}
+	for _, test := range tests {
+		result, llr := sprt.Test(&test.score)
+		if !isClose(llr, test.llr, 1e-4) || result != test.result {
+			t.Errorf("W/D/L %d/%d/%d: LLR is %.4f (%d) expected %.4f (%d)\n", test.score.Wins, test.score.Draws, test.score.Losses, llr, result, test.llr, test.result)
+		}
+	}
+
+	// A clearly stronger engine is accepted, and the LLR is the difference of the normal log-likelihoods of the two
+	// hypotheses - for the score with the prior pseudo-games
+	score := ScoreT{Wins: 600, Draws: 300, Losses: 100}
+	result, llr := sprt.Test(&score)
+	regularised := ScoreT{Wins: 1200 + 1, Draws: 600 + 1, Losses: 200 + 1} // doubled to keep the half games whole
+	n, mean, variance := float64(regularised.Games())/2, regularised.Mean(), regularised.Variance()
+	s0, s1 := eloToScore(sprt.Elo0), eloToScore(sprt.Elo1)
+	expectedLlr := n * ((mean-s0)*(mean-s0) - (mean-s1)*(mean-s1)) / (2 * variance)
+	if result != SprtAcceptH1 || !isClose(llr, expectedLlr, 1e-9) {
+		t.Errorf("W/D/L 600/300/100: LLR is %.4f (%d) expected %.4f and H1 accepted\n", llr, result, expectedLlr)
+	}
+
+	// All draws or all wins still stop - there's no difference between equal engines, or a big one
+	for _, test := range []struct {
+		name   string
+		add    func(s *ScoreT)
+		result SprtResultT
+	}{
+		{"draws", func(s *ScoreT) { s.Draws++ }, SprtAcceptH0},
+		{"wins", func(s *ScoreT) { s.Wins++ }, SprtAcceptH1},
+	} {
+		var score ScoreT
+		result := SprtContinue
+		for score.Games() < 1000 && result == SprtContinue {
+			test.add(&score)
+			result, _ = sprt.Test(&score)
+		}
+		if result != test.result {
+			t.Errorf("%d %s: SPRT result is %d expected %d\n", score.Games(), test.name, result, test.result)
+		}
+	}
+}
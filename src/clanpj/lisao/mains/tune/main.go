@@ -0,0 +1,176 @@
+// Texel-style eval tuner - minimises the error between the game result and the sigmoid-mapped static eval
+// over a set of labelled positions, by local search over all of the engine's tunable eval weights.
+//
+// The positions should be quiet (e.g. the Zurichess quiet-labeled.epd set) since we use the static eval without q-search.
+// The tuned weights are written as JSON which can be loaded with the UCI EvalFile option, or used as the
+// starting point for further tuning with -params.
+// e.g. tune -out tuned.json quiet-labeled.epd
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"runtime"
+	"sync"
+	"time"
+
+	"clanpj/lisao/engine"
+)
+
+var kFlag = flag.Float64("k", 0, "Sigmoid scaling constant (0 to fit K to the positions before tuning).")
+var passesFlag = flag.Int("passes", 100, "Maximum number of local search passes over the params.")
+var stepFlag = flag.Int("step", 1, "Local search step size.")
+var tuneFlag = flag.String("tune", "", "Only tune the params whose name matches this regexp, e.g. 'Val$|PassedPawn'.")
+var paramsFlag = flag.String("params", "", "Start from the eval weights in this JSON file rather than the built-in weights.")
+var outFlag = flag.String("out", "tuned.json", "Write the tuned weights as JSON to this file after each pass.")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: tune [flags] positions.epd ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return
+	}
+
+	var positions []PositionT
+	for _, fileName := range flag.Args() {
+		filePositions, err := readPositions(fileName)
+		if err != nil {
+			log.Fatalf("tune: Error reading %s: %v", fileName, err)
+		}
+		positions = append(positions, filePositions...)
+	}
+	fmt.Println("Loaded", len(positions), "positions")
+
+	evalParams := engine.DefaultEvalParams
+	if *paramsFlag != "" {
+		var err error
+		evalParams, err = engine.LoadEvalParams(*paramsFlag)
+		if err != nil {
+			log.Fatalf("tune: Error reading eval params %s: %v", *paramsFlag, err)
+		}
+	}
+	// Without the pawn hash the eval is safe for the parallel eval in evalError(), and setting a param doesn't have to
+	// clear the hash
+	opts := engine.NewOptions()
+	opts.UsePawnHash = false
+	lisao := engine.NewEngine(opts)
+	lisao.SetEvalParams(&evalParams)
+
+	tuneRe, err := regexp.Compile(*tuneFlag)
+	if err != nil {
+		log.Fatalf("tune: Bad -tune regexp: %v", err)
+	}
+	var params []engine.TuningParamT
+	for _, param := range engine.TuningParams(lisao, &evalParams) {
+		if tuneRe.MatchString(param.Name) {
+			params = append(params, param)
+		}
+	}
+	fmt.Println("Tuning", len(params), "params")
+
+	k := *kFlag
+	if k == 0 {
+		k = fitK(lisao, positions)
+	}
+	bestErr := evalError(lisao, positions, k)
+	fmt.Printf("K %.3f initial error %.6f\n", k, bestErr)
+
+	// Texel local search - step each param in each direction for as long as it improves the error
+	for pass := 1; pass <= *passesFlag; pass++ {
+		start := time.Now()
+		nImproved := 0
+		for i := range params {
+			param := &params[i]
+			orig := param.Get()
+			improved := false
+			for _, delta := range []int{*stepFlag, -*stepFlag} {
+				param.Set(orig + delta)
+				if param.Get() == orig {
+					continue // clamped
+				}
+				if err := evalError(lisao, positions, k); err < bestErr {
+					bestErr = err
+					improved = true
+					break
+				}
+			}
+			if improved {
+				nImproved++
+			} else {
+				param.Set(orig)
+			}
+		}
+
+		fmt.Printf("Pass %d error %.6f improved %d params in %.1fs\n", pass, bestErr, nImproved, time.Since(start).Seconds())
+		if err := engine.SaveEvalParams(*outFlag, &evalParams); err != nil {
+			log.Fatalf("tune: Error writing %s: %v", *outFlag, err)
+		}
+		if nImproved == 0 {
+			break
+		}
+	}
+}
+
+// Expected score from white's perspective for a (white's perspective) eval
+func sigmoid(eval engine.EvalCp, k float64) float64 {
+	return 1.0 / (1.0 + math.Pow(10.0, -k*float64(eval)/400.0))
+}
+
+// Mean squared error of the expected score - evaluated in parallel over all CPUs
+func evalError(lisao *engine.EngineT, positions []PositionT, k float64) float64 {
+	nWorkers := runtime.NumCPU()
+	chunkSize := (len(positions) + nWorkers - 1) / nWorkers
+	sums := make([]float64, nWorkers)
+
+	var waitGroup sync.WaitGroup
+	for worker := 0; worker < nWorkers; worker++ {
+		lo, hi := worker*chunkSize, (worker+1)*chunkSize
+		if hi > len(positions) {
+			hi = len(positions)
+		}
+		if lo >= hi {
+			break
+		}
+		waitGroup.Add(1)
+		go func(worker int, chunk []PositionT) {
+			defer waitGroup.Done()
+			sum := 0.0
+			for i := range chunk {
+				diff := chunk[i].Result - sigmoid(lisao.StaticEval(&chunk[i].Board), k)
+				sum += diff * diff
+			}
+			sums[worker] = sum
+		}(worker, positions[lo:hi])
+	}
+	waitGroup.Wait()
+
+	total := 0.0
+	for _, sum := range sums {
+		total += sum
+	}
+	return total / float64(len(positions))
+}
+
+// Find the K that minimises the error for the current weights - coarse scan then refine
+func fitK(lisao *engine.EngineT, positions []PositionT) float64 {
+	bestK, bestErr := 1.0, math.MaxFloat64
+	lo, hi, step := 0.1, 3.0, 0.1
+	for i := 0; i < 3; i++ {
+		for k := lo; k <= hi+step/2; k += step {
+			if err := evalError(lisao, positions, k); err < bestErr {
+				bestK, bestErr = k, err
+			}
+		}
+		lo, hi, step = bestK-step, bestK+step, step/10
+	}
+	return bestK
+}
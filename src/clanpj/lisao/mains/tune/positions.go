@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// A labelled training position
+type PositionT struct {
+	Board  dragon.Board
+	Result float64 // from white's perspective - 1.0 win, 0.5 draw, 0.0 loss
+}
+
+// Game results in the notations used by the common Texel data sets
+var resultVals = map[string]float64{
+	"1-0":     1.0,
+	"0-1":     0.0,
+	"1/2-1/2": 0.5,
+	"1.0":     1.0,
+	"0.5":     0.5,
+	"0.0":     0.0,
+	"1":       1.0,
+	"0":       0.0,
+}
+
+// Read labelled positions - one per line, a FEN followed by the game result, e.g.
+//
+//	rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1 [0.5]
+//	rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - c9 "1/2-1/2";
+//
+// The result may be bracketed or quoted, and the FEN move counters are optional.
+func readPositions(fileName string) ([]PositionT, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var positions []PositionT
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pos, err := parsePosition(line)
+		if err != nil {
+			return nil, fmt.Errorf("tune: %s:%d: %v", fileName, lineNo, err)
+		}
+		positions = append(positions, pos)
+	}
+	return positions, scanner.Err()
+}
+
+func parsePosition(line string) (PositionT, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return PositionT{}, fmt.Errorf("expecting FEN and result, got '%s'", line)
+	}
+
+	// The half-move and full-move counters are optional
+	nFenFields := 4
+	for nFenFields < 6 && nFenFields < len(fields)-1 {
+		if _, err := strconv.Atoi(fields[nFenFields]); err != nil {
+			break
+		}
+		nFenFields++
+	}
+	fen := strings.Join(fields[:nFenFields], " ")
+	// Pad the missing counters - dragon.ParseFen needs all 6 fields
+	switch nFenFields {
+	case 4:
+		fen += " 0 1"
+	case 5:
+		fen += " 1"
+	}
+
+	// Take the last thing that looks like a result
+	result := -1.0
+	for _, field := range fields[nFenFields:] {
+		if val, ok := resultVals[strings.Trim(field, "[]\";")]; ok {
+			result = val
+		}
+	}
+	if result < 0 {
+		return PositionT{}, fmt.Errorf("no game result in '%s'", line)
+	}
+
+	return PositionT{Board: dragon.ParseFen(fen), Result: result}, nil
+}
@@ -15,11 +15,18 @@ import (
 	dragon "github.com/Bubblyworld/dragontoothmg"
 
 	"clanpj/lisao/engine"
+	"clanpj/lisao/frontend"
+	"clanpj/lisao/syzygy"
 )
 
 var VersionString = "0.0mga Pichu 1" + "CPU " + runtime.GOOS + "-" + runtime.GOARCH
 
 func main() {
+	// Allow 'lisao bench [depth]' from the command line for scripted speed regression tracking
+	if len(os.Args) > 1 && strings.ToLower(os.Args[1]) == "bench" {
+		uciBench(os.Args[1:])
+		return
+	}
 	uciLoop()
 }
 
@@ -37,28 +44,9 @@ func uciLoop() {
 		case "uci":
 			fmt.Println("id name Lisao", VersionString)
 			fmt.Println("id author Clan PJ")
-			fmt.Println("option name SearchAlgorithm type combo default", engine.SearchAlgorithmString(), "var NegAlphaBeta")
-			fmt.Println("option name SearchDepth type spin default", engine.SearchDepth, "min 1 max 1024")
-			fmt.Println("option name SearchCutoffPercent type spin default", engine.SearchCutoffPercent, "min 1 max 100")
-			fmt.Println("option name TimeLeftPerMoveDivisor type spin default", TimeLeftPerMoveDivisor, "min 2 max 200")
-			fmt.Println("option name UseEarlyMoveHint type check default", engine.UseEarlyMoveHint)
-			fmt.Println("option name HeurUseNullMove type check default", engine.HeurUseNullMove)
-			fmt.Println("option name UseMoveOrdering type check default", engine.UseMoveOrdering)
-			fmt.Println("option name UseIDMoveHint type check default", engine.UseIDMoveHint)
-			fmt.Println("option name MinIDMoveHintDepth type spin default", engine.MinIDMoveHintDepth, "min 2 max 1024")
-			fmt.Println("option name UseTT type check default", engine.UseTT)
-			fmt.Println("option name HeurUseTTDeeperHits type check default", engine.HeurUseTTDeeperHits)
-			fmt.Println("option name UseKillerMoves type check default", engine.UseKillerMoves)
-			fmt.Println("option name UsePosRepetition type check default", engine.UsePosRepetition)
-			fmt.Println("option name UseDeepKillerMoves type check default", engine.UseDeepKillerMoves)
-			fmt.Println("option name UseQSearch type check default", engine.UseQSearch)
-			fmt.Println("option name QSearchDepth type spin default", engine.QSearchDepth, "min 1 max 1024")
-			fmt.Println("option name UseQSearchTT type check default", engine.UseQSearchTT)
-			fmt.Println("option name UseQSearchMoveOrdering type check default", engine.UseQSearchMoveOrdering)
-			fmt.Println("option name UseQSearchRampagePruning type check default", engine.UseQSearchRampagePruning)
-			fmt.Println("option name QSearchRampagePruningDepth type spin default", engine.QSearchRampagePruningDepth, "min 0 max 1024")
-			fmt.Println("option name UseQKillerMoves type check default", engine.UseQKillerMoves)
-			fmt.Println("option name UseQDeepKillerMoves type check default", engine.UseQDeepKillerMoves)
+			for _, option := range uciOptions.Options() {
+				fmt.Println(option.UciString())
+			}
 			fmt.Println("uciok")
 		case "isready":
 			fmt.Println("readyok")
@@ -67,216 +55,38 @@ func uciLoop() {
 			board = dragon.ParseFen(dragon.Startpos)
 			// reset the history table
 			ht = make(engine.HistoryTableT)
+			// reset the quiet move ordering history
+			lisao.ResetMoveHistory()
 			// reset the TT
-			engine.ResetTT()
+			lisao.ResetTT()
 			// reset the qsearch TT
-			engine.ResetQtt()
+			lisao.ResetQtt()
 
 		case "quit":
 			return
 		case "setoption":
-			if len(tokens) != 5 || tokens[1] != "name" || tokens[3] != "value" {
-				fmt.Println("info string Malformed setoption command")
-				continue
-			}
-			switch strings.ToLower(tokens[2]) {
-			case "searchalgorithm":
-				switch strings.ToLower(tokens[4]) {
-				case "negalphabeta":
-					engine.SearchAlgorithm = engine.NegAlphaBeta
-				default:
-					fmt.Println("info string Unrecognised Search Algorithm:", tokens[4])
-				}
-			case "searchdepth":
-				res, err := strconv.Atoi(tokens[4])
-				if err != nil {
-					fmt.Println("info string SearchDepth value is not an int (", err, ")")
-					continue
-				}
-				engine.SearchDepth = res
-			case "searchcutoffpercent":
-				res, err := strconv.Atoi(tokens[4])
-				if err != nil {
-					fmt.Println("info string SearchCutoffPercent value is not an int (", err, ")")
-					continue
-				}
-				engine.SearchCutoffPercent = res
-			case "timeleftpermovedivisor":
-				res, err := strconv.Atoi(tokens[4])
-				if err != nil {
-					fmt.Println("info string TimeLeftPerMoveDivisor value is not an int (", err, ")")
-					continue
-				}
-				TimeLeftPerMoveDivisor = res
-			case "usemoveordering":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseMoveOrdering = true
-				case "false":
-					engine.UseMoveOrdering = false
-				default:
-					fmt.Println("info string Unrecognised UseMoveOrdering option:", tokens[4])
-				}
-			case "useearlymovehint":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseEarlyMoveHint = true
-				case "false":
-					engine.UseEarlyMoveHint = false
-				default:
-					fmt.Println("info string Unrecognised UseEarlyMoveHint option:", tokens[4])
-				}
-			case "heurusenullmove":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.HeurUseNullMove = true
-				case "false":
-					engine.HeurUseNullMove = false
-				default:
-					fmt.Println("info string Unrecognised HeurUseNullMove option:", tokens[4])
-				}
-			case "useidmovehint":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseIDMoveHint = true
-				case "false":
-					engine.UseIDMoveHint = false
-				default:
-					fmt.Println("info string Unrecognised UseIDMoveHint option:", tokens[4])
-				}
-			case "minidmovehintdepth":
-				res, err := strconv.Atoi(tokens[4])
-				if err != nil {
-					fmt.Println("info string MinIDMoveHintDepth value is not an int (", err, ")")
-					continue
-				}
-				engine.MinIDMoveHintDepth = res
-			case "usett":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseTT = true
-				case "false":
-					engine.UseTT = false
-				default:
-					fmt.Println("info string Unrecognised UseTT option:", tokens[4])
-				}
-			case "heurusettdeeperhits":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.HeurUseTTDeeperHits = true
-				case "false":
-					engine.HeurUseTTDeeperHits = false
-				default:
-					fmt.Println("info string Unrecognised HeurUseTTDeeperHits option:", tokens[4])
-				}
-			case "usekillermoves":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseKillerMoves = true
-				case "false":
-					engine.UseKillerMoves = false
-				default:
-					fmt.Println("info string Unrecognised UseKillerMoves option:", tokens[4])
-				}
-			case "useposrepetition":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UsePosRepetition = true
-				case "false":
-					engine.UsePosRepetition = false
-				default:
-					fmt.Println("info string Unrecognised UsePosRepetition option:", tokens[4])
-				}
-			case "usedeepkillermoves":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseDeepKillerMoves = true
-				case "false":
-					engine.UseDeepKillerMoves = false
-				default:
-					fmt.Println("info string Unrecognised UseDeepKillerMoves option:", tokens[4])
-				}
-			case "useqsearch":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseQSearch = true
-				case "false":
-					engine.UseQSearch = false
-				default:
-					fmt.Println("info string Unrecognised UseQSearch option:", tokens[4])
-				}
-			case "qsearchdepth":
-				res, err := strconv.Atoi(tokens[4])
-				if err != nil {
-					fmt.Println("info string QSearchDepth value is not an int (", err, ")")
-					continue
-				}
-				engine.QSearchDepth = res
-			case "useqsearchtt":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseQSearchTT = true
-				case "false":
-					engine.UseQSearchTT = false
-				default:
-					fmt.Println("info string Unrecognised UseQSearchTT option:", tokens[4])
-				}
-			case "useqkillermoves":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseQKillerMoves = true
-				case "false":
-					engine.UseQKillerMoves = false
-				default:
-					fmt.Println("info string Unrecognised UseQKillerMoves option:", tokens[4])
-				}
-			case "useqdeepkillermoves":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseQDeepKillerMoves = true
-				case "false":
-					engine.UseQDeepKillerMoves = false
-				default:
-					fmt.Println("info string Unrecognised UseDeepKillerMoves option:", tokens[4])
-				}
-			case "useqsearchmoveordering":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseQSearchMoveOrdering = true
-				case "false":
-					engine.UseQSearchMoveOrdering = false
-				default:
-					fmt.Println("info string Unrecognised UseQSearchMoveOrdering option:", tokens[4])
-				}
-			case "useqsearchrampagepruning":
-				switch strings.ToLower(tokens[4]) {
-				case "true":
-					engine.UseQSearchRampagePruning = true
-				case "false":
-					engine.UseQSearchRampagePruning = false
-				default:
-					fmt.Println("info string Unrecognised UseQSearchRampagePruning option:", tokens[4])
-				}
-			case "qsearchrampagepruningdepth":
-				res, err := strconv.Atoi(tokens[4])
-				if err != nil {
-					fmt.Println("info string QSearchRampagePruningDepth value is not an int (", err, ")")
-					continue
-				}
-				engine.QSearchRampagePruningDepth = res
-			default:
-				fmt.Println("info string Unknown UCI option", tokens[2])
+			if err := uciOptions.SetUci(tokens[1:]); err != nil {
+				fmt.Println("info string", err)
 			}
 		case "go":
 			goScanner := bufio.NewScanner(strings.NewReader(line))
 			goScanner.Split(bufio.ScanWords)
 			goScanner.Scan() // skip the first token
-			var movetime, wtime, btime, winc, binc int
+			var movetime, wtime, btime, winc, binc, movestogo int
 			var infinite bool
 			var depth int // if 0 then we're searching on time
+			var limits engine.SearchLimitsT
+			var isSearchMoves bool // searchmoves is followed by a list of moves
 			var err error
 			for goScanner.Scan() {
 				nextToken := strings.ToLower(goScanner.Text())
+				if isSearchMoves {
+					if move, err := dragon.ParseMove(nextToken); err == nil {
+						limits.SearchMoves = append(limits.SearchMoves, move)
+						continue
+					}
+					isSearchMoves = false
+				}
 				switch nextToken {
 				case "infinite":
 					infinite = true
@@ -341,6 +151,38 @@ func uciLoop() {
 						fmt.Println("info string Malformed go command option; could not convert depth")
 						continue
 					}
+				case "nodes":
+					if !goScanner.Scan() {
+						fmt.Println("info string Malformed go command option nodes")
+						continue
+					}
+					limits.Nodes, err = strconv.ParseUint(goScanner.Text(), 10, 64)
+					if err != nil {
+						fmt.Println("info string Malformed go command option; could not convert nodes")
+						continue
+					}
+				case "mate":
+					if !goScanner.Scan() {
+						fmt.Println("info string Malformed go command option mate")
+						continue
+					}
+					limits.Mate, err = strconv.Atoi(goScanner.Text())
+					if err != nil {
+						fmt.Println("info string Malformed go command option; could not convert mate")
+						continue
+					}
+				case "movestogo":
+					if !goScanner.Scan() {
+						fmt.Println("info string Malformed go command option movestogo")
+						continue
+					}
+					movestogo, err = strconv.Atoi(goScanner.Text())
+					if err != nil {
+						fmt.Println("info string Malformed go command option; could not convert movestogo")
+						continue
+					}
+				case "searchmoves":
+					isSearchMoves = true
 				default:
 					fmt.Println("info string Unknown go subcommand", nextToken)
 					continue
@@ -357,13 +199,28 @@ func uciLoop() {
 					} else {
 						ourtime, opptime, ourinc, oppinc = btime, wtime, binc, winc
 					}
-					timeoutMs = uciCalculateAllowedTimeMs(&board, ourtime, opptime, ourinc, oppinc)
+					timeoutMs = uciCalculateAllowedTimeMs(&board, ourtime, opptime, ourinc, oppinc, movestogo)
 				}
 			}
+			// The game may already be over - it's up to the GUI to adjudicate but we still have to reply with a bestmove
+			if engine.IsDrawByRule(&board) || ht[board.Hash()] >= 3 {
+				fmt.Println("info string position is already drawn by rule")
+			}
+			// Play straight from the opening book if we can - but not if we've been asked to search something specific or to
+			// a fixed depth or node count, e.g. for testing
+			if !infinite && depth == 0 && limits.Nodes == 0 && limits.Mate == 0 && len(limits.SearchMoves) == 0 {
+				if bookMove, ok := frontEnd.BookMove(&board); ok {
+					fmt.Println("info string book move", &bookMove)
+					fmt.Println("bestmove", &bookMove)
+					continue
+				}
+			}
+			// The search gets its own copy of the options so that a setoption during the search doesn't race with it
+			searchOptions := *options
 			// Start the timeout timer...
 			uciStartTimer(timeoutMs)
 			// Run the search in another thread.
-			go uciSearch(&board, depth, timeoutMs)
+			go uciSearch(&board, &searchOptions, depth, timeoutMs, limits)
 		// case "secretparam": // secret parameters used for optimizing the evaluation function
 		// 	res, _ := strconv.Atoi(tokens[2])
 		// 	switch tokens[1] {
@@ -407,6 +264,8 @@ func uciLoop() {
 		// 	}
 		case "stop":
 			uciStop()
+		case "bench": // non-standard - fixed depth search of the bench positions
+			uciBench(tokens)
 		case "position":
 			posScanner := bufio.NewScanner(strings.NewReader(line))
 			posScanner.Split(bufio.ScanWords)
@@ -473,6 +332,48 @@ func perC(n uint64, N uint64) string {
 	return fmt.Sprintf("%d [%.2f%%]", n, float64(n)/float64(N)*100)
 }
 
+// Search options - set by setoption and copied into each search request
+var options = engine.NewOptions()
+
+// The engine hash tables and eval weights - kept for the whole session
+var lisao = engine.NewEngine(options)
+
+// The opening book, tablebases and eval weights
+var frontEnd = newFrontEnd()
+
+func newFrontEnd() *frontend.FrontEndT {
+	f := frontend.NewFrontEnd()
+	f.Info = func(a ...interface{}) { fmt.Println(append([]interface{}{"info string"}, a...)...) }
+	f.OnEvalParams = func(p *engine.EvalParamsT) {
+		lisao.SetEvalParams(p)
+		// TT evals are stale
+		lisao.ResetTT()
+		lisao.ResetQtt()
+	}
+	return f
+}
+
+// All the UCI options - the search options plus our own
+var uciOptions = newUciOptions()
+
+func newUciOptions() *engine.OptionRegistryT {
+	r := engine.NewOptionRegistry(options)
+	r.Add(engine.SpinOption("TimeLeftPerMoveDivisor", TimeLeftPerMoveDivisor, 2, 200, func(value int) { TimeLeftPerMoveDivisor = value }))
+	frontEnd.AddOptions(r)
+	// A file of Name=Value lines for any of these options
+	r.Add(engine.StringOption("ConfigFile", "", func(path string) error {
+		if path == "" {
+			return nil
+		}
+		return r.LoadFile(path)
+	}))
+	r.Add(engine.ButtonOption("Clear Hash", func() {
+		lisao.ResetTT()
+		lisao.ResetQtt()
+	}))
+	return r
+}
+
 // This MUST be per-search-thread but for now we're single-threaded so global is fine.
 var ht engine.HistoryTableT = make(engine.HistoryTableT)
 
@@ -487,7 +388,7 @@ var timeoutTimer *time.Timer
 
 // Lightweight wrapper around Lisao Search.
 // Prints the results (bestmove) and various stats.
-func uciSearch(board *dragon.Board, depth int, timeoutMs int) {
+func uciSearch(board *dragon.Board, searchOptions *engine.OptionsT, depth int, timeoutMs int, limits engine.SearchLimitsT) {
 	// Reset the timeout
 	atomic.StoreUint32(&timeout, 0)
 
@@ -495,31 +396,44 @@ func uciSearch(board *dragon.Board, depth int, timeoutMs int) {
 	start := time.Now()
 
 	// Search for the winning move!
-	bestMove, eval, stats, finalDepth, _ := engine.Search(board, ht, depth, timeoutMs, &timeout)
+	result, err := engine.Search(engine.SearchRequestT{
+		Options:      searchOptions,
+		Engine:       lisao,
+		Board:        board,
+		History:      ht,
+		Depth:        depth,
+		TargetTimeMs: timeoutMs,
+		Limits:       limits,
+		Info:         uciInfo,
+		Timeout:      &timeout,
+	})
+	bestMove, stats, finalDepth := result.BestMove, result.Stats, result.Depth
 
 	elapsedSecs := time.Since(start).Seconds()
 
 	// Stop the timer in case this was an early-out return
 	uciStop()
 
-	// Eval is expected from the engine's perspective, but we generate it from white's perspective
-	if !board.Wtomove {
-		eval = -eval
+	// The GUI is still waiting for a bestmove so we send the UCI null move
+	if err != nil {
+		fmt.Println("info string Search failed (", err, ")")
+		fmt.Println("bestmove 0000")
+		return
 	}
 
 	// Reverse order from which it appears in the UCI driver
-	fmt.Println("info string   q-mates:", perC(stats.QMates, stats.QNonLeafs), "q-pat-cuts:", perC(stats.QPatCuts, stats.QNonLeafs), "q-rampage-prunes:", perC(stats.QRampagePrunes, stats.QNonLeafs), "q-killers:", perC(stats.QKillers, stats.QNonLeafs), "q-killer-cuts:", perC(stats.QKillerCuts, stats.QNonLeafs), "q-deep-killers:", perC(stats.QDeepKillers, stats.QNonLeafs), "q-deep-killer-cuts:", perC(stats.QDeepKillerCuts, stats.QNonLeafs))
-	if engine.UseQSearchTT {
+	fmt.Println("info string   q-mates:", perC(stats.QMates, stats.QNonLeafs), "q-pat-cuts:", perC(stats.QPatCuts, stats.QNonLeafs), "q-rampage-prunes:", perC(stats.QRampagePrunes, stats.QNonLeafs), "q-see-prunes:", stats.QSEEPrunes, "q-checks:", stats.QChecks, "q-killers:", perC(stats.QKillers, stats.QNonLeafs), "q-killer-cuts:", perC(stats.QKillerCuts, stats.QNonLeafs), "q-deep-killers:", perC(stats.QDeepKillers, stats.QNonLeafs), "q-deep-killer-cuts:", perC(stats.QDeepKillerCuts, stats.QNonLeafs))
+	if searchOptions.UseQSearchTT {
 		fmt.Println("info string   qtt-hits:", perC(stats.QttHits, stats.QNonLeafs), "qtt-depth-hits:", perC(stats.QttDepthHits, stats.QNonLeafs), "qtt-beta-cuts:", perC(stats.QttBetaCuts, stats.QNonLeafs), "qtt-alpha-cuts:", perC(stats.QttAlphaCuts, stats.QNonLeafs), "qtt-late-cuts:", perC(stats.QttLateCuts, stats.QNonLeafs), "qtt-true-evals:", perC(stats.QttTrueEvals, stats.QNonLeafs))
 	}
 	fmt.Print("info string    q-non-leafs by depth:")
-	for i := 0; i < engine.MaxQDepthStats && i < engine.QSearchDepth; i++ {
+	for i := 0; i < engine.MaxQDepthStats && i < searchOptions.QSearchDepth; i++ {
 		fmt.Printf(" %d: %s", i, perC(stats.QNonLeafsAt[i], stats.QNonLeafs))
 	}
 	fmt.Println()
 	fmt.Println("info string q-nodes:", stats.QNodes, "q-non-leafs:", stats.QNonLeafs, "q-all-nodes:", perC(stats.QAllChildrenNodes, stats.QNonLeafs), "q-1st-child-cuts:", perC(stats.QFirstChildCuts, stats.QNonLeafs), "q-pats:", perC(stats.QPats, stats.QNonLeafs), "q-quiesced:", perC(stats.QQuiesced, stats.QNonLeafs), "q-prunes:", perC(stats.QPrunes, stats.QNonLeafs))
-	fmt.Println("info string   null-cuts:", perC(stats.NullMoveCuts, stats.NonLeafs), "valid-hint-moves:", perC(stats.ValidHintMoves, stats.NonLeafs), "hint-move-cuts:", perC(stats.HintMoveCuts, stats.NonLeafs), "mates:", perC(stats.Mates, stats.NonLeafs), "killers:", perC(stats.Killers, stats.NonLeafs), "killer-cuts:", perC(stats.KillerCuts, stats.NonLeafs), "deep-killers:", perC(stats.DeepKillers, stats.NonLeafs), "deep-killer-cuts:", perC(stats.DeepKillerCuts, stats.NonLeafs))
-	if engine.UseTT {
+	fmt.Println("info string   null-cuts:", perC(stats.NullMoveCuts, stats.NonLeafs), "valid-hint-moves:", perC(stats.ValidHintMoves, stats.NonLeafs), "hint-move-cuts:", perC(stats.HintMoveCuts, stats.NonLeafs), "mates:", perC(stats.Mates, stats.NonLeafs), "killers:", perC(stats.Killers, stats.NonLeafs), "killer-cuts:", perC(stats.KillerCuts, stats.NonLeafs), "deep-killers:", perC(stats.DeepKillers, stats.NonLeafs), "deep-killer-cuts:", perC(stats.DeepKillerCuts, stats.NonLeafs), "counter-move-cuts:", perC(stats.CounterMoveCuts, stats.NonLeafs))
+	if searchOptions.UseTT {
 		fmt.Println("info string   tt-hits:", perC(stats.TTHits, stats.NonLeafs), "tt-depth-hits:", perC(stats.TTDepthHits, stats.NonLeafs), "tt-deeper-hits:", perC(stats.TTDeeperHits, stats.NonLeafs), "tt-beta-cuts:", perC(stats.TTBetaCuts, stats.NonLeafs), "tt-alpha-cuts:", perC(stats.TTAlphaCuts, stats.NonLeafs), "tt-late-cuts:", perC(stats.TTLateCuts, stats.NonLeafs), "tt-true-evals:", perC(stats.TTTrueEvals, stats.NonLeafs))
 	}
 	fmt.Print("info string    1st-child-cuts by depth:")
@@ -532,12 +446,93 @@ func uciSearch(board *dragon.Board, depth int, timeoutMs int) {
 		fmt.Printf(" %d: %s", i, perC(stats.NonLeafsAt[i], stats.NonLeafs))
 	}
 	fmt.Println()
-	fmt.Println("info string nodes:", stats.Nodes, "non-leafs:", stats.NonLeafs, "all-nodes:", perC(stats.AllChildrenNodes, stats.NonLeafs), "1st-child-cuts:", perC(stats.FirstChildCuts, stats.NonLeafs), "pos-repetitions:", perC(stats.PosRepetitions, stats.Nodes))
-	// TODO proper checkmate score string
-	fmt.Println("info depth", finalDepth, "score cp", eval, "nodes", stats.Nodes, "time", uint64(elapsedSecs*1000), "nps", uint64(float64(stats.Nodes)/elapsedSecs), "pv", &bestMove)
+	if searchOptions.UseAspirationWindows {
+		fmt.Println("info string   aspiration-lows:", stats.AspirationLows, "aspiration-highs:", stats.AspirationHighs)
+	}
+	if searchOptions.UsePVS || searchOptions.UseLateMoveReductions {
+		fmt.Println("info string   lmr-reductions:", perC(stats.LMRReductions, stats.NonLeafs), "lmr-re-searches:", perC(stats.LMRReSearches, stats.LMRReductions), "pvs-re-searches:", perC(stats.PVSReSearches, stats.NonLeafs))
+	}
+	fmt.Println("info string   check-exts:", perC(stats.CheckExts, stats.NonLeafs), "one-reply-exts:", perC(stats.OneReplyExts, stats.NonLeafs), "pawn-push-exts:", perC(stats.PawnPushExts, stats.NonLeafs), "singular-exts:", stats.SingularExts, "/", stats.SingularSearches, "extension-cap-hits:", stats.ExtensionCapHits)
+	if searchOptions.UseReverseFutilityPruning || searchOptions.UseRazoring || searchOptions.UseFutilityPruning {
+		fmt.Println("info string   rev-futility-cuts:", perC(stats.RevFutilityCuts, stats.NonLeafs), "razoring-cuts:", perC(stats.RazoringCuts, stats.NonLeafs), "futility-prunes:", stats.FutilityPrunes)
+	}
+	if searchOptions.UsePawnHash {
+		fmt.Println("info string   pawn-hash-hits:", perC(stats.PawnHashHits, stats.PawnHashProbes))
+	}
+	if searchOptions.UseSyzygy && syzygy.MaxPieces() > 0 {
+		fmt.Println("info string   tb-hits:", perC(stats.TBHits, stats.NonLeafs))
+	}
+	fmt.Println("info string nodes:", stats.Nodes, "non-leafs:", stats.NonLeafs, "all-nodes:", perC(stats.AllChildrenNodes, stats.NonLeafs), "1st-child-cuts:", perC(stats.FirstChildCuts, stats.NonLeafs), "pos-repetitions:", perC(stats.PosRepetitions, stats.Nodes), "fifty-move-draws:", stats.FiftyMoveDraws, "material-draws:", stats.MaterialDraws)
+	pv := result.PV
+	if len(pv) == 0 {
+		pv = []dragon.Move{bestMove}
+	}
+	fmt.Println("info depth", finalDepth, "seldepth", result.SelDepth, uciScore(result.ScoreType, result.Score), "nodes", stats.Nodes, "tbhits", stats.TBHits, "time", uint64(elapsedSecs*1000), "nps", uint64(float64(stats.Nodes)/elapsedSecs), "hashfull", lisao.TTHashFull(), "pv", uciMoves(pv))
 
 	// Print the result
-	fmt.Println("bestmove", &bestMove)
+	if result.PonderMove != engine.NoMove {
+		fmt.Println("bestmove", &bestMove, "ponder", &result.PonderMove)
+	} else {
+		fmt.Println("bestmove", &bestMove)
+	}
+}
+
+// Run the engine bench and print the total nodes (the bench signature), time and NPS.
+// Optional param is the search depth.
+// Note that this resets the TT and QTT.
+func uciBench(tokens []string) {
+	depth := engine.BenchDepth
+	if len(tokens) > 1 {
+		res, err := strconv.Atoi(tokens[1])
+		if err != nil || res < 1 {
+			fmt.Println("info string bench depth is not a positive int (", tokens[1], ")")
+			return
+		}
+		depth = res
+	}
+
+	res, err := engine.Bench(lisao, depth)
+	if err != nil {
+		fmt.Println("info string bench failed:", err)
+		return
+	}
+
+	fmt.Println("info string bench depth", depth, "positions", len(engine.BenchFens))
+	fmt.Println("info string bench nodes", res.Nodes, "time", res.ElapsedMs, "nps", res.Nps)
+}
+
+// Print search info as UCI info lines
+func uciInfo(info engine.SearchInfoT) {
+	switch info.Kind {
+	case engine.InfoString:
+		fmt.Println("info string", info.Text)
+	case engine.InfoDepth:
+		fmt.Println("info depth", info.Depth, "seldepth", info.SelDepth, uciScore(info.ScoreType, info.Score), "nodes", info.Nodes, "time", info.ElapsedMs, "nps", info.Nps, "hashfull", info.HashFull, "pv", uciMoves(info.PV))
+	case engine.InfoProgress:
+		// currmove is only known once the root move loop has started
+		if info.CurrMove == engine.NoMove {
+			fmt.Println("info depth", info.Depth, "seldepth", info.SelDepth, "nodes", info.Nodes, "nps", info.Nps, "time", info.ElapsedMs, "hashfull", info.HashFull)
+		} else {
+			fmt.Println("info depth", info.Depth, "seldepth", info.SelDepth, "currmove", &info.CurrMove, "currmovenumber", info.CurrMoveNumber, "nodes", info.Nodes, "nps", info.Nps, "time", info.ElapsedMs, "hashfull", info.HashFull)
+		}
+	}
+}
+
+// UCI score - in centipawns or moves to mate
+func uciScore(scoreType engine.ScoreTypeT, score int) string {
+	if scoreType == engine.ScoreMate {
+		return "score mate " + strconv.Itoa(score)
+	}
+	return "score cp " + strconv.Itoa(score)
+}
+
+// Moves in UCI format separated by spaces
+func uciMoves(moves []dragon.Move) string {
+	moveStrs := make([]string, len(moves))
+	for i := range moves {
+		moveStrs[i] = moves[i].String()
+	}
+	return strings.Join(moveStrs, " ")
 }
 
 // Start the search timeout timer
@@ -565,9 +560,15 @@ func uciStop() {
 // 1/16th of the time left per move seems aggressive, but we bail early most of the time due to SearchCutoffPercent
 var TimeLeftPerMoveDivisor = 16
 
-// Simple strategy - use fixed percentage of the remaining time
-func uciCalculateAllowedTimeMs(b *dragon.Board, ourtimeMs int, opptimeMs int, ourincMs int, oppincMs int) int {
-	result := ourtimeMs / TimeLeftPerMoveDivisor
+// Simple strategy - use fixed percentage of the remaining time, or more if the time control ends within fewer moves
+// (movesToGo is 0 if there is no next time control)
+func uciCalculateAllowedTimeMs(b *dragon.Board, ourtimeMs int, opptimeMs int, ourincMs int, oppincMs int, movesToGo int) int {
+	divisor := TimeLeftPerMoveDivisor
+	if movesToGo > 0 && movesToGo+1 < divisor {
+		// Keep some time in hand in case the search overruns
+		divisor = movesToGo + 1
+	}
+	result := ourtimeMs / divisor
 	if result <= 0 {
 		return ourincMs
 	}
@@ -0,0 +1,109 @@
+// Table values are compressed with a canonical Huffman code over Recursive Pairing symbols.
+// The compressed data is divided into fixed-size blocks, each holding a variable number of symbols. Each symbol is either a
+// value or a pair of other symbols, so each block expands to up to 65536 values. The Huffman code is the same for all blocks.
+
+package syzygy
+
+import (
+	"encoding/binary"
+)
+
+func (d *pairsDataT) lowestSym(data []byte, l int) uint16 {
+	return binary.LittleEndian.Uint16(data[d.lowestSymOff+2*l:])
+}
+
+// Symbol tree entries are 3 bytes - the first 12 bits is the left symbol and the second 12 bits the right symbol.
+// A leaf has right symbol 0xFFF and its value in the left symbol.
+func (d *pairsDataT) left(data []byte, sym int) int {
+	lr := data[d.btreeOff+3*sym:]
+	return int(lr[1]&0xF)<<8 | int(lr[0])
+}
+
+func (d *pairsDataT) right(data []byte, sym int) int {
+	lr := data[d.btreeOff+3*sym:]
+	return int(lr[2])<<4 | int(lr[1]>>4)
+}
+
+func (d *pairsDataT) blockLength(data []byte, block int) int {
+	return int(binary.LittleEndian.Uint16(data[d.blockLengthOff+2*block:]))
+}
+
+// Big-endian read that tolerates running off the end of the data at the end of the last block
+func readBe32(data []byte, off int) uint32 {
+	var buf [4]byte
+	if off < len(data) {
+		copy(buf[:], data[off:])
+	}
+	return binary.BigEndian.Uint32(buf[:])
+}
+
+// Return the (raw) value stored at the index
+func (d *pairsDataT) decompress(data []byte, idx uint64) int {
+	// Every position has the same value
+	if d.flags&singleValueFlag != 0 {
+		return d.minSymLen
+	}
+
+	// Find the block containing the index. Each block holds blockLength[block] + 1 values.
+	// The sparse index entry k holds the block and offset within the block of the value with index k*span + span/2.
+	k := idx / d.span
+	sparseEntry := data[d.sparseIndexOff+6*int(k):]
+	block := int(binary.LittleEndian.Uint32(sparseEntry[0:4]))
+	offset := int(binary.LittleEndian.Uint16(sparseEntry[4:6]))
+
+	offset += int(idx%d.span) - int(d.span/2)
+
+	for offset < 0 {
+		block--
+		offset += d.blockLength(data, block) + 1
+	}
+	for offset > d.blockLength(data, block) {
+		offset -= d.blockLength(data, block) + 1
+		block++
+	}
+
+	// The block is a sequence of Huffman symbols - find the symbol that contains our offset
+	ptr := d.dataOff + block*int(d.sizeofBlock)
+	buf64 := uint64(readBe32(data, ptr))<<32 | uint64(readBe32(data, ptr+4))
+	ptr += 8
+	buf64Size := 64
+
+	var sym uint16
+	for {
+		// Symbols of a given length are consecutive, and base64[] gives the (padded) lowest symbol of each length
+		l := 0
+		for buf64 < d.base64[l] {
+			l++
+		}
+		sym = uint16((buf64 - d.base64[l]) >> uint(64-l-d.minSymLen))
+		sym += d.lowestSym(data, l)
+
+		if offset < int(d.symlen[sym])+1 {
+			break
+		}
+
+		// Consume the symbol and refill the buffer if necessary
+		offset -= int(d.symlen[sym]) + 1
+		l += d.minSymLen
+		buf64 <<= uint(l)
+		buf64Size -= l
+		if buf64Size <= 32 {
+			buf64Size += 32
+			buf64 |= uint64(readBe32(data, ptr)) << uint(64-buf64Size)
+			ptr += 4
+		}
+	}
+
+	// Expand the symbol through its children (which are adjacent) until we reach the leaf holding our value
+	for d.symlen[sym] != 0 {
+		left := d.left(data, int(sym))
+		if offset < int(d.symlen[left])+1 {
+			sym = uint16(left)
+		} else {
+			offset -= int(d.symlen[left]) + 1
+			sym = uint16(d.right(data, int(sym)))
+		}
+	}
+
+	return d.left(data, int(sym))
+}
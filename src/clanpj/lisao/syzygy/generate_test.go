@@ -0,0 +1,725 @@
+package syzygy
+
+// Generator for the testdata tables - a retrograde solver for the 3-piece endgames, and a writer for the Syzygy file
+// format including the Re-Pair and Huffman compression that decompress.go undoes.
+// This is a round-trip test of the file format - TestOfficialTables checks the prober against the official tables.
+// Regenerate the testdata tables with:
+//
+//	go test -run TestGenerateTables -generate
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/bits"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+var generateFlag = flag.Bool("generate", false, "Regenerate the 3-piece tables in testdata")
+
+// Table piece codes
+const (
+	whitePawn = 1
+	whiteKing = 6
+	blackKing = 14
+)
+
+// Positions are indexed by side to move, white king, white piece and black king squares
+const nPos = 2 * 64 * 64 * 64
+
+func posIndex(blackToMove bool, wk int, x int, bk int) int {
+	return ((boolToInt(blackToMove)*64+wk)*64+x)*64 + bk
+}
+
+// A solved K+X v K endgame
+type solvedT struct {
+	name  string
+	piece dragon.Piece
+	valid []bool
+	wdl   []WdlT // from the side to move's point of view
+	dtz   []int  // plies to a zeroing move or mate for wins, and to the opponent's for losses - 1 if mated
+}
+
+func solverFen(piece dragon.Piece, blackToMove bool, wk int, x int, bk int) string {
+	var squares [64]byte
+	squares[wk], squares[x], squares[bk] = 'K', pieceLetters[piece], 'k'
+	var fen []byte
+	for rank := 7; rank >= 0; rank-- {
+		empty := byte(0)
+		for file := 0; file < 8; file++ {
+			if c := squares[8*rank+file]; c == 0 {
+				empty++
+			} else {
+				if empty > 0 {
+					fen = append(fen, '0'+empty)
+					empty = 0
+				}
+				fen = append(fen, c)
+			}
+		}
+		if empty > 0 {
+			fen = append(fen, '0'+empty)
+		}
+		if rank > 0 {
+			fen = append(fen, '/')
+		}
+	}
+	if blackToMove {
+		return string(fen) + " b - - 0 1"
+	}
+	return string(fen) + " w - - 0 1"
+}
+
+// Children outside the table are stored as their WDL - this maps -2..2 to -1..-5
+func terminalChild(wdl WdlT) int32 { return -3 - int32(wdl) }
+func terminalWdl(child int32) WdlT { return WdlT(-3 - child) }
+
+// Solve the endgame - promotions are looked up in the promoted tables, or else are draws
+func solve(name string, piece dragon.Piece, promoted map[dragon.Piece]*solvedT) (*solvedT, error) {
+	s := &solvedT{name: name, piece: piece, valid: make([]bool, nPos), wdl: make([]WdlT, nPos), dtz: make([]int, nPos)}
+
+	// The moves of each position in a flat list
+	moveStart := make([]int32, nPos+1)
+	var children []int32
+	var zeroing []bool
+	isMated := make([]bool, nPos)
+
+	for p := 0; p < nPos; p++ {
+		moveStart[p] = int32(len(children))
+		blackToMove, wk, x, bk := p>>18 == 1, p>>12&63, p>>6&63, p&63
+		if wk == x || x == bk || isKingAdjacentOrSame(wk, bk) || (piece == dragon.Pawn && (rankOf(x) == 0 || rankOf(x) == 7)) {
+			continue
+		}
+		board := dragon.ParseFen(solverFen(piece, blackToMove, wk, x, bk))
+		// The side not to move can't be in check
+		notToMoveKing := bk
+		if blackToMove {
+			notToMoveKing = wk
+		}
+		if board.UnderDirectAttack(blackToMove, uint8(notToMoveKing)) {
+			continue
+		}
+		s.valid[p] = true
+
+		moves := board.GenerateLegalMoves()
+		isMated[p] = len(moves) == 0 && board.OurKingInCheck()
+		for _, move := range moves {
+			isZeroing := dragon.IsCapture(move, &board) || board.PieceAt(move.From()) == dragon.Pawn
+			unapply := board.Apply(move)
+			children = append(children, s.child(&board, promoted))
+			zeroing = append(zeroing, isZeroing)
+			unapply()
+		}
+	}
+	moveStart[nPos] = int32(len(children))
+
+	childWdl := func(child int32) WdlT {
+		if child < 0 {
+			return terminalWdl(child)
+		}
+		return s.wdl[child]
+	}
+
+	// WDL - a position is won if there's a move to a lost position, and lost if all moves are to won positions
+	const unknown = WdlT(99)
+	for p := 0; p < nPos; p++ {
+		s.wdl[p] = unknown
+		if !s.valid[p] {
+			s.wdl[p] = Draw
+		} else if moveStart[p] == moveStart[p+1] {
+			s.wdl[p] = Draw
+			if isMated[p] {
+				s.wdl[p] = Loss
+			}
+		}
+	}
+	for isChanged := true; isChanged; {
+		isChanged = false
+		for p := 0; p < nPos; p++ {
+			if s.wdl[p] != unknown {
+				continue
+			}
+			isLost := true
+			for i := moveStart[p]; i < moveStart[p+1]; i++ {
+				wdl := childWdl(children[i])
+				if wdl == Loss {
+					s.wdl[p] = Win
+					break
+				}
+				if wdl != Win {
+					isLost = false
+				}
+			}
+			if s.wdl[p] == unknown && isLost {
+				s.wdl[p] = Loss
+			}
+			isChanged = isChanged || s.wdl[p] != unknown
+		}
+	}
+	for p := range s.wdl {
+		if s.wdl[p] == unknown {
+			s.wdl[p] = Draw
+		}
+	}
+
+	// DTZ - resolve wins and losses in order of increasing distance, so that at step n a win has a move to a loss in n-1,
+	// and a loss has all its moves resolved to wins in at most n-1
+	for n := 1; ; n++ {
+		nUnresolved := 0
+		for p := 0; p < nPos; p++ {
+			if s.dtz[p] != 0 || (s.wdl[p] != Win && s.wdl[p] != Loss) {
+				continue
+			}
+			if s.wdl[p] == Win {
+				for i := moveStart[p]; i < moveStart[p+1]; i++ {
+					child := children[i]
+					if zeroing[i] || (child >= 0 && isMated[child]) {
+						if n == 1 && childWdl(child) == Loss {
+							s.dtz[p] = 1
+							break
+						}
+					} else if n > 1 && s.wdl[child] == Loss && s.dtz[child] == n-1 {
+						s.dtz[p] = n
+						break
+					}
+				}
+			} else {
+				maxDtz := 1
+				for i := moveStart[p]; i < moveStart[p+1]; i++ {
+					child := children[i]
+					if zeroing[i] {
+						continue
+					}
+					// Wins resolved earlier in this step don't count yet
+					if s.dtz[child] == 0 || s.dtz[child] >= n {
+						maxDtz = 0
+						break
+					}
+					if s.dtz[child]+1 > maxDtz {
+						maxDtz = s.dtz[child] + 1
+					}
+				}
+				if maxDtz != 0 && maxDtz != n {
+					return nil, fmt.Errorf("%s: loss resolved with DTZ %d at step %d", name, maxDtz, n)
+				}
+				s.dtz[p] = maxDtz
+			}
+			if s.dtz[p] == 0 {
+				nUnresolved++
+			}
+		}
+		if nUnresolved == 0 {
+			break
+		}
+		// Wins with DTZ > 100 are cursed wins, which these tables don't have
+		if n > 100 {
+			return nil, fmt.Errorf("%s: %d positions unresolved after %d plies", name, nUnresolved, n)
+		}
+	}
+
+	return s, nil
+}
+
+// The position after a move, or its WDL if it's outside the table
+func (s *solvedT) child(board *dragon.Board, promoted map[dragon.Piece]*solvedT) int32 {
+	others := board.White.All &^ board.White.Kings
+	if others == 0 {
+		// The piece was captured - KvK
+		return terminalChild(Draw)
+	}
+	wk, x, bk := bits.TrailingZeros64(board.White.Kings), bits.TrailingZeros64(others), bits.TrailingZeros64(board.Black.Kings)
+	p := posIndex(!board.Wtomove, wk, x, bk)
+	piece := board.PieceAt(uint8(x))
+	if piece == s.piece {
+		return int32(p)
+	}
+	if t, ok := promoted[piece]; ok {
+		return terminalChild(t.wdl[p])
+	}
+	// Not solved, i.e. drawn
+	return terminalChild(Draw)
+}
+
+// The values for one side to move and leading pawn file, in table index order
+func (s *solvedT) tableValues(t *tableT, d *pairsDataT, blackToMove bool, file int, value func(p int) uint8) ([]uint8, error) {
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	values := make([]uint8, d.groupIdx[n])
+	isSet := make([]bool, len(values))
+
+	for wk := 0; wk < 64; wk++ {
+		for x := 0; x < 64; x++ {
+			for bk := 0; bk < 64; bk++ {
+				p := posIndex(blackToMove, wk, x, bk)
+				if !s.valid[p] {
+					continue
+				}
+				var idx uint64
+				if t.hasPawns {
+					if f := fileOf(x); f != file && 7-f != file {
+						continue
+					}
+					idx = t.encode(d, []int{x, wk, bk}, []int{whitePawn, whiteKing, blackKing}, 1)
+				} else {
+					idx = t.encode(d, []int{wk, x, bk}, []int{whiteKing, int(s.piece), blackKing}, 0)
+				}
+				v := value(p)
+				if isSet[idx] && values[idx] != v {
+					return nil, fmt.Errorf("%s: index %d has values %d and %d", s.name, idx, values[idx], v)
+				}
+				values[idx], isSet[idx] = v, true
+			}
+		}
+	}
+
+	// Indexes that aren't legal positions are never probed, so give them the most common value which compresses best
+	var counts [256]int
+	for idx, v := range values {
+		if isSet[idx] {
+			counts[v]++
+		}
+	}
+	common := 0
+	for v := range counts {
+		if counts[v] > counts[common] {
+			common = v
+		}
+	}
+	for idx := range values {
+		if !isSet[idx] {
+			values[idx] = uint8(common)
+		}
+	}
+	return values, nil
+}
+
+// Write the WDL and DTZ tables to dir
+func (s *solvedT) writeTables(dir string) error {
+	t, err := newTable(s.name)
+	if err != nil {
+		return err
+	}
+	pieces := []int{whiteKing, int(s.piece), blackKing}
+	maxFile := 0
+	if t.hasPawns {
+		pieces = []int{whitePawn, whiteKing, blackKing}
+		maxFile = 3
+	}
+
+	wdlValue := func(p int) uint8 { return uint8(s.wdl[p] + 2) }
+	// Stored in plies, without the one that's added back
+	dtzValue := func(p int) uint8 {
+		if s.dtz[p] == 0 {
+			return 0
+		}
+		return uint8(s.dtz[p] - 1)
+	}
+
+	var wdlSubs, dtzSubs [][]encodedT
+	for file := 0; file <= maxFile; file++ {
+		d := &pairsDataT{}
+		copy(d.pieces[:], pieces)
+		t.setGroups(d, [2]int{0, 0xF}, file)
+
+		var wdlSides []encodedT
+		for _, blackToMove := range []bool{false, true} {
+			values, err := s.tableValues(t, d, blackToMove, file, wdlValue)
+			if err != nil {
+				return err
+			}
+			e, err := compress(values, 0)
+			if err != nil {
+				return err
+			}
+			wdlSides = append(wdlSides, e)
+		}
+		wdlSubs = append(wdlSubs, wdlSides)
+
+		// DTZ is stored for white to move only, since black never wins
+		values, err := s.tableValues(t, d, false, file, dtzValue)
+		if err != nil {
+			return err
+		}
+		e, err := compress(values, winPliesFlag|lossPliesFlag)
+		if err != nil {
+			return err
+		}
+		dtzSubs = append(dtzSubs, []encodedT{e})
+	}
+
+	if err := writeTableFile(filepath.Join(dir, s.name+wdlSuffix), wdlMagic, t, pieces, wdlSubs, true); err != nil {
+		return err
+	}
+	return writeTableFile(filepath.Join(dir, s.name+dtzSuffix), dtzMagic, t, pieces, dtzSubs, false)
+}
+
+func writeTableFile(path string, magic [4]byte, t *tableT, pieces []int, subs [][]encodedT, isWdl bool) error {
+	buf := append([]byte{}, magic[:]...)
+	var flags byte
+	if t.key != t.key2 {
+		flags |= 1
+	}
+	if t.hasPawns {
+		flags |= 2
+	}
+	buf = append(buf, flags)
+
+	// Group order and pieces for each file - the low nibble is for white to move and the high nibble for black to move
+	for range subs {
+		buf = append(buf, 0)
+		for _, piece := range pieces {
+			buf = append(buf, byte(piece|piece<<4))
+		}
+	}
+	buf = append(buf, make([]byte, len(buf)&1)...)
+
+	for _, sides := range subs {
+		for _, e := range sides {
+			buf = append(buf, e.sizes...)
+		}
+	}
+	if !isWdl {
+		// No DTZ value maps
+		buf = append(buf, make([]byte, len(buf)&1)...)
+	}
+	for _, sides := range subs {
+		for _, e := range sides {
+			buf = append(buf, e.sparseIndex...)
+		}
+	}
+	for _, sides := range subs {
+		for _, e := range sides {
+			buf = append(buf, e.blockLengths...)
+		}
+	}
+	for _, sides := range subs {
+		for _, e := range sides {
+			buf = append(buf, make([]byte, (64-len(buf)&0x3F)&0x3F)...)
+			buf = append(buf, e.data...)
+		}
+	}
+
+	return ioutil.WriteFile(path, buf, 0644)
+}
+
+// Compression parameters
+const (
+	blockSizeLog = 5 // 32-byte blocks
+	spanLog      = 8
+	maxSyms      = 1024
+	minPairCount = 8
+	maxSymValues = 256 // symlen is a byte
+)
+
+// One compressed sub-table - the sizes and Huffman code, the sparse index, the block lengths and the blocks
+type encodedT struct {
+	sizes        []byte
+	sparseIndex  []byte
+	blockLengths []byte
+	data         []byte
+}
+
+// A Re-Pair symbol - either a value or a pair of symbols
+type symT struct {
+	left, right int // right is -1 for a value, which is left
+	nValues     int
+}
+
+func compress(values []uint8, flags uint8) (encodedT, error) {
+	var e encodedT
+
+	isSingleValue := true
+	for _, v := range values {
+		isSingleValue = isSingleValue && v == values[0]
+	}
+	if isSingleValue {
+		e.sizes = []byte{flags | singleValueFlag, values[0]}
+		return e, nil
+	}
+
+	// One symbol per value to start with
+	var syms []symT
+	valueSym := make(map[uint8]int)
+	seq := make([]int, len(values))
+	for i, v := range values {
+		sym, ok := valueSym[v]
+		if !ok {
+			sym = len(syms)
+			syms = append(syms, symT{int(v), -1, 1})
+			valueSym[v] = sym
+		}
+		seq[i] = sym
+	}
+
+	// Recursive Pairing - repeatedly replace the most frequent pair of adjacent symbols with a new symbol
+	for len(syms) < maxSyms {
+		counts := make(map[[2]int]int)
+		for i := 0; i+1 < len(seq); i++ {
+			counts[[2]int{seq[i], seq[i+1]}]++
+			// Pairs in a run of the same symbol overlap
+			if seq[i] == seq[i+1] && i+2 < len(seq) && seq[i+2] == seq[i] {
+				i++
+			}
+		}
+		best, bestCount := [2]int{}, 0
+		for pair, count := range counts {
+			if syms[pair[0]].nValues+syms[pair[1]].nValues > maxSymValues {
+				continue
+			}
+			if count > bestCount || (count == bestCount && (pair[0] < best[0] || (pair[0] == best[0] && pair[1] < best[1]))) {
+				best, bestCount = pair, count
+			}
+		}
+		if bestCount < minPairCount {
+			break
+		}
+
+		sym := len(syms)
+		syms = append(syms, symT{best[0], best[1], syms[best[0]].nValues + syms[best[1]].nValues})
+		paired := seq[:0]
+		for i := 0; i < len(seq); i++ {
+			if i+1 < len(seq) && seq[i] == best[0] && seq[i+1] == best[1] {
+				paired = append(paired, sym)
+				i++
+			} else {
+				paired = append(paired, seq[i])
+			}
+		}
+		seq = paired
+	}
+
+	// Canonical Huffman code - the decoder needs the symbols numbered from the longest code to the shortest, and symbols
+	// that are only used in pairs have no code so they go first
+	freqs := make([]int, len(syms))
+	for _, sym := range seq {
+		freqs[sym]++
+	}
+	lens := huffmanLengths(freqs)
+	order := make([]int, len(syms))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		li, lj := lens[order[i]], lens[order[j]]
+		if (li == 0) != (lj == 0) {
+			return li == 0
+		}
+		return li > lj
+	})
+	newSym := make([]int, len(syms))
+	for i, sym := range order {
+		newSym[sym] = i
+	}
+
+	minLen, maxLen, nUnused := 64, 0, 0
+	var counts [65]int
+	for _, l := range lens {
+		if l == 0 {
+			nUnused++
+			continue
+		}
+		counts[l]++
+		if l < minLen {
+			minLen = l
+		}
+		if l > maxLen {
+			maxLen = l
+		}
+	}
+	if maxLen > 32 {
+		return e, errors.New("syzygy: Huffman code too long")
+	}
+	var lowestSym, base [65]int
+	lowestSym[maxLen] = nUnused
+	for l := maxLen - 1; l >= minLen; l-- {
+		lowestSym[l] = lowestSym[l+1] + counts[l+1]
+		if (base[l+1]+counts[l+1])&1 != 0 {
+			return e, errors.New("syzygy: incomplete Huffman code")
+		}
+		base[l] = (base[l+1] + counts[l+1]) / 2
+	}
+	code := func(sym int) uint64 {
+		l := lens[sym]
+		return uint64(base[l] + newSym[sym] - lowestSym[l])
+	}
+
+	// Pack the symbols into blocks - a symbol never spans blocks
+	var blockStarts []int
+	var bitBuf []byte
+	nBits, blockValues, nValues := 0, 0, 0
+	flush := func() {
+		block := make([]byte, 1<<blockSizeLog)
+		copy(block, bitBuf)
+		e.data = append(e.data, block...)
+		e.blockLengths = append(e.blockLengths, 0, 0)
+		binary.LittleEndian.PutUint16(e.blockLengths[len(e.blockLengths)-2:], uint16(blockValues-1))
+		bitBuf, nBits, blockValues = nil, 0, 0
+	}
+	for _, sym := range seq {
+		l, n := lens[sym], syms[sym].nValues
+		if nBits+l > 8<<blockSizeLog || blockValues+n > 65536 {
+			flush()
+		}
+		if blockValues == 0 {
+			blockStarts = append(blockStarts, nValues)
+		}
+		c := code(sym)
+		for i := l - 1; i >= 0; i-- {
+			if nBits%8 == 0 {
+				bitBuf = append(bitBuf, 0)
+			}
+			if c>>uint(i)&1 != 0 {
+				bitBuf[nBits/8] |= 0x80 >> uint(nBits%8)
+			}
+			nBits++
+		}
+		blockValues += n
+		nValues += n
+	}
+	flush()
+	numBlocks := len(blockStarts)
+
+	// The sparse index entry k holds the block and offset of the value k*span + span/2 - past the end it's relative to
+	// the end of the last block, so that lookups back up into the last block
+	span := 1 << spanLog
+	for k := 0; k*span < len(values); k++ {
+		target := k*span + span/2
+		block, offset := numBlocks, target-len(values)
+		if target < len(values) {
+			block = sort.Search(numBlocks, func(b int) bool { return blockStarts[b] > target }) - 1
+			offset = target - blockStarts[block]
+		}
+		var entry [6]byte
+		binary.LittleEndian.PutUint32(entry[0:4], uint32(block))
+		binary.LittleEndian.PutUint16(entry[4:6], uint16(offset))
+		e.sparseIndex = append(e.sparseIndex, entry[:]...)
+	}
+
+	var header [9]byte
+	header[0] = blockSizeLog
+	header[1] = spanLog
+	header[2] = 0 // no block length padding
+	binary.LittleEndian.PutUint32(header[3:7], uint32(numBlocks))
+	header[7], header[8] = byte(maxLen), byte(minLen)
+	e.sizes = append([]byte{flags}, header[:]...)
+	for l := minLen; l <= maxLen; l++ {
+		e.sizes = append(e.sizes, byte(lowestSym[l]), byte(lowestSym[l]>>8))
+	}
+	e.sizes = append(e.sizes, byte(len(syms)), byte(len(syms)>>8))
+	for _, sym := range order {
+		left, right := syms[sym].left, 0xFFF
+		if syms[sym].right >= 0 {
+			left, right = newSym[left], newSym[syms[sym].right]
+		}
+		e.sizes = append(e.sizes, byte(left), byte(left>>8&0xF|right<<4), byte(right>>4))
+	}
+	e.sizes = append(e.sizes, make([]byte, len(syms)&1)...)
+
+	return e, nil
+}
+
+// Huffman code lengths for the symbol frequencies - 0 for unused symbols
+func huffmanLengths(freqs []int) []int {
+	lens := make([]int, len(freqs))
+	type nodeT struct {
+		freq int
+		syms []int // the symbols under the node
+	}
+	var nodes []nodeT
+	for sym, freq := range freqs {
+		if freq > 0 {
+			nodes = append(nodes, nodeT{freq, []int{sym}})
+		}
+	}
+	if len(nodes) == 1 {
+		lens[nodes[0].syms[0]] = 1
+		return lens
+	}
+	// Merge the two least frequent nodes until there's only one, and each merge adds a bit to the symbols under them
+	for len(nodes) > 1 {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].freq < nodes[j].freq })
+		merged := nodeT{nodes[0].freq + nodes[1].freq, append(append([]int{}, nodes[0].syms...), nodes[1].syms...)}
+		for _, sym := range merged.syms {
+			lens[sym]++
+		}
+		nodes = append([]nodeT{merged}, nodes[2:]...)
+	}
+	return lens
+}
+
+func TestGenerateTables(t *testing.T) {
+	if !*generateFlag {
+		t.Skip("only with -generate")
+	}
+
+	kqk, err := solve("KQvK", dragon.Queen, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	krk, err := solve("KRvK", dragon.Rook, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Drawn, but needed to probe under-promotions
+	kbk, err := solve("KBvK", dragon.Bishop, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knk, err := solve("KNvK", dragon.Knight, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpk, err := solve("KPvK", dragon.Pawn, map[dragon.Piece]*solvedT{dragon.Queen: kqk, dragon.Rook: krk, dragon.Bishop: kbk, dragon.Knight: knk})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solved := []*solvedT{kqk, krk, kbk, knk, kpk}
+	for _, s := range solved {
+		if err := s.writeTables("testdata"); err != nil {
+			t.Fatal(err)
+		}
+		var counts [2][5]int
+		var maxDtz [2]int
+		for p := 0; p < nPos; p++ {
+			if s.valid[p] {
+				stm := p >> 18
+				counts[stm][s.wdl[p]+2]++
+				if s.dtz[p] > maxDtz[stm] {
+					maxDtz[stm] = s.dtz[p]
+				}
+			}
+		}
+		t.Logf("%s white to move W/D/L %d/%d/%d max DTZ %d, black to move W/D/L %d/%d/%d max DTZ %d", s.name,
+			counts[0][4], counts[0][2], counts[0][0], maxDtz[0], counts[1][4], counts[1][2], counts[1][0], maxDtz[1])
+	}
+
+	// Every position probes as solved
+	if _, err := Init("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	defer Init("")
+	for _, s := range solved {
+		for p := 0; p < nPos; p++ {
+			if !s.valid[p] {
+				continue
+			}
+			board := dragon.ParseFen(solverFen(s.piece, p>>18 == 1, p>>12&63, p>>6&63, p&63))
+			dtz := s.dtz[p] * signOf(int(s.wdl[p]))
+			if wdl, ok := ProbeWdl(&board); !ok || wdl != s.wdl[p] {
+				t.Fatalf("%s: ProbeWdl %s is %s (%v) expected %s\n", s.name, board.ToFen(), wdl, ok, s.wdl[p])
+			}
+			if probeDtz, ok := ProbeDtz(&board); !ok || probeDtz != dtz {
+				t.Fatalf("%s: ProbeDtz %s is %d (%v) expected %d\n", s.name, board.ToFen(), probeDtz, ok, dtz)
+			}
+		}
+	}
+}
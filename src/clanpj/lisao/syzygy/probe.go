@@ -0,0 +1,585 @@
+package syzygy
+
+import (
+	"math/bits"
+	"sort"
+	"strings"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+// Win/draw/loss from the perspective of the side to move.
+// Cursed wins and blessed losses are draws under the fifty-move rule.
+type WdlT int
+
+const (
+	Loss        WdlT = -2
+	BlessedLoss WdlT = -1
+	Draw        WdlT = 0
+	CursedWin   WdlT = 1
+	Win         WdlT = 2
+)
+
+func (wdl WdlT) String() string {
+	switch wdl {
+	case Loss:
+		return "loss"
+	case BlessedLoss:
+		return "blessed-loss"
+	case Draw:
+		return "draw"
+	case CursedWin:
+		return "cursed-win"
+	case Win:
+		return "win"
+	default:
+		return "<unknown>"
+	}
+}
+
+type probeStateT int
+
+const (
+	probeFail            probeStateT = iota
+	probeOk                          // probe succeeded
+	probeChangeStm                   // DTZ table is for the other side to move
+	probeZeroingBestMove             // best move is a capture or pawn move
+)
+
+func popCount(bb uint64) int {
+	return bits.OnesCount64(bb)
+}
+
+// Piece code as used in the tables - black pieces have bit 3 set
+func pieceCodeAt(board *dragon.Board, sq int) int {
+	piece := int(board.PieceAt(uint8(sq)))
+	if board.Black.All&(uint64(1)<<uint(sq)) != 0 {
+		piece |= 8
+	}
+	return piece
+}
+
+// Tables don't contain positions with castling rights.
+// Castling needs the king and a rook on their original squares so we can usually avoid the (slow) FEN check.
+func hasCastlingRights(board *dragon.Board) bool {
+	whiteMaybe := board.White.Kings&(1<<4) != 0 && board.White.Rooks&(1<<0|1<<7) != 0
+	blackMaybe := board.Black.Kings&(1<<60) != 0 && board.Black.Rooks&(1<<56|1<<63) != 0
+	if !whiteMaybe && !blackMaybe {
+		return false
+	}
+	fields := strings.Fields(board.ToFen())
+	return len(fields) > 2 && fields[2] != "-"
+}
+
+// Can the position be probed at all?
+func canProbe(board *dragon.Board) bool {
+	return popCount(board.White.All|board.Black.All) <= maxCardinality && !hasCastlingRights(board)
+}
+
+// Probe the WDL tables.
+// The position must have no more than MaxPieces() pieces and no castling rights.
+// Note that the result assumes the fifty-move counter is zero.
+func ProbeWdl(board *dragon.Board) (WdlT, bool) {
+	if !canProbe(board) {
+		return Draw, false
+	}
+	wdl, state := search(board, false)
+	return wdl, state != probeFail
+}
+
+// Probe the DTZ tables.
+// Returns the distance to zeroing the fifty-move counter (with a capture or pawn move) in plies, assuming the counter is
+// currently zero, from the perspective of the side to move:
+//
+//	n < -100       : loss, but draw under the fifty-move rule
+//	-100 <= n < -1 : loss in n plies
+//	-1             : loss - the side to move is mated
+//	0              : draw
+//	1 < n <= 100   : win in n plies
+//	100 < n        : win, but draw under the fifty-move rule
+//
+// The value can be off by one - -n can mean a loss in n+1 plies and +n a win in n+1 plies.
+func ProbeDtz(board *dragon.Board) (int, bool) {
+	if !canProbe(board) {
+		return 0, false
+	}
+	return probeDtz(board)
+}
+
+func probeDtz(board *dragon.Board) (int, bool) {
+	wdl, state := search(board, true)
+
+	// DTZ tables don't store draws
+	if state == probeFail || wdl == Draw {
+		return 0, state != probeFail
+	}
+
+	// DTZ stores a "don't care" value (or a wrong one for a losing en-passant) in this case, so we can't probe
+	if state == probeZeroingBestMove {
+		return dtzBeforeZeroing(wdl), true
+	}
+
+	dtz, state := probeTable(board, false, wdl)
+	if state == probeFail {
+		return 0, false
+	}
+
+	if state != probeChangeStm {
+		if wdl == BlessedLoss || wdl == CursedWin {
+			dtz += 100
+		}
+		return dtz * signOf(int(wdl)), true
+	}
+
+	// DTZ is stored for the other side, so we do a 1-ply search for the winning move that minimises DTZ
+	minDtz := 0xFFFF
+	for _, move := range board.GenerateLegalMoves() {
+		zeroing := dragon.IsCapture(move, board) || board.PieceAt(move.From()) == dragon.Pawn
+
+		unapply := board.Apply(move)
+
+		// For zeroing moves we want the DTZ before the move - we only need the sign of the WDL for that
+		ok := true
+		if zeroing {
+			childWdl, childState := search(board, false)
+			dtz, ok = -dtzBeforeZeroing(childWdl), childState != probeFail
+		} else {
+			dtz, ok = probeDtz(board)
+			dtz = -dtz
+		}
+
+		// A mating move has DTZ 1
+		if dtz == 1 && board.OurKingInCheck() && len(board.GenerateLegalMoves()) == 0 {
+			minDtz = 1
+		}
+
+		// Zeroing moves are already accounted for by dtzBeforeZeroing
+		if !zeroing {
+			dtz += signOf(dtz)
+		}
+
+		// Skip draws, and if we are winning only pick positive DTZ
+		if dtz < minDtz && signOf(dtz) == signOf(int(wdl)) {
+			minDtz = dtz
+		}
+
+		unapply()
+
+		if !ok {
+			return 0, false
+		}
+	}
+
+	// No legal moves means we're mated
+	if minDtz == 0xFFFF {
+		return -1, true
+	}
+	return minDtz, true
+}
+
+// Find the DTZ-optimal move at the root, taking the fifty-move counter into account.
+// Returns the move, the WDL of the position (with draws under the fifty-move rule being cursed wins or blessed losses),
+// and the DTZ (in plies) of the move.
+func ProbeRoot(board *dragon.Board) (dragon.Move, WdlT, int, bool) {
+	if !canProbe(board) {
+		return 0, Draw, 0, false
+	}
+
+	cnt50 := int(board.Halfmoveclock)
+
+	var bestMove dragon.Move
+	bestRank, bestDtz := -0xFFFF, 0
+	for _, move := range board.GenerateLegalMoves() {
+		unapply := board.Apply(move)
+
+		var dtz int
+		var ok bool
+		if board.Halfmoveclock == 0 {
+			// Zeroing move - DTZ is one of -101/-1/0/1/101
+			var wdl WdlT
+			wdl, ok = ProbeWdl(board)
+			dtz = dtzBeforeZeroing(-wdl)
+		} else {
+			// Otherwise use the DTZ of the new position corrected by one ply
+			dtz, ok = probeDtz(board)
+			dtz = -dtz
+			dtz += signOf(dtz)
+		}
+
+		// Make sure that a mating move has DTZ 1
+		if dtz == 2 && board.OurKingInCheck() && len(board.GenerateLegalMoves()) == 0 {
+			dtz = 1
+		}
+
+		unapply()
+
+		if !ok {
+			return 0, Draw, 0, false
+		}
+
+		// Certain wins are ranked equally, as are certain losses, unless the fifty-move rule comes into play
+		rank := 0
+		if dtz > 0 {
+			rank = 1000
+			if dtz+cnt50 > 99 {
+				rank = 1000 - (dtz + cnt50)
+			}
+		} else if dtz < 0 {
+			rank = -1000
+			if -dtz*2+cnt50 >= 100 {
+				rank = -1000 + (-dtz + cnt50)
+			}
+		}
+
+		// Between equally ranked moves prefer the quickest win or the slowest loss
+		if rank > bestRank || (rank == bestRank && dtz < bestDtz) {
+			bestMove, bestRank, bestDtz = move, rank, dtz
+		}
+	}
+
+	// No legal moves
+	if bestRank == -0xFFFF {
+		return 0, Draw, 0, false
+	}
+
+	wdl := Draw
+	switch {
+	case bestRank >= 1000:
+		wdl = Win
+	case bestRank > 0:
+		wdl = CursedWin
+	case bestRank <= -1000:
+		wdl = Loss
+	case bestRank < 0:
+		wdl = BlessedLoss
+	}
+	return bestMove, wdl, bestDtz, true
+}
+
+// DTZ tables don't store valid values for zeroing moves but we can recover the DTZ of the previous move from the WDL
+func dtzBeforeZeroing(wdl WdlT) int {
+	switch wdl {
+	case Win:
+		return 1
+	case CursedWin:
+		return 101
+	case BlessedLoss:
+		return -101
+	case Loss:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func signOf(n int) int {
+	if n > 0 {
+		return 1
+	} else if n < 0 {
+		return -1
+	}
+	return 0
+}
+
+// Tables store "don't care" values for positions where the side to move has a winning capture, and possibly a loss
+// for positions where the side to move has a drawing capture. So we have to search the captures (and pawn moves for DTZ)
+// and take the best of those and the probe of the position itself.
+func search(board *dragon.Board, checkZeroingMoves bool) (WdlT, probeStateT) {
+	bestValue := Loss
+
+	moves := board.GenerateLegalMoves()
+	moveCount := 0
+	for _, move := range moves {
+		if !dragon.IsCapture(move, board) && (!checkZeroingMoves || board.PieceAt(move.From()) != dragon.Pawn) {
+			continue
+		}
+		moveCount++
+
+		unapply := board.Apply(move)
+		value, state := search(board, false)
+		value = -value
+		unapply()
+
+		if state == probeFail {
+			return Draw, probeFail
+		}
+
+		if value > bestValue {
+			bestValue = value
+			if value >= Win {
+				// Winning zeroing move
+				return value, probeZeroingBestMove
+			}
+		}
+	}
+
+	// If we've already searched all the legal moves then we don't probe - tables don't store positions with en-passant
+	// rights, and positions with only capture moves need to return probeZeroingBestMove
+	noMoreMoves := moveCount != 0 && moveCount == len(moves)
+
+	var value WdlT
+	if noMoreMoves {
+		value = bestValue
+	} else {
+		v, state := probeTable(board, true, Draw)
+		if state == probeFail {
+			return Draw, probeFail
+		}
+		value = WdlT(v)
+	}
+
+	// DTZ stores a "don't care" value if bestValue is a win
+	if bestValue >= value {
+		if bestValue > Draw || noMoreMoves {
+			return bestValue, probeZeroingBestMove
+		}
+		return bestValue, probeOk
+	}
+	return value, probeOk
+}
+
+// Probe the WDL or DTZ table for the position itself.
+// For WDL the value is the WdlT; for DTZ it's the (unsigned) DTZ in plies, given the position's WDL.
+func probeTable(board *dragon.Board, isWdl bool, wdl WdlT) (value int, state probeStateT) {
+	// KvK
+	if popCount(board.White.All|board.Black.All) == 2 {
+		return int(Draw), probeOk
+	}
+
+	t := tables[boardMaterialKey(board)]
+	if t == nil {
+		return 0, probeFail
+	}
+	ft := t.fileTable(isWdl)
+	if ft == nil {
+		return 0, probeFail
+	}
+
+	// A corrupted file might send us out of range
+	defer func() {
+		if recover() != nil {
+			value, state = 0, probeFail
+		}
+	}()
+
+	return t.probe(board, ft, wdl)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (t *tableT) probe(board *dragon.Board, ft *fileTableT, wdl WdlT) (int, probeStateT) {
+	var squares [maxPieces]int
+	var pieces [maxPieces]int
+	size, leadPawnsCnt := 0, 0
+	var leadPawns uint64
+	tbFile := 0
+
+	blackToMove := !board.Wtomove
+
+	// If both sides have the same pieces then the table only stores white-to-move, so for black-to-move we flip colours
+	// and squares. Likewise if black is the stronger side (i.e. the first side of the table name).
+	symmetricBlackToMove := t.key == t.key2 && blackToMove
+	blackStronger := boardMaterialKey(board) != t.key
+	flip := symmetricBlackToMove || blackStronger
+
+	flipColour, flipSquares := 0, 0
+	if flip {
+		flipColour, flipSquares = 8, 56
+	}
+	stm := boolToInt(flip) ^ boolToInt(blackToMove)
+
+	// With pawns there are separate tables for the leading pawn on files a, b, c or d.
+	// The leading pawn is the one with the highest mapPawns[] value.
+	if t.hasPawns {
+		// The leading pawns are the first pieces in the table
+		leadColour := ft.get(0, 0, true).pieces[0] ^ flipColour
+		if leadColour&8 == 0 {
+			leadPawns = board.White.Pawns
+		} else {
+			leadPawns = board.Black.Pawns
+		}
+		for bb := leadPawns; bb != 0; bb &= bb - 1 {
+			squares[size] = bits.TrailingZeros64(bb) ^ flipSquares
+			size++
+		}
+		leadPawnsCnt = size
+
+		maxI := 0
+		for i := 1; i < leadPawnsCnt; i++ {
+			if mapPawns[squares[i]] > mapPawns[squares[maxI]] {
+				maxI = i
+			}
+		}
+		squares[0], squares[maxI] = squares[maxI], squares[0]
+
+		tbFile = fileOf(squares[0])
+		if tbFile > 3 {
+			tbFile = 7 - tbFile
+		}
+	}
+
+	// DTZ tables are one-sided
+	if !ft.isWdl {
+		flags := ft.get(0, tbFile, t.hasPawns).flags
+		if int(flags&stmFlag) != stm && (t.key != t.key2 || t.hasPawns) {
+			return 0, probeChangeStm
+		}
+	}
+
+	// All the other pieces
+	for bb := (board.White.All | board.Black.All) ^ leadPawns; bb != 0; bb &= bb - 1 {
+		sq := bits.TrailingZeros64(bb)
+		squares[size] = sq ^ flipSquares
+		pieces[size] = pieceCodeAt(board, sq) ^ flipColour
+		size++
+	}
+
+	d := ft.get(stm, tbFile, t.hasPawns)
+	idx := t.encode(d, squares[:size], pieces[:size], leadPawnsCnt)
+
+	value := d.decompress(ft.data, idx)
+
+	if ft.isWdl {
+		return value - 2, probeOk
+	}
+	return t.mapDtzScore(ft, tbFile, value, wdl), probeOk
+}
+
+// Compute the table index of the position. Squares and pieces are already colour-flipped for the table, with the leading
+// pawns (if any) first.
+// Same-type pieces are encoded together - for k pieces on squares s1 < s2 < ... < sk the index is
+// binomial[1][s1] + binomial[2][s2] + ... + binomial[k][sk].
+func (t *tableT) encode(d *pairsDataT, squares []int, pieces []int, leadPawnsCnt int) uint64 {
+	size := len(squares)
+
+	// Reorder the pieces into the order used by the table
+	for i := leadPawnsCnt; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	// Flip so the leading piece is on files a-d
+	if fileOf(squares[0]) > 3 {
+		for i := range squares {
+			squares[i] ^= 7
+		}
+	}
+
+	var idx uint64
+	if t.hasPawns {
+		// Encode the leading pawns in ascending mapPawns[] order
+		idx = leadPawnIdx[leadPawnsCnt][squares[0]]
+		leading := squares[1:leadPawnsCnt]
+		sort.SliceStable(leading, func(i, j int) bool { return mapPawns[leading[i]] < mapPawns[leading[j]] })
+		for i := 1; i < leadPawnsCnt; i++ {
+			idx += binomial[i][mapPawns[squares[i]]]
+		}
+	} else {
+		// Flip so the leading piece is on ranks 1-4
+		if rankOf(squares[0]) > 3 {
+			for i := range squares {
+				squares[i] ^= 56
+			}
+		}
+
+		// Flip about the a1-h8 diagonal so the first piece of the leading group that is off the diagonal is below it
+		for i := 0; i < d.groupLen[0]; i++ {
+			if offA1H8(squares[i]) == 0 {
+				continue
+			}
+			if offA1H8(squares[i]) > 0 {
+				for j := i; j < size; j++ {
+					squares[j] = ((squares[j] >> 3) | (squares[j] << 3)) & 63
+				}
+			}
+			break
+		}
+
+		if t.hasUniquePieces {
+			// The three leading pieces are encoded together
+			s0, s1, s2 := squares[0], squares[1], squares[2]
+			adjust1 := boolToInt(s1 > s0)
+			adjust2 := boolToInt(s2 > s0) + boolToInt(s2 > s1)
+
+			if offA1H8(s0) != 0 {
+				// First piece below the diagonal - mapA1D1D4[] maps it to 0..5
+				idx = (uint64(mapA1D1D4[s0])*63+uint64(s1-adjust1))*62 + uint64(s2-adjust2)
+			} else if offA1H8(s1) != 0 {
+				// First piece on the diagonal, second below
+				idx = (6*63+uint64(rankOf(s0))*28+uint64(mapB1H1H7[s1]))*62 + uint64(s2-adjust2)
+			} else if offA1H8(s2) != 0 {
+				// First two pieces on the diagonal, third below
+				idx = 6*63*62 + 4*28*62 + uint64(rankOf(s0))*7*28 + uint64(rankOf(s1)-adjust1)*28 + uint64(mapB1H1H7[s2])
+			} else {
+				// All three pieces on the diagonal
+				idx = 6*63*62 + 4*28*62 + 4*7*28 + uint64(rankOf(s0))*7*6 + uint64(rankOf(s1)-adjust1)*6 + uint64(rankOf(s2)-adjust2)
+			}
+		} else {
+			// Just the kings
+			idx = uint64(mapKK[mapA1D1D4[squares[0]]][squares[1]])
+		}
+	}
+
+	idx *= d.groupIdx[0]
+
+	// Encode the remaining pawns and then the pieces, in ascending square order
+	remainingPawns := t.hasPawns && t.pawnCount[1] != 0
+	groupStart := d.groupLen[0]
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[groupStart : groupStart+d.groupLen[next]]
+		sort.Ints(group)
+
+		var n uint64
+		for i, sq := range group {
+			// Squares are mapped down for each square in the previous groups that comes earlier
+			adjust := 0
+			for _, prevSq := range squares[:groupStart] {
+				if sq > prevSq {
+					adjust++
+				}
+			}
+			if remainingPawns {
+				adjust += 8
+			}
+			n += binomial[i+1][sq-adjust]
+		}
+
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		groupStart += len(group)
+	}
+
+	return idx
+}
+
+// DTZ values are stored by frequency and mapped back to the real values, and may be stored in moves rather than plies
+func (t *tableT) mapDtzScore(ft *fileTableT, file int, value int, wdl WdlT) int {
+	// Map offsets are ordered win, loss, cursed win, blessed loss
+	wdlMap := [5]int{1, 3, 0, 2, 0}
+
+	d := ft.get(0, file, t.hasPawns)
+	if d.flags&mappedFlag != 0 {
+		mapIdx := d.mapIdx[wdlMap[wdl+2]]
+		if d.flags&wideFlag != 0 {
+			value = int(ft.data[ft.mapOff+2*(mapIdx+value)]) | int(ft.data[ft.mapOff+2*(mapIdx+value)+1])<<8
+		} else {
+			value = int(ft.data[ft.mapOff+mapIdx+value])
+		}
+	}
+
+	if (wdl == Win && d.flags&winPliesFlag == 0) || (wdl == Loss && d.flags&lossPliesFlag == 0) || wdl == CursedWin || wdl == BlessedLoss {
+		value *= 2
+	}
+
+	return value + 1
+}
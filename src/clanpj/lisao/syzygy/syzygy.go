@@ -0,0 +1,551 @@
+// Syzygy endgame tablebase probing - a pure-Go port of the probing code used by Stockfish and Fathom.
+// Tables are found at Init time by scanning the SyzygyPath directories for WDL (.rtbw) files.
+// Files are read into memory at first access.
+
+package syzygy
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+const wdlSuffix = ".rtbw"
+const dtzSuffix = ".rtbz"
+
+var wdlMagic = [4]byte{0x71, 0xE8, 0x23, 0x5D}
+var dtzMagic = [4]byte{0xD7, 0x66, 0x0C, 0xA5}
+
+// Table flags - all of them refer to DTZ tables except for singleValueFlag which also applies to WDL tables
+const (
+	stmFlag         = 1
+	mappedFlag      = 2
+	winPliesFlag    = 4
+	lossPliesFlag   = 8
+	wideFlag        = 16
+	singleValueFlag = 128
+)
+
+// Low-level indexing information for one (side-to-move, leading pawn file) sub-table of a file
+type pairsDataT struct {
+	flags           uint8
+	sizeofBlock     uint64 // block size in bytes
+	span            uint64 // about every span values there is a sparse index entry
+	numBlocks       int
+	maxSymLen       int // max length in bits of the Huffman symbols
+	minSymLen       int // min length in bits of the Huffman symbols - or the value itself for single-value tables
+	lowestSymOff    int // offset of lowestSym[] - lowestSym[l] is the symbol of length l with the lowest value
+	btreeOff        int // offset of btree[] - btree[sym] holds the left and right symbols that expand sym
+	blockLengthOff  int // offset of blockLength[] - number of stored positions (minus one) for each block
+	blockLengthSize int
+	sparseIndexOff  int // offset of the sparse index into blockLength[]
+	sparseIndexSize uint64
+	dataOff         int      // offset of the Huffman compressed data
+	base64          []uint64 // base64[l - minSymLen] is the 64-bit padded lowest symbol of length l
+	symlen          []uint8  // number of values (minus one) represented by each symbol
+	pieces          [maxPieces]int
+	groupIdx        [maxPieces + 1]uint64 // start index for the encoding of each group of pieces
+	groupLen        [maxPieces + 1]int    // number of pieces in each group, zero-terminated
+	mapIdx          [4]int                // DTZ value map offsets for win, loss, cursed win, blessed loss
+}
+
+// One tablebase file - loaded at first access
+type fileTableT struct {
+	isWdl  bool
+	once   sync.Once
+	ok     bool
+	data   []byte
+	mapOff int              // DTZ value map offset
+	items  [2][4]pairsDataT // [side-to-move][leading pawn file]
+}
+
+func (ft *fileTableT) get(stm int, file int, hasPawns bool) *pairsDataT {
+	if !hasPawns {
+		file = 0
+	}
+	// DTZ tables are one-sided
+	if !ft.isWdl {
+		stm = 0
+	}
+	return &ft.items[stm][file]
+}
+
+// A material configuration, e.g. KRvK, with its WDL and DTZ files.
+// Tables are stored with white as the side of the first piece list - key is the material key of that, and key2 with the colours swapped.
+type tableT struct {
+	name            string
+	wdlPath         string
+	key             uint64
+	key2            uint64
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	pawnCount       [2]int // [leading colour][other colour]
+	wdl             fileTableT
+	dtz             fileTableT
+}
+
+// Tables by material key - both colour orientations
+var tables = map[uint64]*tableT{}
+
+// Directories searched for table files
+var searchPaths []string
+
+// Max number of pieces of the available tables
+var maxCardinality = 0
+
+// Max number of pieces (including kings) for which tables are available - 0 if there are no tables
+func MaxPieces() int {
+	return maxCardinality
+}
+
+// Find the tables in the path, which is a list of directories separated by the OS path list separator.
+// Returns the number of (WDL) tables found.
+// This replaces any previously found tables, and is not safe to call concurrently with probing.
+func Init(path string) (int, error) {
+	tables = map[uint64]*tableT{}
+	searchPaths = nil
+	maxCardinality = 0
+
+	if path == "" || path == "<empty>" {
+		return 0, nil
+	}
+
+	var firstErr error
+	nTables := 0
+	for _, dir := range filepath.SplitList(path) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		searchPaths = append(searchPaths, dir)
+
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), wdlSuffix) {
+				continue
+			}
+			name := strings.TrimSuffix(file.Name(), wdlSuffix)
+			t, err := newTable(name)
+			if err != nil {
+				continue
+			}
+			if _, ok := tables[t.key]; ok {
+				continue // already found in an earlier directory
+			}
+			t.wdlPath = filepath.Join(dir, file.Name())
+			tables[t.key] = t
+			tables[t.key2] = t
+			nTables++
+			if t.pieceCount > maxCardinality {
+				maxCardinality = t.pieceCount
+			}
+		}
+	}
+
+	return nTables, firstErr
+}
+
+// Piece letters indexed by dragon piece type
+const pieceLetters = " PNBRQK"
+
+// Material key - 4 bits per piece count, white in the low bits
+func materialKey(counts *[2][7]int) uint64 {
+	var key uint64
+	for colour := 0; colour < 2; colour++ {
+		for piece := dragon.Pawn; piece <= dragon.King; piece++ {
+			key |= uint64(counts[colour][piece]) << uint(24*colour+4*(piece-1))
+		}
+	}
+	return key
+}
+
+func boardMaterialKey(board *dragon.Board) uint64 {
+	var counts [2][7]int
+	for colour, bbs := range [2]*dragon.Bitboards{&board.White, &board.Black} {
+		counts[colour][dragon.Pawn] = popCount(bbs.Pawns)
+		counts[colour][dragon.Knight] = popCount(bbs.Knights)
+		counts[colour][dragon.Bishop] = popCount(bbs.Bishops)
+		counts[colour][dragon.Rook] = popCount(bbs.Rooks)
+		counts[colour][dragon.Queen] = popCount(bbs.Queens)
+		counts[colour][dragon.King] = popCount(bbs.Kings)
+	}
+	return materialKey(&counts)
+}
+
+// Parse the table name, e.g. KRPvKR, into the table material info
+func newTable(name string) (*tableT, error) {
+	sides := strings.Split(name, "v")
+	if len(sides) != 2 {
+		return nil, errors.New("syzygy: malformed table name " + name)
+	}
+
+	var counts [2][7]int
+	for colour, side := range sides {
+		if len(side) == 0 || side[0] != 'K' {
+			return nil, errors.New("syzygy: malformed table name " + name)
+		}
+		for _, c := range side {
+			piece := strings.IndexRune(pieceLetters, c)
+			if piece < int(dragon.Pawn) {
+				return nil, errors.New("syzygy: malformed table name " + name)
+			}
+			counts[colour][piece]++
+		}
+		if counts[colour][dragon.King] != 1 {
+			return nil, errors.New("syzygy: malformed table name " + name)
+		}
+	}
+
+	t := &tableT{name: name, wdl: fileTableT{isWdl: true}}
+	t.key = materialKey(&counts)
+	counts[0], counts[1] = counts[1], counts[0]
+	t.key2 = materialKey(&counts)
+	counts[0], counts[1] = counts[1], counts[0]
+
+	for colour := 0; colour < 2; colour++ {
+		for piece := dragon.Pawn; piece <= dragon.King; piece++ {
+			t.pieceCount += counts[colour][piece]
+			if piece != dragon.King && counts[colour][piece] == 1 {
+				t.hasUniquePieces = true
+			}
+		}
+	}
+	if t.pieceCount > maxPieces {
+		return nil, errors.New("syzygy: too many pieces in table " + name)
+	}
+
+	whitePawns, blackPawns := counts[0][dragon.Pawn], counts[1][dragon.Pawn]
+	t.hasPawns = whitePawns+blackPawns != 0
+	// The leading colour is the side with fewer pawns, because that compresses better
+	if blackPawns == 0 || (whitePawns != 0 && blackPawns >= whitePawns) {
+		t.pawnCount = [2]int{whitePawns, blackPawns}
+	} else {
+		t.pawnCount = [2]int{blackPawns, whitePawns}
+	}
+
+	return t, nil
+}
+
+// The file table, loading it if this is the first access - nil if it's not available
+func (t *tableT) fileTable(isWdl bool) *fileTableT {
+	ft := &t.dtz
+	if isWdl {
+		ft = &t.wdl
+	}
+	ft.once.Do(func() { ft.ok = t.load(ft) })
+	if !ft.ok {
+		return nil
+	}
+	return ft
+}
+
+func (t *tableT) load(ft *fileTableT) (ok bool) {
+	path := t.wdlPath
+	magic := wdlMagic
+	if !ft.isWdl {
+		magic = dtzMagic
+		path = findFile(t.name + dtzSuffix)
+		if path == "" {
+			return false
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil || len(data) < len(magic) || string(data[:len(magic)]) != string(magic[:]) {
+		return false
+	}
+
+	// A corrupted file might send us out of range
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+
+	if !t.parse(ft, data) {
+		return false
+	}
+	ft.data = data
+	return true
+}
+
+func findFile(name string) string {
+	for _, dir := range searchPaths {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Set up the file table indexing info from the file data.
+// All offsets are from the start of the file, so alignment is relative to the file start too.
+func (t *tableT) parse(ft *fileTableT, data []byte) bool {
+	const (
+		splitFlag    = 1
+		hasPawnsFlag = 2
+	)
+
+	off := len(wdlMagic)
+	if t.hasPawns != (data[off]&hasPawnsFlag != 0) || (t.key != t.key2) != (data[off]&splitFlag != 0) {
+		return false
+	}
+	off++
+
+	sides := 1
+	if ft.isWdl && t.key != t.key2 {
+		sides = 2
+	}
+	maxFile := 0
+	if t.hasPawns {
+		maxFile = 3
+	}
+	// Pawns on both sides
+	pp := t.hasPawns && t.pawnCount[1] != 0
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			ft.items[i][f] = pairsDataT{}
+		}
+
+		order := [2][2]int{{int(data[off] & 0xF), 0xF}, {int(data[off] >> 4), 0xF}}
+		if pp {
+			order[0][1], order[1][1] = int(data[off+1]&0xF), int(data[off+1]>>4)
+			off++
+		}
+		off++
+
+		for k := 0; k < t.pieceCount; k++ {
+			for i := 0; i < sides; i++ {
+				if i == 0 {
+					ft.items[i][f].pieces[k] = int(data[off] & 0xF)
+				} else {
+					ft.items[i][f].pieces[k] = int(data[off] >> 4)
+				}
+			}
+			off++
+		}
+
+		for i := 0; i < sides; i++ {
+			t.setGroups(&ft.items[i][f], order[i], f)
+		}
+	}
+
+	off += off & 1
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			off = ft.items[i][f].setSizes(data, off)
+		}
+	}
+
+	if !ft.isWdl {
+		off = ft.setDtzMap(data, off, maxFile)
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := &ft.items[i][f]
+			d.sparseIndexOff = off
+			off += int(d.sparseIndexSize) * 6
+		}
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := &ft.items[i][f]
+			d.blockLengthOff = off
+			off += d.blockLengthSize * 2
+		}
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := &ft.items[i][f]
+			off = (off + 0x3F) &^ 0x3F // 64-byte alignment
+			d.dataOff = off
+			off += d.numBlocks * int(d.sizeofBlock)
+		}
+	}
+
+	return off <= len(data)
+}
+
+// Group the pieces that are encoded together.
+// Generally a group is pieces of the same type and colour, except for the leading group which (without pawns) is the first three
+// (unique) pieces or else the two kings. With pawns, the leading pawns are the first group.
+// The order gives the order in which the groups are encoded in the index.
+func (t *tableT) setGroups(d *pairsDataT, order [2]int, file int) {
+	firstLen := 2
+	if t.hasPawns {
+		firstLen = 0
+	} else if t.hasUniquePieces {
+		firstLen = 3
+	}
+
+	n := 0
+	d.groupLen[0] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	pp := t.hasPawns && t.pawnCount[1] != 0
+	next := 1
+	freeSquares := 64 - d.groupLen[0]
+	if pp {
+		next = 2
+		freeSquares -= d.groupLen[1]
+	}
+
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		if k == order[0] {
+			// Leading pawns or pieces
+			d.groupIdx[0] = idx
+			if t.hasPawns {
+				idx *= leadPawnsSize[d.groupLen[0]][file]
+			} else if t.hasUniquePieces {
+				idx *= 31332
+			} else {
+				idx *= 462
+			}
+		} else if k == order[1] {
+			// Remaining pawns
+			d.groupIdx[1] = idx
+			idx *= binomial[d.groupLen[1]][48-d.groupLen[0]]
+		} else {
+			// Remaining pieces
+			d.groupIdx[next] = idx
+			idx *= binomial[d.groupLen[next]][freeSquares]
+			freeSquares -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+}
+
+// Read the sizes and Huffman code info and return the offset following it
+func (d *pairsDataT) setSizes(data []byte, off int) int {
+	d.flags = data[off]
+	off++
+
+	if d.flags&singleValueFlag != 0 {
+		d.numBlocks = 0
+		d.span, d.sparseIndexSize = 0, 0
+		// The single value is stored here
+		d.minSymLen = int(data[off])
+		return off + 1
+	}
+
+	// The last groupIdx[] entry is the table size
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	tbSize := d.groupIdx[n]
+
+	d.sizeofBlock = 1 << data[off]
+	d.span = 1 << data[off+1]
+	d.sparseIndexSize = (tbSize + d.span - 1) / d.span
+	padding := int(data[off+2])
+	d.numBlocks = int(binary.LittleEndian.Uint32(data[off+3:]))
+	// Padded so that the sparse index does not point out of range
+	d.blockLengthSize = d.numBlocks + padding
+	d.maxSymLen = int(data[off+7])
+	d.minSymLen = int(data[off+8])
+	off += 9
+
+	d.lowestSymOff = off
+	nLens := d.maxSymLen - d.minSymLen + 1
+	d.base64 = make([]uint64, nLens)
+
+	// The canonical code is ordered such that longer symbols have lower numeric value.
+	// We build base64[] so that base64[i] >= base64[i+1], and for any symbol s64 of length i right-padded to 64 bits
+	// then base64[i-1] >= s64 >= base64[i].
+	for i := nLens - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowestSym(data, i)) - uint64(d.lowestSym(data, i+1))) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= uint(64 - i - d.minSymLen)
+	}
+	off += nLens * 2
+
+	nSyms := int(binary.LittleEndian.Uint16(data[off:]))
+	off += 2
+	d.symlen = make([]uint8, nSyms)
+	d.btreeOff = off
+
+	// Each symbol is a pair of child symbols (Recursive Pairing) - expand to count the values represented by each symbol
+	visited := make([]bool, nSyms)
+	for sym := 0; sym < nSyms; sym++ {
+		if !visited[sym] {
+			d.symlen[sym] = d.setSymlen(data, sym, visited)
+		}
+	}
+
+	return off + nSyms*3 + (nSyms & 1)
+}
+
+func (d *pairsDataT) setSymlen(data []byte, sym int, visited []bool) uint8 {
+	// We can set this now because the tree is acyclic
+	visited[sym] = true
+	right := d.right(data, sym)
+	if right == 0xFFF {
+		return 0
+	}
+	left := d.left(data, sym)
+
+	if !visited[left] {
+		d.symlen[left] = d.setSymlen(data, left, visited)
+	}
+	if !visited[right] {
+		d.symlen[right] = d.setSymlen(data, right, visited)
+	}
+
+	return d.symlen[left] + d.symlen[right] + 1
+}
+
+// Read the DTZ value maps and return the offset following them
+func (ft *fileTableT) setDtzMap(data []byte, off int, maxFile int) int {
+	ft.mapOff = off
+
+	for f := 0; f <= maxFile; f++ {
+		d := &ft.items[0][f]
+		if d.flags&mappedFlag == 0 {
+			continue
+		}
+		if d.flags&wideFlag != 0 {
+			// 16-bit values, word aligned
+			off += off & 1
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = (off-ft.mapOff)/2 + 1
+				off += 2*int(binary.LittleEndian.Uint16(data[off:])) + 2
+			}
+		} else {
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = off - ft.mapOff + 1
+				off += int(data[off]) + 1
+			}
+		}
+	}
+
+	return off + off&1
+}
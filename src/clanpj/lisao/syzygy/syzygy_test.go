@@ -0,0 +1,354 @@
+package syzygy
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	dragon "github.com/Bubblyworld/dragontoothmg"
+)
+
+func TestEncodingTables(t *testing.T) {
+	maxKK := 0
+	for idx := range mapKK {
+		for sq := range mapKK[idx] {
+			if mapKK[idx][sq] > maxKK {
+				maxKK = mapKK[idx][sq]
+			}
+		}
+	}
+	if maxKK != 461 {
+		t.Errorf("Max mapKK is %d expected 461\n", maxKK)
+	}
+	if mapPawns[8] != 47 || mapPawns[15] != 46 || mapPawns[52] != 0 {
+		t.Errorf("mapPawns a2, h2, e7 are %d, %d, %d expected 47, 46, 0\n", mapPawns[8], mapPawns[15], mapPawns[52])
+	}
+	if binomial[2][10] != 45 || binomial[5][63] != 7028847 {
+		t.Errorf("binomial[2][10], binomial[5][63] are %d, %d expected 45, 7028847\n", binomial[2][10], binomial[5][63])
+	}
+	if leadPawnsSize[1][0] != 6 {
+		t.Errorf("leadPawnsSize[1][0] is %d expected 6\n", leadPawnsSize[1][0])
+	}
+}
+
+// The 8 symmetries of the board
+func transform(sq int, sym int) int {
+	file, rank := fileOf(sq), rankOf(sq)
+	if sym&1 != 0 {
+		file = 7 - file
+	}
+	if sym&2 != 0 {
+		rank = 7 - rank
+	}
+	if sym&4 != 0 {
+		file, rank = rank, file
+	}
+	return 8*rank + file
+}
+
+func newTestTable(t *testing.T, name string, pieces []int) (*tableT, *pairsDataT) {
+	table, err := newTable(name)
+	if err != nil {
+		t.Fatalf("newTable failed: %v", err)
+	}
+	d := &pairsDataT{}
+	copy(d.pieces[:], pieces)
+	table.setGroups(d, [2]int{0, 0xF}, 0)
+	return table, d
+}
+
+// Positions related by symmetry must have the same index, and all other positions must have distinct indexes
+func TestEncodeKRvK(t *testing.T) {
+	const wK, wR, bK = 6, 4, 14
+	table, d := newTestTable(t, "KRvK", []int{wK, wR, bK})
+
+	idxToPos := make(map[uint64]int)
+	for s0 := 0; s0 < 64; s0++ {
+		for s1 := 0; s1 < 64; s1++ {
+			for s2 := 0; s2 < 64; s2++ {
+				if s0 == s1 || s0 == s2 || s1 == s2 {
+					continue
+				}
+				canonicalPos := -1
+				var idx uint64
+				for sym := 0; sym < 8; sym++ {
+					t0, t1, t2 := transform(s0, sym), transform(s1, sym), transform(s2, sym)
+					// Deliberately out of table order to exercise the re-ordering
+					symIdx := table.encode(d, []int{t2, t0, t1}, []int{bK, wK, wR}, 0)
+					if sym != 0 && symIdx != idx {
+						t.Fatalf("KRvK %d %d %d symmetry %d index is %d expected %d\n", s0, s1, s2, sym, symIdx, idx)
+					}
+					idx = symIdx
+					if pos := 4096*t0 + 64*t1 + t2; canonicalPos == -1 || pos < canonicalPos {
+						canonicalPos = pos
+					}
+				}
+				if idx >= d.groupIdx[1] {
+					t.Fatalf("KRvK %d %d %d index %d is out of range %d\n", s0, s1, s2, idx, d.groupIdx[1])
+				}
+				if pos, ok := idxToPos[idx]; ok && pos != canonicalPos {
+					t.Fatalf("KRvK %d %d %d index %d is not unique\n", s0, s1, s2, idx)
+				}
+				idxToPos[idx] = canonicalPos
+			}
+		}
+	}
+}
+
+func TestEncodeKPvK(t *testing.T) {
+	const wP, wK, bK = 1, 6, 14
+	table, d := newTestTable(t, "KPvK", []int{wP, wK, bK})
+
+	type fileIdxT struct {
+		file int
+		idx  uint64
+	}
+	idxToPos := make(map[fileIdxT]int)
+	for p := 8; p < 56; p++ {
+		for s1 := 0; s1 < 64; s1++ {
+			for s2 := 0; s2 < 64; s2++ {
+				if p == s1 || p == s2 || s1 == s2 {
+					continue
+				}
+				canonicalPos := -1
+				var idx uint64
+				for sym := 0; sym < 2; sym++ {
+					tp, t1, t2 := transform(p, sym), transform(s1, sym), transform(s2, sym)
+					symIdx := table.encode(d, []int{tp, t2, t1}, []int{wP, bK, wK}, 1)
+					if sym != 0 && symIdx != idx {
+						t.Fatalf("KPvK %d %d %d mirror index is %d expected %d\n", p, s1, s2, symIdx, idx)
+					}
+					idx = symIdx
+					if pos := 4096*tp + 64*t1 + t2; canonicalPos == -1 || pos < canonicalPos {
+						canonicalPos = pos
+					}
+				}
+				file := fileOf(p)
+				if file > 3 {
+					file = 7 - file
+				}
+				if idx >= leadPawnsSize[1][file]*63*62 {
+					t.Fatalf("KPvK %d %d %d index %d is out of range\n", p, s1, s2, idx)
+				}
+				key := fileIdxT{file, idx}
+				if pos, ok := idxToPos[key]; ok && pos != canonicalPos {
+					t.Fatalf("KPvK %d %d %d index %d is not unique\n", p, s1, s2, idx)
+				}
+				idxToPos[key] = canonicalPos
+			}
+		}
+	}
+}
+
+func probeFen(fen string) *dragon.Board {
+	board := dragon.ParseFen(fen)
+	return &board
+}
+
+func TestSingleValueTables(t *testing.T) {
+	// testdata/single holds a KQvK table where every position has the same value - white to move wins (in 9 moves), black
+	// to move loses. This exercises everything except the Huffman decompression.
+	n, err := Init("testdata/single")
+	defer Init("")
+	if n != 1 || err != nil || MaxPieces() != 3 {
+		t.Fatalf("Init found %d tables with max pieces %d (%v) expected 1 table with max pieces 3\n", n, MaxPieces(), err)
+	}
+
+	wdlTests := []struct {
+		fen string
+		wdl WdlT
+		ok  bool
+	}{
+		{"8/8/8/4k3/8/8/8/K6Q w - - 0 1", Win, true},
+		{"8/8/8/4k3/8/8/8/K6Q b - - 0 1", Loss, true},
+		{"k6q/8/8/8/4K3/8/8/8 b - - 0 1", Win, true},    // colours flipped
+		{"8/8/8/8/8/8/6k1/K6Q b - - 0 1", Draw, true},   // king takes queen
+		{"8/8/8/4k3/8/8/P7/K7 w - - 0 1", Draw, false},  // no table
+		{"8/8/8/4k3/8/8/8/K6R w - - 0 1", Draw, false},  // no table
+		{"8/8/8/4k3/8/8/8/KR5Q w - - 0 1", Draw, false}, // too many pieces
+		{"8/8/8/4k3/8/8/8/K7 w - - 0 1", Draw, true},
+	}
+	for _, test := range wdlTests {
+		wdl, ok := ProbeWdl(probeFen(test.fen))
+		if wdl != test.wdl || ok != test.ok {
+			t.Errorf("ProbeWdl %s is %s (%v) expected %s (%v)\n", test.fen, wdl, ok, test.wdl, test.ok)
+		}
+	}
+
+	dtz, ok := ProbeDtz(probeFen("8/8/8/4k3/8/8/8/K6Q w - - 0 1"))
+	if dtz != 19 || !ok {
+		t.Errorf("ProbeDtz is %d (%v) expected 19\n", dtz, ok)
+	}
+	dtz, ok = ProbeDtz(probeFen("8/8/8/4k3/8/8/8/K6Q b - - 0 1"))
+	if dtz != -20 || !ok {
+		t.Errorf("ProbeDtz is %d (%v) expected -20\n", dtz, ok)
+	}
+
+	move, wdl, dtz, ok := ProbeRoot(probeFen("8/8/8/4k3/8/8/8/K6Q w - - 0 1"))
+	if !ok || wdl != Win || dtz != 21 || move.String() == "h1e4" || move.String() == "h1d5" {
+		t.Errorf("ProbeRoot is %s %s %d (%v) expected a win with dtz 21 that doesn't hang the queen\n", &move, wdl, dtz, ok)
+	}
+}
+
+var officialFlag = flag.String("official", "testdata/official", "Directory with the official KQvK, KRvK, KPvK and KRvKP tables")
+
+type knownValueT struct {
+	fen string
+	wdl WdlT
+	dtz int
+}
+
+// Known 3-piece values - mates, stalemates, the opposition and so on
+var known3PieceValues = []knownValueT{
+	{"k7/7Q/1K6/8/8/8/8/8 w - - 0 1", Win, 1},     // Qh8# or Qb7#
+	{"k7/1Q6/1K6/8/8/8/8/8 b - - 0 1", Loss, -1},  // mated
+	{"k7/2Q5/1K6/8/8/8/8/8 b - - 0 1", Draw, 0},   // stalemate
+	{"8/8/8/8/8/8/2kR4/K7 b - - 0 1", Draw, 0},    // king takes rook
+	{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", Win, 3},   // white has the opposition
+	{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", Loss, -4}, // with either side to move
+	{"8/4k3/8/4K3/4P3/8/8/8 w - - 0 1", Draw, 0},  // white has to give up the opposition
+	{"8/4k3/8/4K3/4P3/8/8/8 b - - 0 1", Loss, -4},
+	{"8/8/8/8/4p3/4k3/8/4K3 b - - 0 1", Win, 3}, // colours flipped
+	{"k7/8/8/8/8/8/P7/K7 w - - 0 1", Draw, 0},   // rook pawn
+	{"8/P7/8/8/8/8/8/K1k5 w - - 0 1", Win, 1},   // promotes
+	{"8/8/8/4k3/8/8/8/K6B w - - 0 1", Draw, 0},
+	{"8/8/8/4k3/8/8/8/K6N b - - 0 1", Draw, 0},
+}
+
+// Known KRvKP values
+var knownKRvKPValues = []knownValueT{
+	{"4k3/8/8/8/8/8/p7/R3K3 w - - 0 1", Win, 1},   // rook takes pawn
+	{"4k3/8/8/8/8/8/p7/R3K3 b - - 0 1", Loss, -2}, // with either side to move
+	{"K7/8/8/8/8/8/1pk5/7R b - - 0 1", Draw, 0},   // the pawn costs white the rook
+}
+
+func checkKnownValues(t *testing.T, tests []knownValueT) {
+	for _, test := range tests {
+		if wdl, ok := ProbeWdl(probeFen(test.fen)); wdl != test.wdl || !ok {
+			t.Errorf("ProbeWdl %s is %s (%v) expected %s\n", test.fen, wdl, ok, test.wdl)
+		}
+		if dtz, ok := ProbeDtz(probeFen(test.fen)); dtz != test.dtz || !ok {
+			t.Errorf("ProbeDtz %s is %d (%v) expected %d\n", test.fen, dtz, ok, test.dtz)
+		}
+	}
+}
+
+// The longest wins are mate in 10 for KQvK and mate in 16 for KRvK
+func checkMaxDtz(t *testing.T) {
+	for _, piece := range []string{"Q", "R"} {
+		maxDtz := 0
+		for wk := 0; wk < 64; wk++ {
+			// By symmetry we only need the white king in the a1-d1-d4 triangle
+			if fileOf(wk) > 3 || offA1H8(wk) > 0 {
+				continue
+			}
+			for x := 0; x < 64; x++ {
+				for bk := 0; bk < 64; bk++ {
+					if x == wk || x == bk || isKingAdjacentOrSame(wk, bk) {
+						continue
+					}
+					fen := testFen(wk, piece, x, bk)
+					// Skip positions where black is in check with white to move
+					if probeFen(strings.Replace(fen, " w ", " b ", 1)).OurKingInCheck() {
+						continue
+					}
+					if dtz, ok := ProbeDtz(probeFen(fen)); ok && dtz > maxDtz {
+						maxDtz = dtz
+					}
+				}
+			}
+		}
+		if expected := map[string]int{"Q": 19, "R": 31}[piece]; maxDtz != expected {
+			t.Errorf("K%svK max DTZ is %d expected %d\n", piece, maxDtz, expected)
+		}
+	}
+}
+
+// The testdata tables are generated by generate_test.go, so this is a round-trip test of the generator and the prober
+func TestTables(t *testing.T) {
+	n, err := Init("testdata")
+	defer Init("")
+	if n != 5 || err != nil || MaxPieces() != 3 {
+		t.Fatalf("Init found %d tables with max pieces %d (%v) expected 5 tables with max pieces 3\n", n, MaxPieces(), err)
+	}
+
+	checkKnownValues(t, known3PieceValues)
+	checkMaxDtz(t)
+}
+
+// The official tables aren't checked in since they can't be regenerated here - download them from
+// http://tablebase.sesse.net/syzygy/3-4-5/ into testdata/official, or point -official at an existing set.
+func TestOfficialTables(t *testing.T) {
+	for _, name := range []string{"KQvK", "KRvK", "KPvK", "KRvKP"} {
+		for _, ext := range []string{".rtbw", ".rtbz"} {
+			if _, err := os.Stat(filepath.Join(*officialFlag, name+ext)); err != nil {
+				t.Skipf("No official %s%s table in %s\n", name, ext, *officialFlag)
+			}
+		}
+	}
+	if _, err := Init(*officialFlag); err != nil {
+		t.Fatalf("Init failed: %v\n", err)
+	}
+	defer Init("")
+
+	checkKnownValues(t, known3PieceValues)
+	checkKnownValues(t, knownKRvKPValues)
+	checkMaxDtz(t)
+}
+
+// A white-to-move FEN with the white king, a white piece and the black king
+func testFen(wk int, piece string, x int, bk int) string {
+	var squares [64]string
+	squares[wk], squares[x], squares[bk] = "K", piece, "k"
+	fen := ""
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			if c := squares[8*rank+file]; c == "" {
+				empty++
+			} else {
+				if empty > 0 {
+					fen += strconv.Itoa(empty)
+					empty = 0
+				}
+				fen += c
+			}
+		}
+		if empty > 0 {
+			fen += strconv.Itoa(empty)
+		}
+		if rank > 0 {
+			fen += "/"
+		}
+	}
+	return fen + " w - - 0 1"
+}
+
+// Playing the DTZ-optimal moves for both sides wins KPvK through the promotion to mate
+func TestProbeRootPlayout(t *testing.T) {
+	Init("testdata")
+	defer Init("")
+
+	board := probeFen("4k3/8/4K3/4P3/8/8/8/8 w - - 0 1")
+	isPromoted := false
+	for ply := 0; ply < 100; ply++ {
+		if len(board.GenerateLegalMoves()) == 0 {
+			if board.Wtomove || !board.OurKingInCheck() || !isPromoted {
+				t.Fatalf("Playout ended at %s without black being mated after a promotion\n", board.ToFen())
+			}
+			return
+		}
+		move, wdl, dtz, ok := ProbeRoot(board)
+		if expected := map[bool]WdlT{true: Win, false: Loss}[board.Wtomove]; !ok || wdl != expected {
+			t.Fatalf("ProbeRoot %s is %s %s %d (%v) expected %s\n", board.ToFen(), &move, wdl, dtz, ok, expected)
+		}
+		if move.Promote() != dragon.Nothing {
+			isPromoted = true
+		}
+		board.Apply(move)
+	}
+	t.Errorf("Playout didn't finish - at %s\n", board.ToFen())
+}
@@ -0,0 +1,132 @@
+// Index encoding tables - these are fixed by the Syzygy format
+
+package syzygy
+
+// Max pieces (including kings) in a Syzygy table
+const maxPieces = 7
+
+// Squares a2-h7 encoded to 0..47 - the pawn with the highest value is the leading pawn, i.e. the one nearest the edge and then lowest rank
+var mapPawns [64]int
+
+// Squares below the a1-h8 diagonal encoded to 0..27
+var mapB1H1H7 [64]int
+
+// Squares in the a1-d1-d4 triangle encoded to 0..9, with the diagonal squares last
+var mapA1D1D4 [64]int
+
+// The 462 legal positions of two kings where the first is in the a1-d1-d4 triangle, indexed by [mapA1D1D4[sq1]][sq2]
+var mapKK [10][64]int
+
+// binomial[k][n] is the number of ways of choosing k elements from a set of n elements
+var binomial [6][64]uint64
+
+// Index of the leading pawn (square) for each number of leading pawns
+var leadPawnIdx [6][64]uint64
+
+// Number of leading pawn encodings for each number of leading pawns and (queen-side) file of the leading pawn
+var leadPawnsSize [6][4]uint64
+
+// Distance of the square above the a1-h8 diagonal - negative if below the diagonal
+func offA1H8(sq int) int {
+	return (sq >> 3) - (sq & 7)
+}
+
+func rankOf(sq int) int { return sq >> 3 }
+func fileOf(sq int) int { return sq & 7 }
+
+func isKingAdjacentOrSame(sq1, sq2 int) bool {
+	dr, df := rankOf(sq1)-rankOf(sq2), fileOf(sq1)-fileOf(sq2)
+	return -1 <= dr && dr <= 1 && -1 <= df && df <= 1
+}
+
+func init() {
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		if offA1H8(sq) < 0 {
+			mapB1H1H7[sq] = code
+			code++
+		}
+	}
+
+	const d4 = 27
+	var diagonal []int
+	code = 0
+	for sq := 0; sq <= d4; sq++ {
+		if offA1H8(sq) < 0 && fileOf(sq) <= 3 {
+			mapA1D1D4[sq] = code
+			code++
+		} else if offA1H8(sq) == 0 && fileOf(sq) <= 3 {
+			diagonal = append(diagonal, sq)
+		}
+	}
+	// Diagonal squares are encoded last
+	for _, sq := range diagonal {
+		mapA1D1D4[sq] = code
+		code++
+	}
+
+	// If the first king is on the a1-d4 diagonal then the second king must not be above the a1-h8 diagonal
+	const b1 = 1
+	type bothOnDiagonalT struct{ idx, sq int }
+	var bothOnDiagonal []bothOnDiagonalT
+	code = 0
+	for idx := 0; idx < 10; idx++ {
+		for sq1 := 0; sq1 <= d4; sq1++ {
+			// Unmapped squares are also 0 in mapA1D1D4, so b1 is the real square for 0
+			if mapA1D1D4[sq1] != idx || (idx == 0 && sq1 != b1) {
+				continue
+			}
+			for sq2 := 0; sq2 < 64; sq2++ {
+				if isKingAdjacentOrSame(sq1, sq2) {
+					continue // illegal
+				} else if offA1H8(sq1) == 0 && offA1H8(sq2) > 0 {
+					continue // first on the diagonal, second above
+				} else if offA1H8(sq1) == 0 && offA1H8(sq2) == 0 {
+					bothOnDiagonal = append(bothOnDiagonal, bothOnDiagonalT{idx, sq2})
+				} else {
+					mapKK[idx][sq2] = code
+					code++
+				}
+			}
+		}
+	}
+	// Both kings on the diagonal are encoded last
+	for _, p := range bothOnDiagonal {
+		mapKK[p.idx][p.sq] = code
+		code++
+	}
+
+	// Pascal's rule
+	binomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < 6 && k <= n; k++ {
+			if k > 0 {
+				binomial[k][n] += binomial[k-1][n-1]
+			}
+			if k < n {
+				binomial[k][n] += binomial[k][n-1]
+			}
+		}
+	}
+
+	// There are 47 squares available to the other pawns when the leading pawn is on a2, and two fewer for each rank higher
+	availableSquares := 47
+	for leadPawnsCnt := 1; leadPawnsCnt <= 5; leadPawnsCnt++ {
+		for file := 0; file <= 3; file++ {
+			// The tables are split by file so the index restarts for each file
+			var idx uint64
+			for rank := 1; rank <= 6; rank++ {
+				sq := 8*rank + file
+				if leadPawnsCnt == 1 {
+					mapPawns[sq] = availableSquares
+					availableSquares--
+					mapPawns[sq^7] = availableSquares
+					availableSquares--
+				}
+				leadPawnIdx[leadPawnsCnt][sq] = idx
+				idx += binomial[leadPawnsCnt-1][mapPawns[sq]]
+			}
+			leadPawnsSize[leadPawnsCnt][file] = idx
+		}
+	}
+}